@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/diff"
+)
+
+const usage = `Ocidiff compares two runtime configurations.
+
+   ocidiff [-json] <old/config.json> <new/config.json>
+
+List elements are matched by identity: mounts by destination, namespaces by
+type, devices by path, rlimits by type and seccomp rules by syscall names.
+
+The exit status is 0 if the configurations are equivalent, 1 if they differ
+and 2 on error.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs ocidiff with the arguments args, and returns its exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ocidiff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the changes as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintf(stderr, "ERROR: invalid arguments number\n\n%s", usage)
+		return 2
+	}
+
+	a, err := load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 2
+	}
+	b, err := load(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	changes := diff.Diff(a, b)
+	if *asJSON {
+		if changes == nil {
+			changes = []diff.Change{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(changes)
+	} else {
+		err = diff.Report(stdout, changes)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 2
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func load(path string) (*specs.Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &spec, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.json":   `{"ociVersion": "1.0.0", "hostname": "a"}`,
+		"b.json":   `{"ociVersion": "1.0.0", "hostname": "b"}`,
+		"bad.json": `{"ociVersion": `,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{name: "equal", args: []string{a, a}},
+		{name: "equal as json", args: []string{"-json", a, a}, stdout: "[]\n"},
+		{name: "different", args: []string{a, b}, status: 1, stdout: `~ hostname: "a" -> "b"` + "\n"},
+		{name: "different as json", args: []string{"-json", a, b}, status: 1, stdout: `"path": "hostname"`},
+		{name: "arguments", args: []string{a}, status: 2, stderr: "invalid arguments number"},
+		{name: "flag", args: []string{"-x", a, b}, status: 2, stderr: "Ocidiff compares"},
+		{name: "missing", args: []string{a, filepath.Join(dir, "missing.json")}, status: 2, stderr: "missing.json"},
+		{name: "invalid", args: []string{filepath.Join(dir, "bad.json"), b}, status: 2, stderr: "bad.json"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := run(tc.args, &stdout, &stderr); status != tc.status {
+				t.Errorf("exit status %d, want %d; stderr:\n%s", status, tc.status, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tc.stdout) || (tc.stdout == "" && stdout.Len() > 0) {
+				t.Errorf("stdout %q, want %q", stdout.String(), tc.stdout)
+			}
+			if !strings.Contains(stderr.String(), tc.stderr) || (tc.stderr == "" && stderr.Len() > 0) {
+				t.Errorf("stderr %q, want %q", stderr.String(), tc.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package bundle reads and writes filesystem bundles, as defined by
+// bundle.md.
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/decode"
+	"github.com/opencontainers/runtime-spec/specs-go/validate"
+	"github.com/opencontainers/runtime-spec/specs-go/version"
+)
+
+const (
+	// ConfigFile is the name of the configuration file of a bundle.
+	ConfigFile = "config.json"
+	// ConfigMode is the permission of the configuration file written by Save.
+	ConfigMode os.FileMode = 0o644
+	// DirMode is the permission of the directories created by Create.
+	DirMode os.FileMode = 0o755
+)
+
+// Bundle is a filesystem bundle.
+type Bundle struct {
+	// Dir is the absolute path of the bundle directory.
+	Dir string
+	// Config is the configuration of the bundle.
+	Config *specs.Spec
+}
+
+// Open reads and validates the bundle in dir for platform, which is one of
+// validate.Platforms. The configuration is decoded strictly, its ociVersion
+// must be readable by specs-go, and it must meet the MUST requirements
+// checked by Validate, including the existence of the root filesystem.
+// Violations of SHOULD requirements are not errors; they are reported by
+// Validate.
+func Open(dir, platform string) (*Bundle, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	spec, err := decode.Spec(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	if err := version.CheckReadable(spec.Version); err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	b := &Bundle{Dir: dir, Config: spec}
+	var errs []error
+	for _, err := range b.Validate(platform) {
+		var v *validate.Error
+		if errors.As(err, &v) && v.Level != validate.Must {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", ConfigFile, errors.Join(errs...))
+	}
+	return b, nil
+}
+
+// Create creates the bundle directory dir, and the root filesystem
+// directory of spec if it is relative to the bundle and does not exist yet,
+// then writes spec as the configuration of the bundle.
+func Create(dir string, spec *specs.Spec) (*Bundle, error) {
+	if spec == nil {
+		return nil, errors.New("configuration is nil")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Dir: dir, Config: spec}
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return nil, err
+	}
+	if spec.Root != nil && spec.Root.Path != "" && !filepath.IsAbs(spec.Root.Path) {
+		if err := os.MkdirAll(b.RootfsPath(), DirMode); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.Save(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RootfsPath returns the path of the root filesystem, resolving a relative
+// root.path against the bundle directory. It returns an empty string if the
+// configuration has no root.
+func (b *Bundle) RootfsPath() string {
+	if b.Config == nil || b.Config.Root == nil || b.Config.Root.Path == "" {
+		return ""
+	}
+	if filepath.IsAbs(b.Config.Root.Path) {
+		return b.Config.Root.Path
+	}
+	return filepath.Join(b.Dir, b.Config.Root.Path)
+}
+
+// CheckRootfs reports whether the root filesystem of the bundle exists and
+// is a directory. A configuration without root is accepted.
+func (b *Bundle) CheckRootfs() error {
+	p := b.RootfsPath()
+	if p == "" {
+		return nil
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("root filesystem %s is not a directory", p)
+	}
+	return nil
+}
+
+// Validate checks the configuration of the bundle against the requirements
+// for platform, and the root filesystem of the bundle. The root filesystem
+// is not checked on Windows, where root.path is a volume GUID path.
+func (b *Bundle) Validate(platform string) []error {
+	if b.Config == nil {
+		return []error{&validate.Error{Path: "$", Level: validate.Must, Msg: "configuration is nil"}}
+	}
+	errs := validate.Validate(b.Config, platform)
+	if platform != "windows" {
+		if err := b.CheckRootfs(); err != nil {
+			errs = append(errs, &validate.Error{Path: "root.path", Level: validate.Must, Msg: err.Error()})
+		}
+	}
+	return errs
+}
+
+// Save atomically writes the configuration of the bundle: it is written to
+// a temporary file in the bundle directory, which then replaces
+// config.json.
+func (b *Bundle) Save() (retErr error) {
+	if b.Config == nil {
+		return errors.New("configuration is nil")
+	}
+	data, err := json.MarshalIndent(b.Config, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(b.Dir, "."+ConfigFile+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := f.Chmod(ConfigMode); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(b.Dir, ConfigFile)); err != nil {
+		return err
+	}
+	return syncDir(b.Dir)
+}
+
+// syncDir makes a rename in dir durable. Directories cannot be synced on
+// Windows, where renames are durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
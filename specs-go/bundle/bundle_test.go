@@ -0,0 +1,137 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/generate"
+)
+
+func newSpec(t *testing.T) *specs.Spec {
+	t.Helper()
+	spec, err := generate.New("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestCreateOpen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bundle")
+	spec := newSpec(t)
+	if _, err := Create(dir, spec); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != ConfigMode {
+		t.Errorf("config.json mode %v, want %v", fi.Mode().Perm(), ConfigMode)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "rootfs")); err != nil || !fi.IsDir() {
+		t.Errorf("root filesystem not created: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("bundle holds %d entries, want config.json and rootfs", len(entries))
+	}
+
+	b, err := Open(dir, "linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b.Config, spec) {
+		t.Error("the configuration read differs from the one written")
+	}
+	if errs := b.Validate("linux"); len(errs) != 0 {
+		t.Errorf("unexpected violations %v", errs)
+	}
+}
+
+func TestOpenValidates(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit func(dir string, spec *specs.Spec)
+		ok   bool
+	}{
+		{
+			name: "should violation",
+			edit: func(dir string, spec *specs.Spec) {
+				spec.Root.Path = "root"
+				os.Mkdir(filepath.Join(dir, "root"), DirMode)
+			},
+			ok: true,
+		},
+		{
+			name: "missing rootfs",
+			edit: func(dir string, spec *specs.Spec) { os.Remove(filepath.Join(dir, "rootfs")) },
+		},
+		{
+			name: "must violation",
+			edit: func(dir string, spec *specs.Spec) { spec.Process.Cwd = "home" },
+		},
+		{
+			name: "unreadable version",
+			edit: func(dir string, spec *specs.Spec) { spec.Version = "2.0.0" },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			spec := newSpec(t)
+			b, err := Create(dir, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.edit(dir, spec)
+			if err := b.Save(); err != nil {
+				t.Fatal(err)
+			}
+			_, err = Open(dir, "linux")
+			if tc.ok && err != nil {
+				t.Errorf("Open: %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("Open succeeded")
+			}
+		})
+	}
+}
+
+func TestOpenStrict(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "rootfs"), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"ociVersion": "1.0.0", "root": {"path": "rootfs", "readOnly": true}}`
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(data), ConfigMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, "linux"); err == nil {
+		t.Error("Open accepted a misspelled member")
+	}
+	if _, err := Open(filepath.Join(dir, "missing"), "linux"); err == nil {
+		t.Error("Open accepted a missing bundle")
+	}
+}
+
+func TestRootfsPath(t *testing.T) {
+	b := &Bundle{Dir: "/bundle", Config: &specs.Spec{Root: &specs.Root{Path: "rootfs"}}}
+	if got, want := b.RootfsPath(), filepath.Join("/bundle", "rootfs"); got != want {
+		t.Errorf("RootfsPath() = %q, want %q", got, want)
+	}
+	b.Config.Root = nil
+	if got := b.RootfsPath(); got != "" {
+		t.Errorf("RootfsPath() = %q without root", got)
+	}
+	if err := b.CheckRootfs(); err != nil {
+		t.Errorf("CheckRootfs() = %v without root", err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package capabilities provides typed Linux capability sets, and checks the
+// capability sets of a process against the rules enforced by the kernel.
+package capabilities
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/validate"
+)
+
+// Capability is a Linux capability, identified by its number.
+type Capability uint
+
+// Capabilities known to the package, from <linux/capability.h>.
+const (
+	Chown Capability = iota
+	DacOverride
+	DacReadSearch
+	Fowner
+	Fsetid
+	Kill
+	Setgid
+	Setuid
+	Setpcap
+	LinuxImmutable
+	NetBindService
+	NetBroadcast
+	NetAdmin
+	NetRaw
+	IpcLock
+	IpcOwner
+	SysModule
+	SysRawio
+	SysChroot
+	SysPtrace
+	SysPacct
+	SysAdmin
+	SysBoot
+	SysNice
+	SysResource
+	SysTime
+	SysTtyConfig
+	Mknod
+	Lease
+	AuditWrite
+	AuditControl
+	Setfcap
+	MacOverride
+	MacAdmin
+	Syslog
+	WakeAlarm
+	BlockSuspend
+	AuditRead
+	Perfmon
+	Bpf
+	CheckpointRestore
+
+	// Last is the capability with the highest number.
+	Last = CheckpointRestore
+)
+
+var names = [...]string{
+	Chown:             "CAP_CHOWN",
+	DacOverride:       "CAP_DAC_OVERRIDE",
+	DacReadSearch:     "CAP_DAC_READ_SEARCH",
+	Fowner:            "CAP_FOWNER",
+	Fsetid:            "CAP_FSETID",
+	Kill:              "CAP_KILL",
+	Setgid:            "CAP_SETGID",
+	Setuid:            "CAP_SETUID",
+	Setpcap:           "CAP_SETPCAP",
+	LinuxImmutable:    "CAP_LINUX_IMMUTABLE",
+	NetBindService:    "CAP_NET_BIND_SERVICE",
+	NetBroadcast:      "CAP_NET_BROADCAST",
+	NetAdmin:          "CAP_NET_ADMIN",
+	NetRaw:            "CAP_NET_RAW",
+	IpcLock:           "CAP_IPC_LOCK",
+	IpcOwner:          "CAP_IPC_OWNER",
+	SysModule:         "CAP_SYS_MODULE",
+	SysRawio:          "CAP_SYS_RAWIO",
+	SysChroot:         "CAP_SYS_CHROOT",
+	SysPtrace:         "CAP_SYS_PTRACE",
+	SysPacct:          "CAP_SYS_PACCT",
+	SysAdmin:          "CAP_SYS_ADMIN",
+	SysBoot:           "CAP_SYS_BOOT",
+	SysNice:           "CAP_SYS_NICE",
+	SysResource:       "CAP_SYS_RESOURCE",
+	SysTime:           "CAP_SYS_TIME",
+	SysTtyConfig:      "CAP_SYS_TTY_CONFIG",
+	Mknod:             "CAP_MKNOD",
+	Lease:             "CAP_LEASE",
+	AuditWrite:        "CAP_AUDIT_WRITE",
+	AuditControl:      "CAP_AUDIT_CONTROL",
+	Setfcap:           "CAP_SETFCAP",
+	MacOverride:       "CAP_MAC_OVERRIDE",
+	MacAdmin:          "CAP_MAC_ADMIN",
+	Syslog:            "CAP_SYSLOG",
+	WakeAlarm:         "CAP_WAKE_ALARM",
+	BlockSuspend:      "CAP_BLOCK_SUSPEND",
+	AuditRead:         "CAP_AUDIT_READ",
+	Perfmon:           "CAP_PERFMON",
+	Bpf:               "CAP_BPF",
+	CheckpointRestore: "CAP_CHECKPOINT_RESTORE",
+}
+
+// String returns the name of c, such as "CAP_CHOWN".
+func (c Capability) String() string {
+	if c > Last {
+		return fmt.Sprintf("CAP_%d", uint(c))
+	}
+	return names[c]
+}
+
+// Parse returns the capability named name, with or without the "CAP_"
+// prefix. Names are case-insensitive.
+func Parse(name string) (Capability, error) {
+	n := strings.ToUpper(name)
+	if !strings.HasPrefix(n, "CAP_") {
+		n = "CAP_" + n
+	}
+	for c, s := range names {
+		if s == n {
+			return Capability(c), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown capability %q", name)
+}
+
+// Known returns the set of the capabilities known to the package.
+func Known() Set {
+	return Set(1)<<(Last+1) - 1
+}
+
+// Set is a set of capabilities.
+type Set uint64
+
+// NewSet returns the set of caps. Capabilities unknown to the package are
+// ignored.
+func NewSet(caps ...Capability) Set {
+	var s Set
+	for _, c := range caps {
+		if c <= Last {
+			s |= 1 << c
+		}
+	}
+	return s
+}
+
+// ParseSet returns the set of the capabilities named by names, as accepted
+// by Parse.
+func ParseSet(names []string) (Set, error) {
+	var s Set
+	for _, name := range names {
+		c, err := Parse(name)
+		if err != nil {
+			return 0, err
+		}
+		s |= 1 << c
+	}
+	return s, nil
+}
+
+// Has reports whether c is in s.
+func (s Set) Has(c Capability) bool {
+	return c <= Last && s&(1<<c) != 0
+}
+
+// Len returns the number of capabilities in s.
+func (s Set) Len() int {
+	return bits.OnesCount64(uint64(s))
+}
+
+// Union returns the capabilities in s or t.
+func (s Set) Union(t Set) Set {
+	return s | t
+}
+
+// Intersection returns the capabilities in both s and t.
+func (s Set) Intersection(t Set) Set {
+	return s & t
+}
+
+// Difference returns the capabilities in s but not in t.
+func (s Set) Difference(t Set) Set {
+	return s &^ t
+}
+
+// SubsetOf reports whether all the capabilities of s are in t.
+func (s Set) SubsetOf(t Set) bool {
+	return s&^t == 0
+}
+
+// List returns the capabilities of s, in increasing order.
+func (s Set) List() []Capability {
+	list := make([]Capability, 0, s.Len())
+	for c := Capability(0); c <= Last; c++ {
+		if s.Has(c) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Strings returns the names of the capabilities of s, in increasing order.
+func (s Set) Strings() []string {
+	list := make([]string, 0, s.Len())
+	for _, c := range s.List() {
+		list = append(list, c.String())
+	}
+	return list
+}
+
+// String returns the names of the capabilities of s, separated by commas.
+func (s Set) String() string {
+	return strings.Join(s.Strings(), ",")
+}
+
+// Sets holds the capability sets of a process.
+type Sets struct {
+	Bounding    Set
+	Effective   Set
+	Inheritable Set
+	Permitted   Set
+	Ambient     Set
+}
+
+// FromSpec returns the capability sets of c, which may be nil.
+func FromSpec(c *specs.LinuxCapabilities) (Sets, error) {
+	var s Sets
+	if c == nil {
+		return s, nil
+	}
+	var err error
+	for _, set := range []struct {
+		name  string
+		names []string
+		set   *Set
+	}{
+		{"bounding", c.Bounding, &s.Bounding},
+		{"effective", c.Effective, &s.Effective},
+		{"inheritable", c.Inheritable, &s.Inheritable},
+		{"permitted", c.Permitted, &s.Permitted},
+		{"ambient", c.Ambient, &s.Ambient},
+	} {
+		if *set.set, err = ParseSet(set.names); err != nil {
+			return Sets{}, fmt.Errorf("%s: %w", set.name, err)
+		}
+	}
+	return s, nil
+}
+
+// Spec returns s as the capabilities of a configuration.
+func (s Sets) Spec() *specs.LinuxCapabilities {
+	return &specs.LinuxCapabilities{
+		Bounding:    s.Bounding.Strings(),
+		Effective:   s.Effective.Strings(),
+		Inheritable: s.Inheritable.Strings(),
+		Permitted:   s.Permitted.Strings(),
+		Ambient:     s.Ambient.Strings(),
+	}
+}
+
+// Check checks the capability sets of c against the rules enforced by the
+// kernel: the effective set must be a subset of the permitted set, and the
+// ambient set a subset of both the permitted and the inheritable sets.
+// Permitted capabilities missing from the bounding set are reported at the
+// Should level, since the process cannot regain them from the capabilities
+// of an executable file. Unknown capabilities, which may be supported by
+// newer kernels, are also reported at the Should level.
+func Check(c *specs.LinuxCapabilities) []error {
+	if c == nil {
+		return nil
+	}
+	var errs []error
+	parse := func(name string, list []string) Set {
+		var s Set
+		for i, n := range list {
+			c, err := Parse(n)
+			if err != nil {
+				errs = append(errs, &validate.Error{Path: fmt.Sprintf("process.capabilities.%s[%d]", name, i), Level: validate.Should, Msg: err.Error()})
+				continue
+			}
+			s |= 1 << c
+		}
+		return s
+	}
+	s := Sets{
+		Bounding:    parse("bounding", c.Bounding),
+		Effective:   parse("effective", c.Effective),
+		Inheritable: parse("inheritable", c.Inheritable),
+		Permitted:   parse("permitted", c.Permitted),
+		Ambient:     parse("ambient", c.Ambient),
+	}
+	if d := s.Effective.Difference(s.Permitted); d != 0 {
+		errs = append(errs, &validate.Error{Path: "process.capabilities.effective", Level: validate.Must, Msg: "capabilities not in the permitted set: " + d.String()})
+	}
+	if d := s.Ambient.Difference(s.Permitted.Intersection(s.Inheritable)); d != 0 {
+		errs = append(errs, &validate.Error{Path: "process.capabilities.ambient", Level: validate.Must, Msg: "capabilities not in both the permitted and inheritable sets: " + d.String()})
+	}
+	if d := s.Permitted.Difference(s.Bounding); d != 0 {
+		errs = append(errs, &validate.Error{Path: "process.capabilities.bounding", Level: validate.Should, Msg: "permitted capabilities not in the bounding set: " + d.String()})
+	}
+	return errs
+}
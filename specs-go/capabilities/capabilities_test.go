@@ -0,0 +1,170 @@
+package capabilities
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/validate"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Capability
+		err  bool
+	}{
+		{in: "CAP_CHOWN", want: Chown},
+		{in: "cap_sys_admin", want: SysAdmin},
+		{in: "net_raw", want: NetRaw},
+		{in: "CAP_CHECKPOINT_RESTORE", want: CheckpointRestore},
+		{in: "CAP_BOGUS", err: true},
+		{in: "", err: true},
+		{in: "CAP_", err: true},
+	} {
+		got, err := Parse(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+	for c := Capability(0); c <= Last; c++ {
+		if got, err := Parse(c.String()); err != nil || got != c {
+			t.Errorf("Parse(%q) = %v, %v", c.String(), got, err)
+		}
+	}
+	if got := (Last + 1).String(); got != "CAP_41" {
+		t.Errorf("String() of an unknown capability = %q", got)
+	}
+}
+
+func TestSet(t *testing.T) {
+	a := NewSet(Chown, Kill, SysAdmin, Last+1)
+	b := NewSet(Kill, NetRaw)
+	for _, tc := range []struct {
+		name string
+		got  Set
+		want []string
+	}{
+		{"new", a, []string{"CAP_CHOWN", "CAP_KILL", "CAP_SYS_ADMIN"}},
+		{"union", a.Union(b), []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW", "CAP_SYS_ADMIN"}},
+		{"intersection", a.Intersection(b), []string{"CAP_KILL"}},
+		{"difference", a.Difference(b), []string{"CAP_CHOWN", "CAP_SYS_ADMIN"}},
+		{"empty", Set(0), []string{}},
+	} {
+		if got := tc.got.Strings(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if a.Len() != 3 || !a.Has(Kill) || a.Has(NetRaw) || a.Has(Last+1) {
+		t.Errorf("unexpected set %v", a)
+	}
+	if !a.Intersection(b).SubsetOf(b) || a.SubsetOf(b) {
+		t.Error("SubsetOf")
+	}
+	if got := Known().Len(); got != int(Last)+1 {
+		t.Errorf("Known() holds %d capabilities", got)
+	}
+	if got, want := a.String(), "CAP_CHOWN,CAP_KILL,CAP_SYS_ADMIN"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if s, err := ParseSet([]string{"kill", "CAP_CHOWN"}); err != nil || s != NewSet(Chown, Kill) {
+		t.Errorf("ParseSet = %v, %v", s, err)
+	}
+	if _, err := ParseSet([]string{"CAP_KILL", "CAP_BOGUS"}); err == nil {
+		t.Error("ParseSet accepted an unknown capability")
+	}
+}
+
+func TestSpecRoundTrip(t *testing.T) {
+	c := &specs.LinuxCapabilities{
+		Bounding:    []string{"CAP_KILL", "CAP_CHOWN"},
+		Effective:   []string{"CAP_CHOWN"},
+		Inheritable: []string{},
+		Permitted:   []string{"CAP_CHOWN", "CAP_KILL"},
+		Ambient:     []string{},
+	}
+	s, err := FromSpec(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &specs.LinuxCapabilities{
+		Bounding:    []string{"CAP_CHOWN", "CAP_KILL"},
+		Effective:   []string{"CAP_CHOWN"},
+		Inheritable: []string{},
+		Permitted:   []string{"CAP_CHOWN", "CAP_KILL"},
+		Ambient:     []string{},
+	}
+	if got := s.Spec(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if s, err := FromSpec(nil); err != nil || s != (Sets{}) {
+		t.Errorf("FromSpec(nil) = %+v, %v", s, err)
+	}
+	if _, err := FromSpec(&specs.LinuxCapabilities{Ambient: []string{"CAP_BOGUS"}}); err == nil {
+		t.Error("FromSpec accepted an unknown capability")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		caps *specs.LinuxCapabilities
+		want map[string]validate.Level
+	}{
+		{name: "nil"},
+		{
+			name: "valid",
+			caps: &specs.LinuxCapabilities{
+				Bounding:    []string{"CAP_CHOWN", "CAP_KILL"},
+				Effective:   []string{"CAP_KILL"},
+				Inheritable: []string{"CAP_KILL"},
+				Permitted:   []string{"CAP_CHOWN", "CAP_KILL"},
+				Ambient:     []string{"CAP_KILL"},
+			},
+		},
+		{
+			name: "effective not permitted",
+			caps: &specs.LinuxCapabilities{Bounding: []string{"CAP_KILL"}, Effective: []string{"CAP_KILL"}},
+			want: map[string]validate.Level{"process.capabilities.effective": validate.Must},
+		},
+		{
+			name: "ambient not inheritable",
+			caps: &specs.LinuxCapabilities{Bounding: []string{"CAP_KILL"}, Permitted: []string{"CAP_KILL"}, Ambient: []string{"CAP_KILL"}},
+			want: map[string]validate.Level{"process.capabilities.ambient": validate.Must},
+		},
+		{
+			name: "permitted not bounding",
+			caps: &specs.LinuxCapabilities{Permitted: []string{"CAP_KILL"}},
+			want: map[string]validate.Level{"process.capabilities.bounding": validate.Should},
+		},
+		{
+			name: "unknown",
+			caps: &specs.LinuxCapabilities{Bounding: []string{"CAP_KILL", "CAP_FUTURE"}},
+			want: map[string]validate.Level{"process.capabilities.bounding[1]": validate.Should},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got map[string]validate.Level
+			for _, err := range Check(tc.caps) {
+				var e *validate.Error
+				if !errors.As(err, &e) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if got == nil {
+					got = make(map[string]validate.Level)
+				}
+				got[e.Path] = e.Level
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
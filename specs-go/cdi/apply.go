@@ -0,0 +1,269 @@
+package cdi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/devices"
+)
+
+// edits are container edits, along with the device or specification they
+// come from.
+type edits struct {
+	source string
+	ContainerEdits
+}
+
+// Apply applies the edits of the devices of the qualified names to spec:
+// those of the devices and those of their specifications, each applied
+// once. Environment variables, device nodes and mounts replace those of
+// spec with the same name, path or destination. Device nodes are allowed
+// in the device cgroup with their permissions.
+//
+// Edits of different devices or specifications setting the same environment
+// variable, device node path, or mount destination to different values are
+// conflicts, and so are different Intel RDT settings. Apply returns the
+// conflicts without changing spec.
+func (r *Registry) Apply(spec *specs.Spec, names ...string) error {
+	var list []edits
+	seenDevices := make(map[string]bool)
+	seenSpecs := make(map[*Spec]bool)
+	for _, name := range names {
+		if seenDevices[name] {
+			continue
+		}
+		seenDevices[name] = true
+		d, s, err := r.Lookup(name)
+		if err != nil {
+			return err
+		}
+		if !seenSpecs[s] {
+			seenSpecs[s] = true
+			// Specifications of the same kind are told apart by their
+			// file, or their order when added with Add.
+			source := r.devices[name].path
+			if source == "" {
+				source = fmt.Sprintf("%s specification %d", s.Kind, len(seenSpecs))
+			}
+			list = append(list, edits{source: source, ContainerEdits: s.ContainerEdits})
+		}
+		list = append(list, edits{source: name, ContainerEdits: d.ContainerEdits})
+	}
+	for i := range list {
+		e := &list[i]
+		resolved := make([]DeviceNode, len(e.DeviceNodes))
+		for j, n := range e.DeviceNodes {
+			var err error
+			if resolved[j], err = resolveNode(n); err != nil {
+				return fmt.Errorf("%s: %w", e.source, err)
+			}
+		}
+		e.DeviceNodes = resolved
+	}
+	if err := check(list); err != nil {
+		return err
+	}
+	for _, e := range list {
+		apply(spec, e.ContainerEdits)
+	}
+	return nil
+}
+
+// check returns the conflicts between list, and the hooks of unknown
+// events.
+func check(list []edits) error {
+	var errs []error
+	type value struct {
+		source string
+		v      interface{}
+	}
+	seen := make(map[string]value)
+	add := func(key, source string, v interface{}) {
+		prev, ok := seen[key]
+		if !ok {
+			seen[key] = value{source, v}
+			return
+		}
+		if prev.source != source && !reflect.DeepEqual(prev.v, v) {
+			errs = append(errs, fmt.Errorf("conflicting %s in %s and %s", key, prev.source, source))
+		}
+	}
+	for _, e := range list {
+		for _, env := range e.Env {
+			k, v, _ := strings.Cut(env, "=")
+			add(fmt.Sprintf("environment variable %q", k), e.source, v)
+		}
+		for _, n := range e.DeviceNodes {
+			add(fmt.Sprintf("device node %q", n.Path), e.source, n)
+		}
+		for _, m := range e.Mounts {
+			if m.ContainerPath == "" {
+				errs = append(errs, fmt.Errorf("%s: mount with no container path", e.source))
+				continue
+			}
+			add(fmt.Sprintf("mount %q", m.ContainerPath), e.source, m)
+		}
+		if e.IntelRdt != nil {
+			add("Intel RDT settings", e.source, *e.IntelRdt)
+		}
+		for _, h := range e.Hooks {
+			if hookList(&specs.Hooks{}, h.HookName) == nil {
+				errs = append(errs, fmt.Errorf("%s: unknown hook %q", e.source, h.HookName))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hookList(h *specs.Hooks, name string) *[]specs.Hook {
+	switch name {
+	case "prestart":
+		return &h.Prestart
+	case "createRuntime":
+		return &h.CreateRuntime
+	case "createContainer":
+		return &h.CreateContainer
+	case "startContainer":
+		return &h.StartContainer
+	case "poststart":
+		return &h.Poststart
+	case "poststop":
+		return &h.Poststop
+	}
+	return nil
+}
+
+// resolveNode completes n with the type and numbers of the node on the
+// host, when they are missing.
+func resolveNode(n DeviceNode) (DeviceNode, error) {
+	if n.Path == "" {
+		return n, fmt.Errorf("device node with no path")
+	}
+	if n.HostPath == "" {
+		n.HostPath = n.Path
+	}
+	if n.Type != "" && (n.Major != 0 || n.Type == "p") {
+		return n, nil
+	}
+	d, err := hostDevice(n.HostPath)
+	if err != nil {
+		return n, err
+	}
+	if n.Type != "" && n.Type != d.Type && !(n.Type == "u" && d.Type == "c") {
+		return n, fmt.Errorf("%s: device type %q does not match the host node type %q", n.HostPath, n.Type, d.Type)
+	}
+	n.Type, n.Major, n.Minor = d.Type, d.Major, d.Minor
+	if n.FileMode == nil {
+		n.FileMode = d.FileMode
+	}
+	return n, nil
+}
+
+func apply(spec *specs.Spec, e ContainerEdits) {
+	if len(e.Env) > 0 || len(e.AdditionalGIDs) > 0 {
+		if spec.Process == nil {
+			spec.Process = &specs.Process{}
+		}
+	}
+	for _, env := range e.Env {
+		k, _, _ := strings.Cut(env, "=")
+		replaced := false
+		for i, cur := range spec.Process.Env {
+			if ck, _, _ := strings.Cut(cur, "="); ck == k {
+				spec.Process.Env[i] = env
+				replaced = true
+			}
+		}
+		if !replaced {
+			spec.Process.Env = append(spec.Process.Env, env)
+		}
+	}
+	for _, gid := range e.AdditionalGIDs {
+		found := false
+		for _, g := range spec.Process.User.AdditionalGids {
+			found = found || g == gid
+		}
+		if !found {
+			spec.Process.User.AdditionalGids = append(spec.Process.User.AdditionalGids, gid)
+		}
+	}
+
+	if len(e.DeviceNodes) > 0 || e.IntelRdt != nil {
+		if spec.Linux == nil {
+			spec.Linux = &specs.Linux{}
+		}
+	}
+	for _, n := range e.DeviceNodes {
+		d := specs.LinuxDevice{
+			Path:     n.Path,
+			Type:     n.Type,
+			Major:    n.Major,
+			Minor:    n.Minor,
+			FileMode: n.FileMode,
+			UID:      n.UID,
+			GID:      n.GID,
+		}
+		replaced := false
+		for i := range spec.Linux.Devices {
+			if spec.Linux.Devices[i].Path == d.Path {
+				spec.Linux.Devices[i] = d
+				replaced = true
+			}
+		}
+		if !replaced {
+			spec.Linux.Devices = append(spec.Linux.Devices, d)
+		}
+		rule, ok := devices.Rule(d)
+		if !ok {
+			continue
+		}
+		if n.Permissions != "" {
+			rule.Access = n.Permissions
+		}
+		if spec.Linux.Resources == nil {
+			spec.Linux.Resources = &specs.LinuxResources{}
+		}
+		if !hasRule(spec.Linux.Resources.Devices, rule) {
+			spec.Linux.Resources.Devices = append(spec.Linux.Resources.Devices, rule)
+		}
+	}
+	if e.IntelRdt != nil {
+		rdt := *e.IntelRdt
+		spec.Linux.IntelRdt = &rdt
+	}
+
+	for _, m := range e.Mounts {
+		mounts := spec.Mounts[:0]
+		for _, cur := range spec.Mounts {
+			if cur.Destination != m.ContainerPath {
+				mounts = append(mounts, cur)
+			}
+		}
+		spec.Mounts = append(mounts, specs.Mount{
+			Destination: m.ContainerPath,
+			Source:      m.HostPath,
+			Type:        m.Type,
+			Options:     m.Options,
+		})
+	}
+
+	if len(e.Hooks) > 0 && spec.Hooks == nil {
+		spec.Hooks = &specs.Hooks{}
+	}
+	for _, h := range e.Hooks {
+		l := hookList(spec.Hooks, h.HookName)
+		*l = append(*l, h.Hook)
+	}
+}
+
+func hasRule(rules []specs.LinuxDeviceCgroup, rule specs.LinuxDeviceCgroup) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
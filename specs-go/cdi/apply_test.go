@@ -0,0 +1,191 @@
+package cdi
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func i64(v int64) *int64 { return &v }
+
+// registry returns a registry of the devices of the specifications of
+// kind vendor.com/gpu with the edits of edits, by device name, and
+// specEdits.
+func registry(specEdits ContainerEdits, edits map[string]ContainerEdits) *Registry {
+	s := &Spec{Version: "0.6.0", Kind: "vendor.com/gpu", ContainerEdits: specEdits}
+	for name, e := range edits {
+		s.Devices = append(s.Devices, Device{Name: name, ContainerEdits: e})
+	}
+	r := &Registry{}
+	r.Add(s)
+	return r
+}
+
+func TestApply(t *testing.T) {
+	mode := os.FileMode(0o666)
+	r := registry(ContainerEdits{
+		Env:   []string{"VENDOR=1"},
+		Hooks: []Hook{{HookName: "createRuntime", Hook: specs.Hook{Path: "/bin/hook"}}},
+	}, map[string]ContainerEdits{
+		"0": {
+			Env:            []string{"GPU=0", "PATH=/gpu"},
+			DeviceNodes:    []DeviceNode{{Path: "/dev/gpu0", Type: "c", Major: 195, Minor: 0, FileMode: &mode, Permissions: "rw"}},
+			Mounts:         []Mount{{HostPath: "/lib/gpu", ContainerPath: "/usr/lib/gpu", Options: []string{"ro", "bind"}}},
+			AdditionalGIDs: []uint32{44},
+		},
+		"1": {
+			DeviceNodes: []DeviceNode{
+				{Path: "/dev/gpu1", Type: "c", Major: 195, Minor: 1},
+				{Path: "/dev/gpuctl", Type: "c", Major: 195, Minor: 255},
+			},
+			AdditionalGIDs: []uint32{44},
+		},
+	})
+	spec := &specs.Spec{
+		Process: &specs.Process{Env: []string{"PATH=/bin"}},
+		Mounts:  []specs.Mount{{Destination: "/usr/lib/gpu", Type: "tmpfs"}, {Destination: "/proc", Type: "proc"}},
+		Linux:   &specs.Linux{Devices: []specs.LinuxDevice{{Path: "/dev/gpuctl", Type: "c", Major: 1, Minor: 1}}},
+	}
+	if err := r.Apply(spec, "vendor.com/gpu=0", "vendor.com/gpu=1", "vendor.com/gpu=0"); err != nil {
+		t.Fatal(err)
+	}
+	want := &specs.Spec{
+		Process: &specs.Process{
+			Env:  []string{"PATH=/gpu", "VENDOR=1", "GPU=0"},
+			User: specs.User{AdditionalGids: []uint32{44}},
+		},
+		Mounts: []specs.Mount{
+			{Destination: "/proc", Type: "proc"},
+			{Destination: "/usr/lib/gpu", Source: "/lib/gpu", Options: []string{"ro", "bind"}},
+		},
+		Hooks: &specs.Hooks{CreateRuntime: []specs.Hook{{Path: "/bin/hook"}}},
+		Linux: &specs.Linux{
+			Devices: []specs.LinuxDevice{
+				{Path: "/dev/gpuctl", Type: "c", Major: 195, Minor: 255},
+				{Path: "/dev/gpu0", Type: "c", Major: 195, Minor: 0, FileMode: &mode},
+				{Path: "/dev/gpu1", Type: "c", Major: 195, Minor: 1},
+			},
+			Resources: &specs.LinuxResources{Devices: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Major: i64(195), Minor: i64(0), Access: "rw"},
+				{Allow: true, Type: "c", Major: i64(195), Minor: i64(1), Access: "rwm"},
+				{Allow: true, Type: "c", Major: i64(195), Minor: i64(255), Access: "rwm"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("got %+v, want %+v", spec, want)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		edits map[string]ContainerEdits
+		want  string
+	}{
+		{
+			name: "environment variable",
+			edits: map[string]ContainerEdits{
+				"0": {Env: []string{"GPU=0"}},
+				"1": {Env: []string{"GPU=1"}},
+			},
+			want: `conflicting environment variable "GPU"`,
+		},
+		{
+			name: "device node",
+			edits: map[string]ContainerEdits{
+				"0": {DeviceNodes: []DeviceNode{{Path: "/dev/gpu", Type: "c", Major: 195, Minor: 0}}},
+				"1": {DeviceNodes: []DeviceNode{{Path: "/dev/gpu", Type: "c", Major: 195, Minor: 1}}},
+			},
+			want: `conflicting device node "/dev/gpu"`,
+		},
+		{
+			name: "mount",
+			edits: map[string]ContainerEdits{
+				"0": {Mounts: []Mount{{HostPath: "/lib/gpu0", ContainerPath: "/lib/gpu"}}},
+				"1": {Mounts: []Mount{{HostPath: "/lib/gpu1", ContainerPath: "/lib/gpu"}}},
+			},
+			want: `conflicting mount "/lib/gpu"`,
+		},
+		{
+			name: "intel rdt",
+			edits: map[string]ContainerEdits{
+				"0": {IntelRdt: &specs.LinuxIntelRdt{ClosID: "a"}},
+				"1": {IntelRdt: &specs.LinuxIntelRdt{ClosID: "b"}},
+			},
+			want: "conflicting Intel RDT settings",
+		},
+		{
+			name: "mount without container path",
+			edits: map[string]ContainerEdits{
+				"0": {Mounts: []Mount{{HostPath: "/lib/gpu0"}}},
+				"1": {},
+			},
+			want: "mount with no container path",
+		},
+		{
+			name: "unknown hook",
+			edits: map[string]ContainerEdits{
+				"0": {Hooks: []Hook{{HookName: "prestop", Hook: specs.Hook{Path: "/bin/hook"}}}},
+				"1": {},
+			},
+			want: `unknown hook "prestop"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := registry(ContainerEdits{}, tc.edits)
+			spec := &specs.Spec{}
+			err := r.Apply(spec, "vendor.com/gpu=0", "vendor.com/gpu=1")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+			if !reflect.DeepEqual(spec, &specs.Spec{}) {
+				t.Errorf("Apply changed the configuration to %+v", spec)
+			}
+		})
+	}
+
+	// Identical edits do not conflict.
+	same := ContainerEdits{
+		Env:         []string{"GPU=1"},
+		DeviceNodes: []DeviceNode{{Path: "/dev/gpu", Type: "c", Major: 195, Minor: 0}},
+		Mounts:      []Mount{{HostPath: "/lib/gpu", ContainerPath: "/lib/gpu"}},
+	}
+	r := registry(ContainerEdits{}, map[string]ContainerEdits{"0": same, "1": same})
+	if err := r.Apply(&specs.Spec{}, "vendor.com/gpu=0", "vendor.com/gpu=1"); err != nil {
+		t.Error(err)
+	}
+	if err := r.Apply(&specs.Spec{}, "vendor.com/gpu=2"); err == nil {
+		t.Error("Apply succeeded with an unknown device")
+	}
+}
+
+func TestApplySpecConflicts(t *testing.T) {
+	dir := writeSpecs(t, map[string]string{
+		"a.json": `{"cdiVersion": "0.6.0", "kind": "vendor.com/gpu", "devices": [{"name": "0"}], "containerEdits": {"env": ["VENDOR=a"]}}`,
+		"b.json": `{"cdiVersion": "0.6.0", "kind": "vendor.com/gpu", "devices": [{"name": "1"}], "containerEdits": {"env": ["VENDOR=b"]}}`,
+	})
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := &Registry{}
+	added.Add(&Spec{Version: "0.6.0", Kind: "vendor.com/gpu", Devices: []Device{{Name: "0"}}, ContainerEdits: ContainerEdits{Env: []string{"VENDOR=a"}}})
+	added.Add(&Spec{Version: "0.6.0", Kind: "vendor.com/gpu", Devices: []Device{{Name: "1"}}, ContainerEdits: ContainerEdits{Env: []string{"VENDOR=b"}}})
+	for name, r := range map[string]*Registry{"loaded": loaded, "added": added} {
+		err := r.Apply(&specs.Spec{}, "vendor.com/gpu=0", "vendor.com/gpu=1")
+		if err == nil || !strings.Contains(err.Error(), `conflicting environment variable "VENDOR"`) {
+			t.Errorf("%s: got error %v, want a conflict", name, err)
+		}
+	}
+	if err := loaded.Apply(&specs.Spec{}, "vendor.com/gpu=0", "vendor.com/gpu=1"); err != nil {
+		for _, file := range []string{"a.json", "b.json"} {
+			if !strings.Contains(err.Error(), file) {
+				t.Errorf("error %q does not mention %s", err, file)
+			}
+		}
+	}
+}
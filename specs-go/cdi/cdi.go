@@ -0,0 +1,180 @@
+// Package cdi applies the devices described by Container Device Interface
+// (CDI) specifications to a configuration.
+//
+// A CDI specification describes the devices of a kind, such as
+// "vendor.com/gpu", and the edits to apply to a container to give it
+// access to each device: environment variables, device nodes, mounts,
+// hooks and additional groups. Devices are requested by their qualified
+// name, such as "vendor.com/gpu=0".
+//
+// Specifications are decoded from JSON or YAML.
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultDirs are the directories CDI specifications are read from by
+// default, in increasing order of priority.
+var DefaultDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
+// Spec is a CDI specification.
+type Spec struct {
+	// Version is the version of the CDI specification format.
+	Version string `json:"cdiVersion"`
+	// Kind is the kind of the devices, "<vendor>/<class>".
+	Kind        string            `json:"kind"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Devices     []Device          `json:"devices"`
+	// ContainerEdits are applied along with any device of the
+	// specification.
+	ContainerEdits ContainerEdits `json:"containerEdits,omitempty"`
+}
+
+// Device is a device of a CDI specification.
+type Device struct {
+	// Name is the name of the device, unique among the devices of its kind.
+	Name           string            `json:"name"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	ContainerEdits ContainerEdits    `json:"containerEdits"`
+}
+
+// ContainerEdits are the edits applied to a container for a device.
+type ContainerEdits struct {
+	// Env holds environment variables, "KEY=value".
+	Env            []string             `json:"env,omitempty"`
+	DeviceNodes    []DeviceNode         `json:"deviceNodes,omitempty"`
+	Hooks          []Hook               `json:"hooks,omitempty"`
+	Mounts         []Mount              `json:"mounts,omitempty"`
+	IntelRdt       *specs.LinuxIntelRdt `json:"intelRdt,omitempty"`
+	AdditionalGIDs []uint32             `json:"additionalGids,omitempty"`
+}
+
+// DeviceNode is a device node to create in a container.
+type DeviceNode struct {
+	// Path is the path of the node in the container.
+	Path string `json:"path"`
+	// HostPath is the path of the node on the host, Path when empty.
+	HostPath string `json:"hostPath,omitempty"`
+	// Type, Major and Minor are read from the node on the host when Type
+	// is empty, or Major is 0 for a block or character device.
+	Type     string       `json:"type,omitempty"`
+	Major    int64        `json:"major,omitempty"`
+	Minor    int64        `json:"minor,omitempty"`
+	FileMode *os.FileMode `json:"fileMode,omitempty"`
+	// Permissions is the cgroup access allowed to the node, "rwm" when
+	// empty.
+	Permissions string  `json:"permissions,omitempty"`
+	UID         *uint32 `json:"uid,omitempty"`
+	GID         *uint32 `json:"gid,omitempty"`
+}
+
+// Mount is a mount to add to a container.
+type Mount struct {
+	HostPath      string   `json:"hostPath"`
+	ContainerPath string   `json:"containerPath"`
+	Options       []string `json:"options,omitempty"`
+	Type          string   `json:"type,omitempty"`
+}
+
+// Hook is a hook to add to a container.
+type Hook struct {
+	// HookName is the lifecycle event of the hook, such as
+	// "createContainer", as named by the fields of specs.Hooks.
+	HookName string `json:"hookName"`
+	specs.Hook
+}
+
+// ParseSpec decodes the CDI specification data, in JSON or YAML, and
+// checks its kind and the names of its devices.
+func ParseSpec(data []byte) (*Spec, error) {
+	var s Spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version == "" {
+		return nil, fmt.Errorf("cdiVersion is empty")
+	}
+	if _, _, err := ParseKind(s.Kind); err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for i, d := range s.Devices {
+		if err := checkName(d.Name); err != nil {
+			return nil, fmt.Errorf("devices[%d]: %w", i, err)
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("devices[%d]: device %q is defined more than once", i, d.Name)
+		}
+		names[d.Name] = true
+	}
+	return &s, nil
+}
+
+// QualifiedName returns the qualified name of the device name of kind,
+// such as "vendor.com/gpu=0".
+func QualifiedName(kind, name string) string {
+	return kind + "=" + name
+}
+
+// ParseQualifiedName splits the qualified name of a device into its kind
+// and name, after checking them.
+func ParseQualifiedName(qualified string) (kind, name string, err error) {
+	kind, name, ok := strings.Cut(qualified, "=")
+	if !ok {
+		return "", "", fmt.Errorf("%q is not a qualified device name", qualified)
+	}
+	if _, _, err := ParseKind(kind); err != nil {
+		return "", "", fmt.Errorf("%q: %w", qualified, err)
+	}
+	if err := checkName(name); err != nil {
+		return "", "", fmt.Errorf("%q: %w", qualified, err)
+	}
+	return kind, name, nil
+}
+
+// ParseKind splits the kind of a device into its vendor, a domain name such
+// as "vendor.com", and its class, after checking them.
+func ParseKind(kind string) (vendor, class string, err error) {
+	vendor, class, ok := strings.Cut(kind, "/")
+	if !ok {
+		return "", "", fmt.Errorf("kind %q is not <vendor>/<class>", kind)
+	}
+	if !validIdentifier(vendor, "-.") {
+		return "", "", fmt.Errorf("invalid vendor %q", vendor)
+	}
+	if !validIdentifier(class, "-_") {
+		return "", "", fmt.Errorf("invalid class %q", class)
+	}
+	return vendor, class, nil
+}
+
+func checkName(name string) error {
+	if !validIdentifier(name, "-_.:") {
+		return fmt.Errorf("invalid device name %q", name)
+	}
+	return nil
+}
+
+// validIdentifier reports whether s starts with a letter or digit, ends
+// with one, and holds letters, digits and the characters of extra.
+func validIdentifier(s, extra string) bool {
+	if s == "" || !isAlnum(rune(s[len(s)-1])) || strings.ContainsRune(extra, rune(s[0])) {
+		return false
+	}
+	for _, c := range s {
+		if !isAlnum(c) && !strings.ContainsRune(extra, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
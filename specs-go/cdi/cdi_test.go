@@ -0,0 +1,114 @@
+package cdi
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestParseSpec(t *testing.T) {
+	mode := os.FileMode(0o660)
+	want := &Spec{
+		Version: "0.6.0",
+		Kind:    "vendor.com/gpu",
+		Devices: []Device{{
+			Name: "0",
+			ContainerEdits: ContainerEdits{
+				DeviceNodes: []DeviceNode{{Path: "/dev/gpu0", Type: "c", Major: 195, FileMode: &mode}},
+			},
+		}},
+		ContainerEdits: ContainerEdits{
+			Env:   []string{"GPU=1"},
+			Hooks: []Hook{{HookName: "createContainer", Hook: specs.Hook{Path: "/bin/hook", Args: []string{"hook", "create"}}}},
+		},
+	}
+	for _, tc := range []struct {
+		name, data string
+	}{
+		{
+			name: "json",
+			data: `{
+  "cdiVersion": "0.6.0",
+  "kind": "vendor.com/gpu",
+  "devices": [{"name": "0", "containerEdits": {"deviceNodes": [{"path": "/dev/gpu0", "type": "c", "major": 195, "fileMode": 432}]}}],
+  "containerEdits": {"env": ["GPU=1"], "hooks": [{"hookName": "createContainer", "path": "/bin/hook", "args": ["hook", "create"]}]}
+}`,
+		},
+		{
+			name: "yaml",
+			data: `cdiVersion: 0.6.0
+kind: vendor.com/gpu
+devices:
+- name: "0"
+  containerEdits:
+    deviceNodes:
+    - path: /dev/gpu0
+      type: c
+      major: 195
+      fileMode: 432
+containerEdits:
+  env:
+  - GPU=1
+  hooks:
+  - hookName: createContainer
+    path: /bin/hook
+    args: [hook, create]
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseSpec([]byte(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, data string
+	}{
+		{name: "syntax", data: "kind: [vendor.com/gpu"},
+		{name: "version", data: `{"kind": "vendor.com/gpu"}`},
+		{name: "kind", data: `{"cdiVersion": "0.6.0", "kind": "gpu"}`},
+		{name: "vendor", data: `{"cdiVersion": "0.6.0", "kind": "-vendor.com/gpu"}`},
+		{name: "device name", data: `{"cdiVersion": "0.6.0", "kind": "vendor.com/gpu", "devices": [{"name": "a b"}]}`},
+		{name: "duplicate device", data: `{"cdiVersion": "0.6.0", "kind": "vendor.com/gpu", "devices": [{"name": "0"}, {"name": "0"}]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if s, err := ParseSpec([]byte(tc.data)); err == nil {
+				t.Errorf("ParseSpec succeeded with %+v", s)
+			}
+		})
+	}
+}
+
+func TestParseQualifiedName(t *testing.T) {
+	for _, tc := range []struct {
+		qualified  string
+		kind, name string
+		ok         bool
+	}{
+		{"vendor.com/gpu=0", "vendor.com/gpu", "0", true},
+		{"example.org/net-dev=eth_0:1", "example.org/net-dev", "eth_0:1", true},
+		{"vendor.com/gpu", "", "", false},
+		{"vendor.com=0", "", "", false},
+		{"vendor.com/gpu=", "", "", false},
+		{"vendor.com/gpu=0.", "", "", false},
+		{"vendor.com/_gpu=0", "", "", false},
+	} {
+		kind, name, err := ParseQualifiedName(tc.qualified)
+		if (err == nil) != tc.ok || kind != tc.kind || name != tc.name {
+			t.Errorf("ParseQualifiedName(%q) = %q, %q, %v", tc.qualified, kind, name, err)
+		}
+		if tc.ok && QualifiedName(kind, name) != tc.qualified {
+			t.Errorf("QualifiedName(%q, %q) = %q", kind, name, QualifiedName(kind, name))
+		}
+	}
+}
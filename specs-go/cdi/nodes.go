@@ -0,0 +1,12 @@
+//go:build linux
+
+package cdi
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/devices"
+)
+
+func hostDevice(path string) (specs.LinuxDevice, error) {
+	return devices.FromPath(path)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cdi
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func hostDevice(path string) (specs.LinuxDevice, error) {
+	return specs.LinuxDevice{}, fmt.Errorf("%s: reading device nodes is only supported on Linux", path)
+}
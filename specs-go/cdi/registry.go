@@ -0,0 +1,113 @@
+package cdi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Registry holds the devices of a set of CDI specifications.
+type Registry struct {
+	devices map[string]entry
+}
+
+type entry struct {
+	spec   *Spec
+	device *Device
+	// path is the file of the specification.
+	path string
+}
+
+// Load reads the CDI specifications of dirs, given in increasing order of
+// priority, such as DefaultDirs: a device defined in several directories is
+// taken from the last one. Missing directories are skipped.
+//
+// Load returns the registry of the devices read along with the errors met,
+// so that invalid specifications only disable their own devices. A device
+// defined by several specifications of the same directory is a conflict,
+// and is left out of the registry.
+func Load(dirs ...string) (*Registry, error) {
+	r := &Registry{devices: make(map[string]entry)}
+	var errs []error
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		found := make(map[string]entry)
+		conflicts := make(map[string]bool)
+		for _, f := range files {
+			switch filepath.Ext(f.Name()) {
+			case ".json", ".yaml", ".yml":
+			default:
+				continue
+			}
+			if f.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			s, err := ParseSpec(data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			for i := range s.Devices {
+				name := QualifiedName(s.Kind, s.Devices[i].Name)
+				if prev, ok := found[name]; ok {
+					errs = append(errs, fmt.Errorf("device %q is defined by both %s and %s", name, prev.path, path))
+					conflicts[name] = true
+					continue
+				}
+				found[name] = entry{spec: s, device: &s.Devices[i], path: path}
+			}
+		}
+		for name, e := range found {
+			if !conflicts[name] {
+				r.devices[name] = e
+			}
+		}
+	}
+	return r, errors.Join(errs...)
+}
+
+// Add adds the devices of s to r, replacing the devices of the same name.
+func (r *Registry) Add(s *Spec) {
+	if r.devices == nil {
+		r.devices = make(map[string]entry)
+	}
+	for i := range s.Devices {
+		r.devices[QualifiedName(s.Kind, s.Devices[i].Name)] = entry{spec: s, device: &s.Devices[i]}
+	}
+}
+
+// Devices returns the qualified names of the devices of r, sorted.
+func (r *Registry) Devices() []string {
+	names := make([]string, 0, len(r.devices))
+	for name := range r.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the device of the qualified name, and its specification.
+func (r *Registry) Lookup(qualified string) (*Device, *Spec, error) {
+	if _, _, err := ParseQualifiedName(qualified); err != nil {
+		return nil, nil, err
+	}
+	e, ok := r.devices[qualified]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown CDI device %q", qualified)
+	}
+	return e.device, e.spec, nil
+}
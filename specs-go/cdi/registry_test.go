@@ -0,0 +1,97 @@
+package cdi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSpecs writes the files of specs, by name, to a new directory.
+func writeSpecs(t *testing.T, specs map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range specs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// specFile returns a CDI specification of kind vendor.com/gpu with the
+// devices of names, setting DEVICE to source.
+func specFile(source string, names ...string) string {
+	var devices []string
+	for _, name := range names {
+		devices = append(devices, `{"name": "`+name+`", "containerEdits": {"env": ["DEVICE=`+source+`"]}}`)
+	}
+	return `{"cdiVersion": "0.6.0", "kind": "vendor.com/gpu", "devices": [` + strings.Join(devices, ", ") + `]}`
+}
+
+func TestLoad(t *testing.T) {
+	low := writeSpecs(t, map[string]string{
+		"a.json": specFile("low", "0", "1"),
+		"b.yaml": "cdiVersion: 0.6.0\nkind: vendor.com/nic\ndevices:\n- name: eth0\n  containerEdits:\n    env: [DEVICE=low]\n",
+		"c.txt":  "not a specification",
+	})
+	high := writeSpecs(t, map[string]string{
+		"a.yml":  specFile("high", "1", "2"),
+		"b.json": specFile("high", "2"),
+		"c.json": "{",
+	})
+	r, err := Load(low, filepath.Join(low, "missing"), high)
+	if err == nil {
+		t.Fatal("Load succeeded with an invalid specification and a conflict")
+	}
+	for _, want := range []string{"c.json", `"vendor.com/gpu=2" is defined by both`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "c.txt") {
+		t.Errorf("error %q mentions a file which is not a specification", err)
+	}
+
+	if got, want := r.Devices(), []string{"vendor.com/gpu=0", "vendor.com/gpu=1", "vendor.com/nic=eth0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got devices %q, want %q", got, want)
+	}
+	for _, tc := range []struct {
+		name, source string
+	}{
+		{"vendor.com/gpu=0", "low"},
+		{"vendor.com/gpu=1", "high"},
+		{"vendor.com/nic=eth0", "low"},
+	} {
+		d, _, err := r.Lookup(tc.name)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tc.name, err)
+			continue
+		}
+		if got := d.ContainerEdits.Env; !reflect.DeepEqual(got, []string{"DEVICE=" + tc.source}) {
+			t.Errorf("%s: got %q, want the device of the %s directory", tc.name, got, tc.source)
+		}
+	}
+	for _, name := range []string{"vendor.com/gpu=2", "vendor.com/gpu=3", "gpu=0"} {
+		if _, _, err := r.Lookup(name); err == nil {
+			t.Errorf("Lookup(%q) succeeded", name)
+		}
+	}
+}
+
+func TestRegistryAdd(t *testing.T) {
+	var r Registry
+	s, err := ParseSpec([]byte(specFile("added", "0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Add(s)
+	d, got, err := r.Lookup("vendor.com/gpu=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != s || d != &s.Devices[0] {
+		t.Errorf("Lookup returned %p and %p, want %p and %p", d, got, &s.Devices[0], s)
+	}
+}
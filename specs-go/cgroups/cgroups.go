@@ -0,0 +1,75 @@
+// Package cgroups translates the Linux resources of a configuration into
+// the interface files of cgroup v2 and cgroup v1, and back.
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Setting is a value to write to an interface file of a cgroup.
+type Setting struct {
+	// Controller is the controller owning the file, such as "memory".
+	Controller string `json:"controller"`
+	// File is the name of the interface file, such as "memory.max".
+	File string `json:"file"`
+	// Value is written to the file as a whole. Files taking one entry per
+	// write, such as io.max, have a setting per entry.
+	Value string `json:"value"`
+}
+
+func (s Setting) String() string {
+	return s.File + "=" + s.Value
+}
+
+// renderer gathers the settings derived from the fields of a
+// configuration.
+type renderer struct {
+	settings []Setting
+	// paths holds the JSON path of the field each setting comes from.
+	paths []string
+	errs  []error
+	// byController sorts the settings by controller only, for files
+	// whose writes depend on each other, such as devices.allow and
+	// devices.deny.
+	byController bool
+}
+
+func (r *renderer) set(path, file, value string) {
+	controller, _, _ := strings.Cut(file, ".")
+	r.settings = append(r.settings, Setting{Controller: controller, File: file, Value: value})
+	r.paths = append(r.paths, path)
+}
+
+func (r *renderer) fail(path, format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+// result returns the settings sorted by file, or by controller, keeping
+// the order of the settings with the same key.
+func (r *renderer) result() ([]Setting, error) {
+	if len(r.errs) > 0 {
+		return nil, errors.Join(r.errs...)
+	}
+	sort.SliceStable(r.settings, func(i, j int) bool {
+		if r.byController {
+			return r.settings[i].Controller < r.settings[j].Controller
+		}
+		return r.settings[i].File < r.settings[j].File
+	})
+	return r.settings, nil
+}
+
+func device(major, minor int64) string {
+	return fmt.Sprintf("%d:%d", major, minor)
+}
+
+// limit formats a limit for which negative values mean no limit.
+func limit(v int64) string {
+	if v < 0 {
+		return "max"
+	}
+	return fmt.Sprint(v)
+}
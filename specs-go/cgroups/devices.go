@@ -0,0 +1,377 @@
+package cgroups
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// DeviceInstruction is an eBPF instruction, struct bpf_insn.
+type DeviceInstruction struct {
+	Code uint8
+	Dst  uint8
+	Src  uint8
+	Off  int16
+	Imm  int32
+}
+
+// eBPF opcodes used by device programs, from <linux/bpf.h>.
+const (
+	opLoadWord  = 0x61 // BPF_LDX | BPF_MEM | BPF_W
+	opAndImm    = 0x54 // BPF_ALU | BPF_AND | BPF_K
+	opRshImm    = 0x74 // BPF_ALU | BPF_RSH | BPF_K
+	opMovReg    = 0xbc // BPF_ALU | BPF_MOV | BPF_X
+	opMov64Imm  = 0xb7 // BPF_ALU64 | BPF_MOV | BPF_K
+	opJumpNEImm = 0x55 // BPF_JMP | BPF_JNE | BPF_K
+	opJumpNEReg = 0x5d // BPF_JMP | BPF_JNE | BPF_X
+	opJumpEQImm = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	opExit      = 0x95 // BPF_JMP | BPF_EXIT
+)
+
+// Device types and accesses of struct bpf_cgroup_dev_ctx.
+const (
+	devBlock    = 1 // BPF_DEVCG_DEV_BLOCK
+	devChar     = 2 // BPF_DEVCG_DEV_CHAR
+	accessMknod = 1 // BPF_DEVCG_ACC_MKNOD
+	accessRead  = 2 // BPF_DEVCG_ACC_READ
+	accessWrite = 4 // BPF_DEVCG_ACC_WRITE
+	accessAll   = accessMknod | accessRead | accessWrite
+)
+
+// devTypeAny and deviceAnyNode are the type and numbers of device rules
+// matching any device.
+const (
+	devTypeAny    = 0
+	deviceAnyNode = -1
+)
+
+// Registers holding the fields of struct bpf_cgroup_dev_ctx.
+const (
+	regType   = 2
+	regAccess = 3
+	regMajor  = 4
+	regMinor  = 5
+)
+
+// disasm disassembles ins, located at address pc.
+func (ins DeviceInstruction) disasm(pc int) string {
+	target := func() string { return fmt.Sprintf("%04d", pc+1+int(ins.Off)) }
+	switch ins.Code {
+	case opLoadWord:
+		return fmt.Sprintf("r%d = *(u32 *)(r%d + %d)", ins.Dst, ins.Src, ins.Off)
+	case opAndImm:
+		return fmt.Sprintf("w%d &= %#x", ins.Dst, uint32(ins.Imm))
+	case opRshImm:
+		return fmt.Sprintf("w%d >>= %d", ins.Dst, ins.Imm)
+	case opMovReg:
+		return fmt.Sprintf("w%d = w%d", ins.Dst, ins.Src)
+	case opMov64Imm:
+		return fmt.Sprintf("r%d = %d", ins.Dst, ins.Imm)
+	case opJumpNEImm:
+		return fmt.Sprintf("if r%d != %d goto %s", ins.Dst, ins.Imm, target())
+	case opJumpNEReg:
+		return fmt.Sprintf("if r%d != r%d goto %s", ins.Dst, ins.Src, target())
+	case opJumpEQImm:
+		return fmt.Sprintf("if r%d == %d goto %s", ins.Dst, ins.Imm, target())
+	case opExit:
+		return "exit"
+	}
+	return "unknown"
+}
+
+// DeviceProgram is an eBPF program of type BPF_PROG_TYPE_CGROUP_DEVICE,
+// to attach to a cgroup v2 directory with BPF_CGROUP_DEVICE.
+type DeviceProgram []DeviceInstruction
+
+// String returns the disassembly of p.
+func (p DeviceProgram) String() string {
+	var b strings.Builder
+	_ = p.Dump(&b)
+	return b.String()
+}
+
+// Dump writes the disassembly of p to w, one instruction per line.
+func (p DeviceProgram) Dump(w io.Writer) error {
+	for pc, ins := range p {
+		if _, err := fmt.Fprintf(w, " %04d: %s\n", pc, ins.disasm(pc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytes returns the array of struct bpf_insn of p, encoded with order,
+// which is the byte order of the kernel the program is loaded into.
+func (p DeviceProgram) Bytes(order binary.ByteOrder) []byte {
+	var probe [2]byte
+	order.PutUint16(probe[:], 1)
+	bigEndian := probe[0] == 0
+	b := make([]byte, 8*len(p))
+	for i, ins := range p {
+		b[8*i] = ins.Code
+		// The register fields are bit-fields, laid out from the least
+		// significant bit on little-endian kernels.
+		if bigEndian {
+			b[8*i+1] = ins.Dst<<4 | ins.Src&0xf
+		} else {
+			b[8*i+1] = ins.Src<<4 | ins.Dst&0xf
+		}
+		order.PutUint16(b[8*i+2:], uint16(ins.Off))
+		order.PutUint32(b[8*i+4:], uint32(ins.Imm))
+	}
+	return b
+}
+
+// deviceRule is a LinuxDeviceCgroup, checked and decoded.
+type deviceRule struct {
+	allow bool
+	typ   int32
+	// major and minor are deviceAnyNode for any number.
+	major, minor int64
+	access       int32
+}
+
+func (r deviceRule) matchesAll() bool {
+	return r.typ == devTypeAny && r.major == deviceAnyNode && r.minor == deviceAnyNode && r.access == accessAll
+}
+
+func parseDeviceRules(rules []specs.LinuxDeviceCgroup) ([]deviceRule, error) {
+	list := make([]deviceRule, 0, len(rules))
+	for i, d := range rules {
+		r := deviceRule{allow: d.Allow, major: deviceAnyNode, minor: deviceAnyNode}
+		switch d.Type {
+		case "", "a":
+			r.typ = devTypeAny
+		case "b":
+			r.typ = devBlock
+		case "c":
+			r.typ = devChar
+		default:
+			return nil, fmt.Errorf("linux.resources.devices[%d].type: unknown device type %q", i, d.Type)
+		}
+		for _, n := range []struct {
+			field string
+			v     *int64
+			dst   *int64
+		}{{"major", d.Major, &r.major}, {"minor", d.Minor, &r.minor}} {
+			if n.v == nil || *n.v < 0 {
+				continue
+			}
+			if *n.v > math.MaxInt32 {
+				return nil, fmt.Errorf("linux.resources.devices[%d].%s: %d is out of range", i, n.field, *n.v)
+			}
+			*n.dst = *n.v
+		}
+		access, err := parseDeviceAccess(d.Access)
+		if err != nil {
+			return nil, fmt.Errorf("linux.resources.devices[%d].access: %w", i, err)
+		}
+		if access == 0 {
+			access = accessAll
+		}
+		r.access = access
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+func parseDeviceAccess(s string) (int32, error) {
+	var access int32
+	for _, c := range s {
+		switch c {
+		case 'r':
+			access |= accessRead
+		case 'w':
+			access |= accessWrite
+		case 'm':
+			access |= accessMknod
+		default:
+			return 0, fmt.Errorf("unknown access %q in %q", c, s)
+		}
+	}
+	return access, nil
+}
+
+// CompileDevices compiles rules into a device program. As with the
+// devices.allow and devices.deny files of cgroup v1, later rules take
+// precedence over earlier ones, and accesses matching no rule are denied.
+// A rule matches an access when its type, major and minor numbers match
+// the device, and, for rules allowing access, its access includes all the
+// accesses requested, or, for rules denying access, any of them. Empty
+// types and access, and nil or negative numbers, match any.
+func CompileDevices(rules []specs.LinuxDeviceCgroup) (DeviceProgram, error) {
+	list, err := parseDeviceRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	p := DeviceProgram{
+		// The context, struct bpf_cgroup_dev_ctx, is in r1.
+		{Code: opLoadWord, Dst: regType, Src: 1, Off: 0},
+		{Code: opAndImm, Dst: regType, Imm: 0xffff},
+		{Code: opLoadWord, Dst: regAccess, Src: 1, Off: 0},
+		{Code: opRshImm, Dst: regAccess, Imm: 16},
+		{Code: opLoadWord, Dst: regMajor, Src: 1, Off: 4},
+		{Code: opLoadWord, Dst: regMinor, Src: 1, Off: 8},
+	}
+	final := false
+	for i := len(list) - 1; i >= 0; i-- {
+		r := list[i]
+		var block DeviceProgram
+		if r.typ != devTypeAny {
+			block = append(block, DeviceInstruction{Code: opJumpNEImm, Dst: regType, Imm: r.typ})
+		}
+		if r.access != accessAll {
+			block = append(block,
+				DeviceInstruction{Code: opMovReg, Dst: 1, Src: regAccess},
+				DeviceInstruction{Code: opAndImm, Dst: 1, Imm: r.access},
+			)
+			if r.allow {
+				block = append(block, DeviceInstruction{Code: opJumpNEReg, Dst: 1, Src: regAccess})
+			} else {
+				block = append(block, DeviceInstruction{Code: opJumpEQImm, Dst: 1, Imm: 0})
+			}
+		}
+		if r.major != deviceAnyNode {
+			block = append(block, DeviceInstruction{Code: opJumpNEImm, Dst: regMajor, Imm: int32(r.major)})
+		}
+		if r.minor != deviceAnyNode {
+			block = append(block, DeviceInstruction{Code: opJumpNEImm, Dst: regMinor, Imm: int32(r.minor)})
+		}
+		block = append(block, exitWith(r.allow)...)
+		// Failed matches skip to the next block.
+		for pc := range block {
+			switch block[pc].Code {
+			case opJumpNEImm, opJumpNEReg, opJumpEQImm:
+				block[pc].Off = int16(len(block) - pc - 1)
+			}
+		}
+		p = append(p, block...)
+		if r.matchesAll() {
+			// Earlier rules are unreachable.
+			final = true
+			break
+		}
+	}
+	if !final {
+		p = append(p, exitWith(false)...)
+	}
+	if len(p) > math.MaxInt16 {
+		return nil, fmt.Errorf("too many device rules")
+	}
+	return p, nil
+}
+
+func exitWith(allow bool) DeviceProgram {
+	var v int32
+	if allow {
+		v = 1
+	}
+	return DeviceProgram{{Code: opMov64Imm, Dst: 0, Imm: v}, {Code: opExit}}
+}
+
+// DeviceAccess is an access to a device node checked by a device program.
+type DeviceAccess struct {
+	// Type is "b" for block devices, and "c" for character devices.
+	Type  string
+	Major uint32
+	Minor uint32
+	// Access holds the accesses requested: "r", "w" and "m".
+	Access string
+}
+
+func (a DeviceAccess) context() (typ, access int32, err error) {
+	switch a.Type {
+	case "b":
+		typ = devBlock
+	case "c":
+		typ = devChar
+	default:
+		return 0, 0, fmt.Errorf("invalid device type %q", a.Type)
+	}
+	if access, err = parseDeviceAccess(a.Access); err != nil {
+		return 0, 0, err
+	}
+	if access == 0 {
+		return 0, 0, fmt.Errorf("no access requested")
+	}
+	return typ, access, nil
+}
+
+// DeviceAllowed reports whether rules allow a, as the program built by
+// CompileDevices does.
+func DeviceAllowed(rules []specs.LinuxDeviceCgroup, a DeviceAccess) (bool, error) {
+	list, err := parseDeviceRules(rules)
+	if err != nil {
+		return false, err
+	}
+	typ, access, err := a.context()
+	if err != nil {
+		return false, err
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		r := list[i]
+		matchesAccess := access&^r.access == 0
+		if !r.allow {
+			matchesAccess = access&r.access != 0
+		}
+		if (r.typ == devTypeAny || r.typ == typ) && matchesAccess &&
+			(r.major == deviceAnyNode || r.major == int64(a.Major)) &&
+			(r.minor == deviceAnyNode || r.minor == int64(a.Minor)) {
+			return r.allow, nil
+		}
+	}
+	return false, nil
+}
+
+// Run executes p on a, and reports whether p allows it. Only the
+// instructions emitted by CompileDevices are supported.
+func (p DeviceProgram) Run(a DeviceAccess) (bool, error) {
+	typ, access, err := a.context()
+	if err != nil {
+		return false, err
+	}
+	ctx := [3]uint32{uint32(access)<<16 | uint32(typ), a.Major, a.Minor}
+	var regs [11]uint64
+	for pc := 0; pc < len(p); pc++ {
+		ins := p[pc]
+		if ins.Dst >= uint8(len(regs)) || ins.Src >= uint8(len(regs)) {
+			return false, fmt.Errorf("%04d: invalid register", pc)
+		}
+		switch ins.Code {
+		case opLoadWord:
+			if ins.Src != 1 || ins.Off < 0 || ins.Off > 8 || ins.Off%4 != 0 {
+				return false, fmt.Errorf("%04d: invalid load", pc)
+			}
+			regs[ins.Dst] = uint64(ctx[ins.Off/4])
+		case opAndImm:
+			regs[ins.Dst] = uint64(uint32(regs[ins.Dst]) & uint32(ins.Imm))
+		case opRshImm:
+			regs[ins.Dst] = uint64(uint32(regs[ins.Dst]) >> uint32(ins.Imm))
+		case opMovReg:
+			regs[ins.Dst] = uint64(uint32(regs[ins.Src]))
+		case opMov64Imm:
+			regs[ins.Dst] = uint64(int64(ins.Imm))
+		case opJumpNEImm:
+			if regs[ins.Dst] != uint64(int64(ins.Imm)) {
+				pc += int(ins.Off)
+			}
+		case opJumpNEReg:
+			if regs[ins.Dst] != regs[ins.Src] {
+				pc += int(ins.Off)
+			}
+		case opJumpEQImm:
+			if regs[ins.Dst] == uint64(int64(ins.Imm)) {
+				pc += int(ins.Off)
+			}
+		case opExit:
+			return regs[0] != 0, nil
+		default:
+			return false, fmt.Errorf("%04d: unsupported opcode 0x%02x", pc, ins.Code)
+		}
+	}
+	return false, fmt.Errorf("program does not exit")
+}
@@ -0,0 +1,262 @@
+package cgroups
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestDeviceAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []specs.LinuxDeviceCgroup
+		a     DeviceAccess
+		want  bool
+	}{
+		{
+			name: "no rules",
+			a:    DeviceAccess{Type: "c", Major: 1, Minor: 3, Access: "r"},
+		},
+		{
+			name:  "allow all",
+			rules: []specs.LinuxDeviceCgroup{{Allow: true}},
+			a:     DeviceAccess{Type: "b", Major: 8, Minor: 0, Access: "rwm"},
+			want:  true,
+		},
+		{
+			name:  "allow with part of the access",
+			rules: []specs.LinuxDeviceCgroup{{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "r"}},
+			a:     DeviceAccess{Type: "c", Major: 1, Minor: 3, Access: "rw"},
+		},
+		{
+			name: "deny with part of the access",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "rwm"},
+				{Allow: false, Type: "c", Major: i64(1), Minor: i64(3), Access: "w"},
+			},
+			a: DeviceAccess{Type: "c", Major: 1, Minor: 3, Access: "rw"},
+		},
+		{
+			name: "deny without the access",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "rwm"},
+				{Allow: false, Type: "c", Major: i64(1), Minor: i64(3), Access: "w"},
+			},
+			a:    DeviceAccess{Type: "c", Major: 1, Minor: 3, Access: "rm"},
+			want: true,
+		},
+		{
+			name: "later rule overrides",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: false, Type: "b", Major: i64(8)},
+				{Allow: true, Type: "b", Major: i64(8), Minor: i64(0)},
+			},
+			a:    DeviceAccess{Type: "b", Major: 8, Minor: 0, Access: "r"},
+			want: true,
+		},
+		{
+			name: "earlier rule overridden",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "b", Major: i64(8), Minor: i64(0)},
+				{Allow: false, Type: "b", Major: i64(8)},
+			},
+			a: DeviceAccess{Type: "b", Major: 8, Minor: 0, Access: "r"},
+		},
+		{
+			name: "deny all cuts off earlier rules",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(3)},
+				{Allow: false, Type: "a", Access: "rwm"},
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(5)},
+			},
+			a: DeviceAccess{Type: "c", Major: 1, Minor: 3, Access: "r"},
+		},
+		{
+			name: "rules after deny all",
+			rules: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(3)},
+				{Allow: false, Type: "a", Access: "rwm"},
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(5)},
+			},
+			a:    DeviceAccess{Type: "c", Major: 1, Minor: 5, Access: "rwm"},
+			want: true,
+		},
+		{
+			name:  "type",
+			rules: []specs.LinuxDeviceCgroup{{Allow: true, Type: "b"}},
+			a:     DeviceAccess{Type: "c", Major: 8, Minor: 0, Access: "r"},
+		},
+		{
+			name:  "negative numbers match any",
+			rules: []specs.LinuxDeviceCgroup{{Allow: true, Type: "c", Major: i64(-1), Minor: i64(-1), Access: "m"}},
+			a:     DeviceAccess{Type: "c", Major: 136, Minor: 7, Access: "m"},
+			want:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DeviceAllowed(tc.rules, tc.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("DeviceAllowed = %v, want %v", got, tc.want)
+			}
+			p, err := CompileDevices(tc.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := p.Run(tc.a); err != nil || got != tc.want {
+				t.Errorf("Run = %v, %v, want %v\n%s", got, err, tc.want, p)
+			}
+		})
+	}
+}
+
+func TestCompileDevices(t *testing.T) {
+	p, err := CompileDevices([]specs.LinuxDeviceCgroup{
+		{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "rw"},
+		{Allow: false, Type: "c", Access: "w"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = ` 0000: r2 = *(u32 *)(r1 + 0)
+ 0001: w2 &= 0xffff
+ 0002: r3 = *(u32 *)(r1 + 0)
+ 0003: w3 >>= 16
+ 0004: r4 = *(u32 *)(r1 + 4)
+ 0005: r5 = *(u32 *)(r1 + 8)
+ 0006: if r2 != 2 goto 0012
+ 0007: w1 = w3
+ 0008: w1 &= 0x4
+ 0009: if r1 == 0 goto 0012
+ 0010: r0 = 0
+ 0011: exit
+ 0012: if r2 != 2 goto 0020
+ 0013: w1 = w3
+ 0014: w1 &= 0x6
+ 0015: if r1 != r3 goto 0020
+ 0016: if r4 != 1 goto 0020
+ 0017: if r5 != 3 goto 0020
+ 0018: r0 = 1
+ 0019: exit
+ 0020: r0 = 0
+ 0021: exit
+`
+	if got := p.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Rules before one matching all are dropped.
+	p, err = CompileDevices([]specs.LinuxDeviceCgroup{
+		{Allow: true, Type: "c", Major: i64(1), Minor: i64(3)},
+		{Allow: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 8 {
+		t.Errorf("got\n%s\nwant 8 instructions", p)
+	}
+}
+
+func TestCompileDevicesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rule specs.LinuxDeviceCgroup
+	}{
+		{name: "type", rule: specs.LinuxDeviceCgroup{Type: "u"}},
+		{name: "access", rule: specs.LinuxDeviceCgroup{Access: "x"}},
+		{name: "major", rule: specs.LinuxDeviceCgroup{Major: i64(1 << 32)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if p, err := CompileDevices([]specs.LinuxDeviceCgroup{tc.rule}); err == nil {
+				t.Errorf("CompileDevices succeeded with\n%s", p)
+			}
+		})
+	}
+	for _, a := range []DeviceAccess{
+		{Type: "a", Access: "r"},
+		{Type: "c", Access: ""},
+		{Type: "c", Access: "x"},
+	} {
+		if _, err := DeviceAllowed(nil, a); err == nil {
+			t.Errorf("DeviceAllowed accepted %+v", a)
+		}
+		if _, err := (DeviceProgram{}).Run(a); err == nil {
+			t.Errorf("Run accepted %+v", a)
+		}
+	}
+}
+
+// TestCompileDevicesRandom checks that the programs compiled from random
+// rules agree with DeviceAllowed.
+func TestCompileDevicesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	types := []string{"", "a", "b", "c"}
+	accesses := []string{"", "r", "w", "m", "rw", "wm", "rwm"}
+	for i := 0; i < 2000; i++ {
+		rules := make([]specs.LinuxDeviceCgroup, rng.Intn(6))
+		for j := range rules {
+			r := specs.LinuxDeviceCgroup{
+				Allow:  rng.Intn(2) == 0,
+				Type:   types[rng.Intn(len(types))],
+				Access: accesses[rng.Intn(len(accesses))],
+			}
+			if rng.Intn(2) == 0 {
+				r.Major = i64(int64(rng.Intn(3)))
+			}
+			if rng.Intn(2) == 0 {
+				r.Minor = i64(int64(rng.Intn(3)))
+			}
+			rules[j] = r
+		}
+		p, err := CompileDevices(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, typ := range []string{"b", "c"} {
+			for _, access := range accesses[1:] {
+				for major := uint32(0); major < 3; major++ {
+					for minor := uint32(0); minor < 3; minor++ {
+						a := DeviceAccess{Type: typ, Major: major, Minor: minor, Access: access}
+						want, err := DeviceAllowed(rules, a)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if got, err := p.Run(a); err != nil || got != want {
+							t.Fatalf("%+v: Run = %v, %v, want %v for %+v\n%s", a, got, err, want, rules, p)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDeviceProgramBytes(t *testing.T) {
+	p := DeviceProgram{
+		{Code: opLoadWord, Dst: 2, Src: 1, Off: 4},
+		{Code: opJumpNEImm, Dst: 4, Off: 3, Imm: 0x01020304},
+	}
+	for _, tc := range []struct {
+		order binary.ByteOrder
+		want  []byte
+	}{
+		{binary.LittleEndian, []byte{
+			0x61, 0x12, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x55, 0x04, 0x03, 0x00, 0x04, 0x03, 0x02, 0x01,
+		}},
+		{binary.BigEndian, []byte{
+			0x61, 0x21, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00,
+			0x55, 0x40, 0x00, 0x03, 0x01, 0x02, 0x03, 0x04,
+		}},
+	} {
+		if got := p.Bytes(tc.order); !bytes.Equal(got, tc.want) {
+			t.Errorf("%v: got % x, want % x", tc.order, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ReadV2 returns the resources of the cgroup v2 directory dir, from its
+// interface files. Files missing from dir, such as those of disabled
+// controllers, are skipped.
+//
+// CPU shares and block I/O weights are converted back from cpu.weight and
+// io.weight; rendering them with V2 gives back the weights read, when
+// those were written by V2. Devices are not read, and unified entries are
+// read by ReadUnified, except for a memory.swap.max limiting the swap of an
+// unlimited memory, which is read as a unified entry.
+func ReadV2(dir string) (*specs.LinuxResources, error) {
+	rd := &reader{path: func(file string) string { return filepath.Join(dir, file) }}
+	r := &specs.LinuxResources{}
+
+	var cpu specs.LinuxCPU
+	if v := rd.uint("cpu.weight"); v != nil {
+		shares := weightToCPUShares(*v)
+		cpu.Shares = &shares
+	}
+	if f := rd.fields("cpu.max"); len(f) == 2 {
+		quota := rd.parseInt("cpu.max", f[0])
+		period := rd.parseUint("cpu.max", f[1])
+		cpu.Quota, cpu.Period = &quota, &period
+	}
+	cpu.Burst = rd.uint("cpu.max.burst")
+	cpu.Idle = rd.int("cpu.idle")
+	cpu.Cpus, _ = rd.read("cpuset.cpus")
+	cpu.Mems, _ = rd.read("cpuset.mems")
+	if cpu != (specs.LinuxCPU{}) {
+		r.CPU = &cpu
+	}
+
+	var memory specs.LinuxMemory
+	memory.Limit = rd.int("memory.max")
+	memory.Reservation = rd.int("memory.low")
+	if s, ok := rd.read("memory.swap.max"); ok {
+		// memory.swap.max limits the swap alone.
+		swap := rd.parseInt("memory.swap.max", s)
+		switch {
+		case swap < 0:
+			memory.Swap = &swap
+		case memory.Limit != nil && *memory.Limit >= 0:
+			swap += *memory.Limit
+			memory.Swap = &swap
+		default:
+			// A swap limit without a memory limit has no typed
+			// equivalent.
+			r.Unified = map[string]string{"memory.swap.max": s}
+		}
+	}
+	if memory != (specs.LinuxMemory{}) {
+		r.Memory = &memory
+	}
+
+	if v := rd.int("pids.max"); v != nil {
+		r.Pids = &specs.LinuxPids{Limit: v}
+	}
+
+	var blockIO specs.LinuxBlockIO
+	for _, line := range rd.lines("io.weight") {
+		key, value, _ := strings.Cut(line, " ")
+		weight := weightToBlkioWeight(rd.parseUint("io.weight", value))
+		if key == "default" {
+			blockIO.Weight = &weight
+			continue
+		}
+		blockIO.WeightDevice = append(blockIO.WeightDevice, specs.LinuxWeightDevice{
+			LinuxBlockIODevice: rd.device("io.weight", key),
+			Weight:             &weight,
+		})
+	}
+	for _, line := range rd.lines("io.max") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		dev := rd.device("io.max", f[0])
+		for _, kv := range f[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			if value == "max" {
+				continue
+			}
+			td := specs.LinuxThrottleDevice{LinuxBlockIODevice: dev, Rate: rd.parseUint("io.max", value)}
+			switch key {
+			case "rbps":
+				blockIO.ThrottleReadBpsDevice = append(blockIO.ThrottleReadBpsDevice, td)
+			case "wbps":
+				blockIO.ThrottleWriteBpsDevice = append(blockIO.ThrottleWriteBpsDevice, td)
+			case "riops":
+				blockIO.ThrottleReadIOPSDevice = append(blockIO.ThrottleReadIOPSDevice, td)
+			case "wiops":
+				blockIO.ThrottleWriteIOPSDevice = append(blockIO.ThrottleWriteIOPSDevice, td)
+			}
+		}
+	}
+	if !emptyBlockIO(&blockIO) {
+		r.BlockIO = &blockIO
+	}
+
+	r.HugepageLimits = rd.hugepageLimits(dir, ".max")
+	r.Rdma = rd.rdma()
+	if rd.err != nil {
+		return nil, rd.err
+	}
+	return r, nil
+}
+
+// ReadUnified returns the content of the interface files keys of the cgroup
+// v2 directory dir, such as the keys of the unified entries of a
+// configuration, as unified entries. Files missing from dir are skipped.
+func ReadUnified(dir string, keys []string) (map[string]string, error) {
+	rd := &reader{path: func(file string) string { return filepath.Join(dir, file) }}
+	var unified map[string]string
+	for _, k := range keys {
+		if k == "" || strings.Contains(k, "/") || !strings.Contains(k, ".") {
+			return nil, fmt.Errorf("%q is not the name of an interface file", k)
+		}
+		if v, ok := rd.read(k); ok {
+			if unified == nil {
+				unified = make(map[string]string)
+			}
+			unified[k] = v
+		}
+	}
+	if rd.err != nil {
+		return nil, rd.err
+	}
+	return unified, nil
+}
+
+// ReadV1 returns the resources of the cgroup v1 path, relative to the
+// hierarchies mounted as directories named after their controller under
+// root, such as /sys/fs/cgroup/memory. Files missing, such as those of
+// hierarchies which are not mounted, are skipped. Limits read as the
+// largest value accepted by the kernel are returned as -1, and hugepage
+// limits equal to it are skipped. Devices are not read, since
+// devices.list holds the rules in effect rather than the rules written.
+func ReadV1(root, path string) (*specs.LinuxResources, error) {
+	rd := &reader{path: func(file string) string {
+		controller, _, _ := strings.Cut(file, ".")
+		return filepath.Join(root, controller, path, file)
+	}}
+	r := &specs.LinuxResources{}
+
+	var cpu specs.LinuxCPU
+	cpu.Shares = rd.uint("cpu.shares")
+	cpu.Quota = rd.int("cpu.cfs_quota_us")
+	cpu.Period = rd.uint("cpu.cfs_period_us")
+	cpu.Burst = rd.uint("cpu.cfs_burst_us")
+	cpu.RealtimeRuntime = rd.int("cpu.rt_runtime_us")
+	cpu.RealtimePeriod = rd.uint("cpu.rt_period_us")
+	cpu.Idle = rd.int("cpu.idle")
+	cpu.Cpus, _ = rd.read("cpuset.cpus")
+	cpu.Mems, _ = rd.read("cpuset.mems")
+	if cpu != (specs.LinuxCPU{}) {
+		r.CPU = &cpu
+	}
+
+	var memory specs.LinuxMemory
+	memory.Limit = rd.bytes("memory.limit_in_bytes")
+	memory.Swap = rd.bytes("memory.memsw.limit_in_bytes")
+	memory.Reservation = rd.bytes("memory.soft_limit_in_bytes")
+	memory.Kernel = rd.bytes("memory.kmem.limit_in_bytes")
+	memory.KernelTCP = rd.bytes("memory.kmem.tcp.limit_in_bytes")
+	memory.Swappiness = rd.uint("memory.swappiness")
+	for _, line := range rd.lines("memory.oom_control") {
+		if key, value, _ := strings.Cut(line, " "); key == "oom_kill_disable" {
+			disable := value == "1"
+			memory.DisableOOMKiller = &disable
+		}
+	}
+	if v := rd.uint("memory.use_hierarchy"); v != nil {
+		use := *v == 1
+		memory.UseHierarchy = &use
+	}
+	if memory != (specs.LinuxMemory{}) {
+		r.Memory = &memory
+	}
+
+	if v := rd.int("pids.max"); v != nil {
+		r.Pids = &specs.LinuxPids{Limit: v}
+	}
+
+	var blockIO specs.LinuxBlockIO
+	if v := rd.uint("blkio.weight"); v != nil {
+		weight := uint16(*v)
+		blockIO.Weight = &weight
+	}
+	if v := rd.uint("blkio.leaf_weight"); v != nil {
+		weight := uint16(*v)
+		blockIO.LeafWeight = &weight
+	}
+	weightDevices := make(map[specs.LinuxBlockIODevice]int)
+	for _, file := range []string{"blkio.weight_device", "blkio.leaf_weight_device"} {
+		for _, line := range rd.lines(file) {
+			key, value, _ := strings.Cut(line, " ")
+			dev := rd.device(file, key)
+			i, ok := weightDevices[dev]
+			if !ok {
+				i = len(blockIO.WeightDevice)
+				weightDevices[dev] = i
+				blockIO.WeightDevice = append(blockIO.WeightDevice, specs.LinuxWeightDevice{LinuxBlockIODevice: dev})
+			}
+			weight := uint16(rd.parseUint(file, value))
+			if file == "blkio.weight_device" {
+				blockIO.WeightDevice[i].Weight = &weight
+			} else {
+				blockIO.WeightDevice[i].LeafWeight = &weight
+			}
+		}
+	}
+	for _, t := range []struct {
+		file string
+		list *[]specs.LinuxThrottleDevice
+	}{
+		{"blkio.throttle.read_bps_device", &blockIO.ThrottleReadBpsDevice},
+		{"blkio.throttle.write_bps_device", &blockIO.ThrottleWriteBpsDevice},
+		{"blkio.throttle.read_iops_device", &blockIO.ThrottleReadIOPSDevice},
+		{"blkio.throttle.write_iops_device", &blockIO.ThrottleWriteIOPSDevice},
+	} {
+		for _, line := range rd.lines(t.file) {
+			key, value, _ := strings.Cut(line, " ")
+			*t.list = append(*t.list, specs.LinuxThrottleDevice{
+				LinuxBlockIODevice: rd.device(t.file, key),
+				Rate:               rd.parseUint(t.file, value),
+			})
+		}
+	}
+	if !emptyBlockIO(&blockIO) {
+		r.BlockIO = &blockIO
+	}
+
+	r.HugepageLimits = rd.hugepageLimits(filepath.Join(root, "hugetlb", path), ".limit_in_bytes")
+
+	var network specs.LinuxNetwork
+	if v := rd.uint("net_cls.classid"); v != nil {
+		classID := uint32(*v)
+		network.ClassID = &classID
+	}
+	for _, line := range rd.lines("net_prio.ifpriomap") {
+		name, value, _ := strings.Cut(line, " ")
+		network.Priorities = append(network.Priorities, specs.LinuxInterfacePriority{
+			Name:     name,
+			Priority: uint32(rd.parseUint("net_prio.ifpriomap", value)),
+		})
+	}
+	if network.ClassID != nil || len(network.Priorities) > 0 {
+		r.Network = &network
+	}
+
+	r.Rdma = rd.rdma()
+	if rd.err != nil {
+		return nil, rd.err
+	}
+	return r, nil
+}
+
+// Difference is an interface file whose value differs between two sets
+// of resources.
+type Difference struct {
+	// File is the name of the interface file.
+	File string `json:"file"`
+	// Got is the value of the file, empty when it is not set.
+	Got string `json:"got"`
+	// Want is the desired value of the file.
+	Want string `json:"want"`
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: got %q, want %q", d.File, d.Got, d.Want)
+}
+
+// Drift compares the resources got, read by ReadV2 or ReadV1, with the
+// desired resources want, once both are rendered by render, which is V2
+// or V1. Only the files set by want are compared, so that the defaults of
+// the files left unset do not count as drift. Files with an entry per
+// device, interface or page size are compared entry by entry.
+//
+// Devices are not compared, as they cannot be read back, nor are the
+// unified entries of want which are not in got: add the entries read by
+// ReadUnified to the Unified entries of got to compare them. Unified
+// entries are compared as written, so values the kernel reads back in
+// another form, such as "1G" for memory.max, are reported as differences.
+func Drift(render func(*specs.LinuxResources) ([]Setting, error), got, want *specs.LinuxResources) ([]Difference, error) {
+	if want == nil {
+		return nil, nil
+	}
+	w := *want
+	w.Devices = nil
+	w.Unified = nil
+	if got != nil {
+		for k, v := range want.Unified {
+			if _, ok := got.Unified[k]; ok {
+				if w.Unified == nil {
+					w.Unified = make(map[string]string)
+				}
+				w.Unified[k] = v
+			}
+		}
+	}
+	wantSettings, err := render(&w)
+	if err != nil {
+		return nil, fmt.Errorf("desired resources: %w", err)
+	}
+	var gotSettings []Setting
+	if got != nil {
+		g := *got
+		g.Devices = nil
+		if gotSettings, err = render(&g); err != nil {
+			return nil, fmt.Errorf("resources read: %w", err)
+		}
+	}
+	values := make(map[string]string, len(gotSettings))
+	for _, s := range gotSettings {
+		values[settingKey(s)] = s.Value
+	}
+	var diffs []Difference
+	for _, s := range wantSettings {
+		if v := values[settingKey(s)]; v != s.Value {
+			diffs = append(diffs, Difference{File: s.File, Got: v, Want: s.Value})
+		}
+	}
+	return diffs, nil
+}
+
+// entryFiles lists the files taking one entry per write, keyed by their
+// first field.
+var entryFiles = map[string]bool{
+	"io.max":                           true,
+	"io.weight":                        true,
+	"rdma.max":                         true,
+	"net_prio.ifpriomap":               true,
+	"blkio.weight_device":              true,
+	"blkio.leaf_weight_device":         true,
+	"blkio.throttle.read_bps_device":   true,
+	"blkio.throttle.write_bps_device":  true,
+	"blkio.throttle.read_iops_device":  true,
+	"blkio.throttle.write_iops_device": true,
+}
+
+func settingKey(s Setting) string {
+	if entryFiles[s.File] {
+		key, _, _ := strings.Cut(s.Value, " ")
+		return s.File + " " + key
+	}
+	return s.File
+}
+
+// weightToCPUShares is the inverse of CPUSharesToWeight, rounding up so
+// that converting the result back gives weight.
+func weightToCPUShares(weight uint64) uint64 {
+	if weight < 1 {
+		weight = 1
+	}
+	return 2 + ((weight-1)*262142+9998)/9999
+}
+
+// weightToBlkioWeight is the inverse of BlkioWeightToIOWeight, rounding up
+// so that converting the result back gives weight.
+func weightToBlkioWeight(weight uint64) uint16 {
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > 10000 {
+		weight = 10000
+	}
+	return uint16(10 + ((weight-1)*990+9998)/9999)
+}
+
+func emptyBlockIO(b *specs.LinuxBlockIO) bool {
+	return b.Weight == nil && b.LeafWeight == nil && len(b.WeightDevice) == 0 &&
+		len(b.ThrottleReadBpsDevice) == 0 && len(b.ThrottleWriteBpsDevice) == 0 &&
+		len(b.ThrottleReadIOPSDevice) == 0 && len(b.ThrottleWriteIOPSDevice) == 0
+}
+
+// unlimitedV1 is the lowest value cgroup v1 reads back for a limit of -1,
+// which is the largest int64 rounded down to the page size.
+const unlimitedV1 = math.MaxInt64 &^ (1<<16 - 1)
+
+// reader reads interface files, keeping the first error.
+type reader struct {
+	path func(file string) string
+	err  error
+}
+
+func (rd *reader) fail(file string, err error) {
+	if rd.err == nil {
+		rd.err = fmt.Errorf("%s: %w", file, err)
+	}
+}
+
+// read returns the trimmed content of file, and whether it exists.
+func (rd *reader) read(file string) (string, bool) {
+	b, err := os.ReadFile(rd.path(file))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			rd.fail(file, err)
+		}
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
+
+func (rd *reader) lines(file string) []string {
+	s, ok := rd.read(file)
+	if !ok || s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func (rd *reader) fields(file string) []string {
+	s, _ := rd.read(file)
+	return strings.Fields(s)
+}
+
+// parseInt parses a value for which "max" means no limit, returned as -1.
+func (rd *reader) parseInt(file, s string) int64 {
+	if s == "max" {
+		return -1
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		rd.fail(file, err)
+	}
+	return v
+}
+
+func (rd *reader) parseUint(file, s string) uint64 {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		rd.fail(file, err)
+	}
+	return v
+}
+
+func (rd *reader) int(file string) *int64 {
+	s, ok := rd.read(file)
+	if !ok {
+		return nil
+	}
+	v := rd.parseInt(file, s)
+	return &v
+}
+
+func (rd *reader) uint(file string) *uint64 {
+	s, ok := rd.read(file)
+	if !ok {
+		return nil
+	}
+	v := rd.parseUint(file, s)
+	return &v
+}
+
+// bytes reads a cgroup v1 limit in bytes.
+func (rd *reader) bytes(file string) *int64 {
+	v := rd.int(file)
+	if v != nil && *v >= unlimitedV1 {
+		*v = -1
+	}
+	return v
+}
+
+func (rd *reader) device(file, s string) specs.LinuxBlockIODevice {
+	major, minor, ok := strings.Cut(s, ":")
+	if !ok {
+		rd.fail(file, fmt.Errorf("invalid device %q", s))
+		return specs.LinuxBlockIODevice{}
+	}
+	return specs.LinuxBlockIODevice{
+		Major: rd.parseInt(file, major),
+		Minor: rd.parseInt(file, minor),
+	}
+}
+
+// hugepageLimits reads the hugetlb.<size><suffix> files of dir, skipping
+// the reservation limits and the limits which are not set.
+func (rd *reader) hugepageLimits(dir, suffix string) []specs.LinuxHugepageLimit {
+	matches, err := filepath.Glob(filepath.Join(dir, "hugetlb.*"+suffix))
+	if err != nil {
+		rd.fail("hugetlb", err)
+		return nil
+	}
+	var limits []specs.LinuxHugepageLimit
+	for _, m := range matches {
+		file := filepath.Base(m)
+		size := strings.TrimSuffix(strings.TrimPrefix(file, "hugetlb."), suffix)
+		if strings.Contains(size, ".") {
+			continue
+		}
+		s, ok := rd.read(file)
+		if !ok || s == "max" {
+			continue
+		}
+		v := rd.parseUint(file, s)
+		if v >= unlimitedV1 {
+			continue
+		}
+		limits = append(limits, specs.LinuxHugepageLimit{Pagesize: size, Limit: v})
+	}
+	return limits
+}
+
+func (rd *reader) rdma() map[string]specs.LinuxRdma {
+	var limits map[string]specs.LinuxRdma
+	for _, line := range rd.lines("rdma.max") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		var l specs.LinuxRdma
+		for _, kv := range f[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			if value == "max" {
+				continue
+			}
+			v := uint32(rd.parseUint("rdma.max", value))
+			switch key {
+			case "hca_handle":
+				l.HcaHandles = &v
+			case "hca_object":
+				l.HcaObjects = &v
+			}
+		}
+		if limits == nil {
+			limits = make(map[string]specs.LinuxRdma)
+		}
+		limits[f[0]] = l
+	}
+	return limits
+}
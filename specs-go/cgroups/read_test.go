@@ -0,0 +1,285 @@
+package cgroups
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// writeTree writes settings to the files returned by path, as the kernel
+// would read them back: the entries of a file are on lines of their own.
+func writeTree(t *testing.T, settings []Setting, path func(file string) string) {
+	t.Helper()
+	for _, s := range settings {
+		value := s.Value
+		if s.File == "memory.oom_control" {
+			value = "oom_kill_disable " + value + "\nunder_oom 0"
+		}
+		p := path(s.File)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(value + "\n"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func u32(v uint32) *uint32 { return &v }
+
+func TestReadV2(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    *specs.LinuxResources
+	}{
+		{
+			name: "cpu",
+			r: &specs.LinuxResources{CPU: &specs.LinuxCPU{
+				Shares: u64(1024),
+				Quota:  i64(50000),
+				Period: u64(200000),
+				Burst:  u64(1000),
+				Idle:   i64(0),
+				Cpus:   "0-3",
+				Mems:   "0",
+			}},
+		},
+		{
+			name: "memory",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(1 << 30), Swap: i64(2 << 30), Reservation: i64(1 << 20)}},
+		},
+		{
+			name: "unlimited memory",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1)}},
+		},
+		{
+			name: "unlimited memory with limited swap",
+			r: &specs.LinuxResources{
+				Memory:  &specs.LinuxMemory{Limit: i64(-1)},
+				Unified: map[string]string{"memory.swap.max": "0"},
+			},
+		},
+		{
+			name: "io",
+			r: &specs.LinuxResources{BlockIO: &specs.LinuxBlockIO{
+				Weight: u16(500),
+				WeightDevice: []specs.LinuxWeightDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Weight: u16(100)},
+				},
+				ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1000},
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 2000},
+				},
+				ThrottleWriteIOPSDevice: []specs.LinuxThrottleDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 10},
+				},
+			}},
+		},
+		{
+			name: "pids, hugetlb and rdma",
+			r: &specs.LinuxResources{
+				Pids:           &specs.LinuxPids{Limit: i64(100)},
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "1GB", Limit: 1 << 30}, {Pagesize: "2MB", Limit: 4 << 20}},
+				Rdma:           map[string]specs.LinuxRdma{"mlx5_0": {HcaHandles: u32(3)}, "mlx5_1": {HcaObjects: u32(100)}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			settings, err := V2(tc.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTree(t, settings, func(file string) string { return filepath.Join(dir, file) })
+			got, err := ReadV2(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffs, err := Drift(V2, got, tc.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diffs) != 0 {
+				t.Errorf("drift %v after a round trip", diffs)
+			}
+			again, err := V2(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(settingStrings(again), settingStrings(settings)) {
+				t.Errorf("read back %q, want %q", settingStrings(again), settingStrings(settings))
+			}
+		})
+	}
+}
+
+func TestReadV1(t *testing.T) {
+	root := t.TempDir()
+	const path = "/pod/ctr"
+	r := &specs.LinuxResources{
+		CPU: &specs.LinuxCPU{
+			Shares:          u64(512),
+			Quota:           i64(-1),
+			Period:          u64(100000),
+			RealtimeRuntime: i64(950000),
+			RealtimePeriod:  u64(1000000),
+			Cpus:            "1",
+		},
+		Memory: &specs.LinuxMemory{
+			Limit:            i64(1 << 30),
+			Swap:             i64(-1),
+			Reservation:      i64(1 << 20),
+			Swappiness:       u64(10),
+			DisableOOMKiller: boolPtr(true),
+			UseHierarchy:     boolPtr(true),
+		},
+		Pids: &specs.LinuxPids{Limit: i64(-1)},
+		BlockIO: &specs.LinuxBlockIO{
+			Weight:     u16(500),
+			LeafWeight: u16(300),
+			WeightDevice: []specs.LinuxWeightDevice{
+				{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Weight: u16(100), LeafWeight: u16(50)},
+			},
+			ThrottleWriteBpsDevice: []specs.LinuxThrottleDevice{
+				{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1000},
+			},
+		},
+		HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 4 << 20}},
+		Network: &specs.LinuxNetwork{
+			ClassID:    u32(0x100001),
+			Priorities: []specs.LinuxInterfacePriority{{Name: "eth0", Priority: 5}, {Name: "lo", Priority: 1}},
+		},
+	}
+	settings, err := V1(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, settings, func(file string) string {
+		controller, _, _ := strings.Cut(file, ".")
+		return filepath.Join(root, controller, path, file)
+	})
+	got, err := ReadV1(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("read %+v, want %+v", got, r)
+	}
+	diffs, err := Drift(V1, got, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("drift %v after a round trip", diffs)
+	}
+
+	// The kernel reads unlimited limits back as the largest multiple of
+	// the page size.
+	unlimited := filepath.Join(root, "memory", path, "memory.memsw.limit_in_bytes")
+	if err := os.WriteFile(unlimited, []byte("9223372036854771712\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadV1(root, path); err != nil || *got.Memory.Swap != -1 {
+		t.Errorf("unlimited swap read as %v, %v", got.Memory.Swap, err)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	dir := t.TempDir()
+	want := &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: i64(100)},
+		Pids:   &specs.LinuxPids{Limit: i64(10)},
+		BlockIO: &specs.LinuxBlockIO{ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+			{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1000},
+			{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 2000},
+		}},
+		Unified: map[string]string{"memory.high": "90", "memory.oom.group": "1"},
+		Devices: []specs.LinuxDeviceCgroup{{Allow: false, Access: "rwm"}},
+	}
+	writeTree(t, []Setting{
+		{File: "memory.max", Value: "200"},
+		{File: "memory.high", Value: "max"},
+		{File: "memory.swap.max", Value: "max"},
+		{File: "io.max", Value: "8:0 rbps=1000 wbps=max riops=max wiops=max"},
+		{File: "cpu.weight", Value: "100"},
+	}, func(file string) string { return filepath.Join(dir, file) })
+
+	got, err := ReadV2(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(want.Unified))
+	for k := range want.Unified {
+		keys = append(keys, k)
+	}
+	if got.Unified, err = ReadUnified(dir, keys); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := Drift(V2, got, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDiffs := []Difference{
+		{File: "io.max", Want: "8:16 rbps=2000"},
+		{File: "memory.high", Got: "max", Want: "90"},
+		{File: "memory.max", Got: "200", Want: "100"},
+		{File: "pids.max", Want: "10"},
+	}
+	if !reflect.DeepEqual(diffs, wantDiffs) {
+		t.Errorf("got %v, want %v", diffs, wantDiffs)
+	}
+
+	// The swap limit of an unlimited memory is compared, although it has
+	// no typed equivalent.
+	swapDir := t.TempDir()
+	writeTree(t, []Setting{
+		{File: "memory.max", Value: "max"},
+		{File: "memory.swap.max", Value: "0"},
+	}, func(file string) string { return filepath.Join(swapDir, file) })
+	if got, err = ReadV2(swapDir); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = Drift(V2, got, &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Difference{{File: "memory.swap.max", Got: "0", Want: "max"}}; !reflect.DeepEqual(diffs, want) {
+		t.Errorf("got %v, want %v", diffs, want)
+	}
+
+	if _, err := ReadUnified(dir, []string{"../memory.max"}); err == nil {
+		t.Error("ReadUnified accepted a path")
+	}
+	if diffs, err := Drift(V2, nil, nil); err != nil || diffs != nil {
+		t.Errorf("Drift(nil, nil) = %v, %v", diffs, err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		file, content string
+	}{
+		{"memory.max", "lots"},
+		{"io.max", "8-0 rbps=1"},
+		{"cpu.max", "max x"},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, tc.file), []byte(tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if r, err := ReadV2(dir); err == nil {
+			t.Errorf("ReadV2 read %s %q as %+v", tc.file, tc.content, r)
+		}
+	}
+}
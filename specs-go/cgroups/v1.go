@@ -0,0 +1,246 @@
+package cgroups
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// V1 returns the writes to the cgroup v1 interface files for r. Settings
+// are sorted by controller, and keep their order within a controller:
+// device rules are written to devices.allow and devices.deny in the order
+// of r.Devices.
+//
+// r.Unified is an error, as it only applies to cgroup v2. The kernel keeps
+// the memory limit below the memory and swap limit, so the order of
+// memory.limit_in_bytes and memory.memsw.limit_in_bytes matters: V1 orders
+// them for a new cgroup, whose limits are unlimited. Use V1Update to update
+// the limits of an existing cgroup.
+func V1(r *specs.LinuxResources) ([]Setting, error) {
+	return V1Update(r, nil)
+}
+
+// V1Update is like V1, but orders the writes to update a cgroup whose
+// current resources, as returned by ReadV1, are current. As runc does, the
+// memory and swap limit is written before the memory limit when it is
+// unlimited or above the current memory limit, and after it otherwise.
+func V1Update(r, current *specs.LinuxResources) ([]Setting, error) {
+	if r == nil {
+		return nil, nil
+	}
+	rd := &renderer{byController: true}
+	rd.devicesV1(r.Devices)
+	rd.cpuV1(r.CPU)
+	var currentLimit *int64
+	if current != nil && current.Memory != nil {
+		currentLimit = current.Memory.Limit
+	}
+	rd.memoryV1(r.Memory, currentLimit)
+	if r.Pids != nil && r.Pids.Limit != nil {
+		rd.set("linux.resources.pids.limit", "pids.max", limit(*r.Pids.Limit))
+	}
+	rd.blkioV1(r.BlockIO)
+	for i, h := range r.HugepageLimits {
+		if h.Pagesize == "" {
+			rd.fail(fmt.Sprintf("linux.resources.hugepageLimits[%d].pageSize", i), "page size is empty")
+			continue
+		}
+		rd.set(fmt.Sprintf("linux.resources.hugepageLimits[%d]", i), "hugetlb."+h.Pagesize+".limit_in_bytes", fmt.Sprint(h.Limit))
+	}
+	if n := r.Network; n != nil {
+		if n.ClassID != nil {
+			rd.set("linux.resources.network.classID", "net_cls.classid", fmt.Sprint(*n.ClassID))
+		}
+		for i, p := range n.Priorities {
+			rd.set(fmt.Sprintf("linux.resources.network.priorities[%d]", i), "net_prio.ifpriomap", fmt.Sprintf("%s %d", p.Name, p.Priority))
+		}
+	}
+	rd.rdma(r.Rdma)
+	if len(r.Unified) > 0 {
+		rd.fail("linux.resources.unified", "only applies to cgroup v2")
+	}
+	return rd.result()
+}
+
+func (rd *renderer) devicesV1(devices []specs.LinuxDeviceCgroup) {
+	for i, d := range devices {
+		path := fmt.Sprintf("linux.resources.devices[%d]", i)
+		typ := d.Type
+		switch typ {
+		case "":
+			typ = "a"
+		case "a", "b", "c":
+		default:
+			rd.fail(path+".type", "unknown device type %q", d.Type)
+			continue
+		}
+		access := d.Access
+		if access == "" {
+			access = "rwm"
+		}
+		file := "devices.deny"
+		if d.Allow {
+			file = "devices.allow"
+		}
+		rd.set(path, file, fmt.Sprintf("%s %s:%s %s", typ, deviceNumber(d.Major), deviceNumber(d.Minor), access))
+	}
+}
+
+// deviceNumber formats a device number of a device rule, for which nil
+// matches any number.
+func deviceNumber(n *int64) string {
+	if n == nil || *n < 0 {
+		return "*"
+	}
+	return fmt.Sprint(*n)
+}
+
+func (rd *renderer) cpuV1(c *specs.LinuxCPU) {
+	if c == nil {
+		return
+	}
+	const path = "linux.resources.cpu"
+	if c.Shares != nil && *c.Shares != 0 {
+		rd.set(path+".shares", "cpu.shares", fmt.Sprint(*c.Shares))
+	}
+	if c.Period != nil && *c.Period != 0 {
+		rd.set(path+".period", "cpu.cfs_period_us", fmt.Sprint(*c.Period))
+	}
+	if c.Quota != nil && *c.Quota != 0 {
+		quota := *c.Quota
+		if quota < 0 {
+			quota = -1
+		}
+		rd.set(path+".quota", "cpu.cfs_quota_us", fmt.Sprint(quota))
+	}
+	if c.Burst != nil {
+		rd.set(path+".burst", "cpu.cfs_burst_us", fmt.Sprint(*c.Burst))
+	}
+	if c.RealtimePeriod != nil && *c.RealtimePeriod != 0 {
+		rd.set(path+".realtimePeriod", "cpu.rt_period_us", fmt.Sprint(*c.RealtimePeriod))
+	}
+	if c.RealtimeRuntime != nil && *c.RealtimeRuntime != 0 {
+		rd.set(path+".realtimeRuntime", "cpu.rt_runtime_us", fmt.Sprint(*c.RealtimeRuntime))
+	}
+	if c.Idle != nil {
+		rd.set(path+".idle", "cpu.idle", fmt.Sprint(*c.Idle))
+	}
+	if c.Cpus != "" {
+		rd.set(path+".cpus", "cpuset.cpus", c.Cpus)
+	}
+	if c.Mems != "" {
+		rd.set(path+".mems", "cpuset.mems", c.Mems)
+	}
+}
+
+// memoryV1 adds the memory settings of m. currentLimit is the current
+// memory limit of the cgroup, nil or negative when unlimited.
+func (rd *renderer) memoryV1(m *specs.LinuxMemory, currentLimit *int64) {
+	if m == nil {
+		return
+	}
+	const path = "linux.resources.memory"
+	// Negative limits are written as -1, which the kernel reads as no
+	// limit.
+	bytes := func(v int64) string {
+		if v < 0 {
+			v = -1
+		}
+		return fmt.Sprint(v)
+	}
+	var memory, swap int64
+	if m.Limit != nil {
+		memory = *m.Limit
+	}
+	if m.Swap != nil {
+		swap = *m.Swap
+	}
+	swapPath := path + ".swap"
+	if memory < 0 && swap == 0 {
+		// An unlimited memory without a swap limit leaves the swap
+		// unlimited, as with runc.
+		swap = -1
+		swapPath = path + ".limit"
+	}
+	switch {
+	case swap > 0 && memory < 0:
+		rd.fail(swapPath, "cannot be limited when memory is unlimited")
+		swap = 0
+	case swap > 0 && memory > 0 && swap < memory:
+		rd.fail(swapPath, "%d is lower than the memory limit %d", swap, memory)
+		swap = 0
+	}
+	// Raising the memory and swap limit above the current memory limit
+	// must come first, and lowering it must come last.
+	swapFirst := swap < 0 || (currentLimit != nil && *currentLimit >= 0 && *currentLimit < swap)
+	if swap != 0 && swapFirst {
+		rd.set(swapPath, "memory.memsw.limit_in_bytes", bytes(swap))
+	}
+	if memory != 0 {
+		rd.set(path+".limit", "memory.limit_in_bytes", bytes(memory))
+	}
+	if swap != 0 && !swapFirst {
+		rd.set(swapPath, "memory.memsw.limit_in_bytes", bytes(swap))
+	}
+	if m.Reservation != nil && *m.Reservation != 0 {
+		rd.set(path+".reservation", "memory.soft_limit_in_bytes", bytes(*m.Reservation))
+	}
+	if m.Kernel != nil && *m.Kernel != 0 {
+		rd.set(path+".kernel", "memory.kmem.limit_in_bytes", bytes(*m.Kernel))
+	}
+	if m.KernelTCP != nil && *m.KernelTCP != 0 {
+		rd.set(path+".kernelTCP", "memory.kmem.tcp.limit_in_bytes", bytes(*m.KernelTCP))
+	}
+	if m.Swappiness != nil {
+		if *m.Swappiness > 100 {
+			rd.fail(path+".swappiness", "%d is out of the range 0-100", *m.Swappiness)
+		} else {
+			rd.set(path+".swappiness", "memory.swappiness", fmt.Sprint(*m.Swappiness))
+		}
+	}
+	if m.DisableOOMKiller != nil && *m.DisableOOMKiller {
+		rd.set(path+".disableOOMKiller", "memory.oom_control", "1")
+	}
+	if m.UseHierarchy != nil {
+		v := "0"
+		if *m.UseHierarchy {
+			v = "1"
+		}
+		rd.set(path+".useHierarchy", "memory.use_hierarchy", v)
+	}
+}
+
+func (rd *renderer) blkioV1(b *specs.LinuxBlockIO) {
+	if b == nil {
+		return
+	}
+	const path = "linux.resources.blockIO"
+	if b.Weight != nil {
+		rd.set(path+".weight", "blkio.weight", fmt.Sprint(*b.Weight))
+	}
+	if b.LeafWeight != nil {
+		rd.set(path+".leafWeight", "blkio.leaf_weight", fmt.Sprint(*b.LeafWeight))
+	}
+	for i, wd := range b.WeightDevice {
+		p := fmt.Sprintf("%s.weightDevice[%d]", path, i)
+		if wd.Weight != nil {
+			rd.set(p, "blkio.weight_device", fmt.Sprintf("%s %d", device(wd.Major, wd.Minor), *wd.Weight))
+		}
+		if wd.LeafWeight != nil {
+			rd.set(p, "blkio.leaf_weight_device", fmt.Sprintf("%s %d", device(wd.Major, wd.Minor), *wd.LeafWeight))
+		}
+	}
+	for _, t := range []struct {
+		field, file string
+		list        []specs.LinuxThrottleDevice
+	}{
+		{"throttleReadBpsDevice", "blkio.throttle.read_bps_device", b.ThrottleReadBpsDevice},
+		{"throttleWriteBpsDevice", "blkio.throttle.write_bps_device", b.ThrottleWriteBpsDevice},
+		{"throttleReadIOPSDevice", "blkio.throttle.read_iops_device", b.ThrottleReadIOPSDevice},
+		{"throttleWriteIOPSDevice", "blkio.throttle.write_iops_device", b.ThrottleWriteIOPSDevice},
+	} {
+		for i, td := range t.list {
+			rd.set(fmt.Sprintf("%s.%s[%d]", path, t.field, i), t.file, fmt.Sprintf("%s %d", device(td.Major, td.Minor), td.Rate))
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package cgroups
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestV1(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    *specs.LinuxResources
+		want []string
+	}{
+		{name: "nil"},
+		{
+			name: "devices in order",
+			r: &specs.LinuxResources{Devices: []specs.LinuxDeviceCgroup{
+				{Allow: false, Access: "rwm"},
+				{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "rw"},
+				{Allow: false, Type: "b", Major: i64(8)},
+			}},
+			want: []string{"devices.deny=a *:* rwm", "devices.allow=c 1:3 rw", "devices.deny=b 8:* rwm"},
+		},
+		{
+			name: "cpu",
+			r: &specs.LinuxResources{CPU: &specs.LinuxCPU{
+				Shares:          u64(512),
+				Quota:           i64(-5),
+				Period:          u64(100000),
+				RealtimeRuntime: i64(950000),
+				Cpus:            "1",
+			}},
+			want: []string{"cpu.shares=512", "cpu.cfs_period_us=100000", "cpu.cfs_quota_us=-1", "cpu.rt_runtime_us=950000", "cpuset.cpus=1"},
+		},
+		{
+			name: "memory and swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(300), Reservation: i64(50), Swappiness: u64(10)}},
+			want: []string{"memory.limit_in_bytes=100", "memory.memsw.limit_in_bytes=300", "memory.soft_limit_in_bytes=50", "memory.swappiness=10"},
+		},
+		{
+			name: "unlimited swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(-1)}},
+			want: []string{"memory.memsw.limit_in_bytes=-1", "memory.limit_in_bytes=100"},
+		},
+		{
+			name: "unlimited memory",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-2)}},
+			want: []string{"memory.memsw.limit_in_bytes=-1", "memory.limit_in_bytes=-1"},
+		},
+		{
+			name: "unlimited memory with zero swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1), Swap: i64(0)}},
+			want: []string{"memory.memsw.limit_in_bytes=-1", "memory.limit_in_bytes=-1"},
+		},
+		{
+			name: "blkio and network",
+			r: &specs.LinuxResources{
+				BlockIO: &specs.LinuxBlockIO{
+					Weight: u16(500),
+					ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+						{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 1000},
+					},
+				},
+				Network: &specs.LinuxNetwork{
+					ClassID:    func() *uint32 { v := uint32(0x100001); return &v }(),
+					Priorities: []specs.LinuxInterfacePriority{{Name: "eth0", Priority: 5}},
+				},
+				Pids: &specs.LinuxPids{Limit: i64(10)},
+			},
+			want: []string{"blkio.weight=500", "blkio.throttle.read_bps_device=8:0 1000", "net_cls.classid=1048577", "net_prio.ifpriomap=eth0 5", "pids.max=10"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := V1(tc.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := settingStrings(settings); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestV1Update(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		current, limit, swap int64
+		want                 []string
+	}{
+		{
+			name:    "raise",
+			current: 100, limit: 200, swap: 400,
+			want: []string{"memory.memsw.limit_in_bytes=400", "memory.limit_in_bytes=200"},
+		},
+		{
+			name:    "lower",
+			current: 400, limit: 100, swap: 200,
+			want: []string{"memory.limit_in_bytes=100", "memory.memsw.limit_in_bytes=200"},
+		},
+		{
+			name:    "from unlimited",
+			current: -1, limit: 100, swap: 200,
+			want: []string{"memory.limit_in_bytes=100", "memory.memsw.limit_in_bytes=200"},
+		},
+		{
+			name:    "to unlimited",
+			current: 100, limit: -1,
+			want: []string{"memory.memsw.limit_in_bytes=-1", "memory.limit_in_bytes=-1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(tc.limit), Swap: i64(tc.swap)}}
+			current := &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(tc.current)}}
+			settings, err := V1Update(r, current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := settingStrings(settings)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if !kernelAccepts(tc.current, tc.limit, tc.swap, settings) {
+				t.Errorf("the kernel rejects %q from the memory limit %d", got, tc.current)
+			}
+		})
+	}
+}
+
+// kernelAccepts reports whether the kernel accepts the memory writes of
+// settings in order, starting from the memory limit current, with the
+// memory and swap limit at least as high, and reaching limit and swap.
+func kernelAccepts(current, limit, swap int64, settings []Setting) bool {
+	const unlimited = 1 << 62
+	value := func(v int64) int64 {
+		if v < 0 {
+			return unlimited
+		}
+		return v
+	}
+	mem, memsw := value(current), value(current)
+	if limit < 0 && swap == 0 {
+		swap = -1
+	}
+	for _, s := range settings {
+		v, err := strconv.ParseInt(s.Value, 10, 64)
+		if err != nil {
+			return false
+		}
+		v = value(v)
+		switch s.File {
+		case "memory.limit_in_bytes":
+			if v > memsw {
+				return false
+			}
+			mem = v
+		case "memory.memsw.limit_in_bytes":
+			if v < mem {
+				return false
+			}
+			memsw = v
+		}
+	}
+	return mem == value(limit) && memsw == value(swap)
+}
+
+func TestV1Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    *specs.LinuxResources
+	}{
+		{name: "device type", r: &specs.LinuxResources{Devices: []specs.LinuxDeviceCgroup{{Type: "u"}}}},
+		{name: "swap below limit", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(50)}}},
+		{name: "swap with unlimited memory", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1), Swap: i64(50)}}},
+		{name: "swappiness", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Swappiness: u64(101)}}},
+		{name: "page size", r: &specs.LinuxResources{HugepageLimits: []specs.LinuxHugepageLimit{{Limit: 1}}}},
+		{name: "unified", r: &specs.LinuxResources{Unified: map[string]string{"memory.high": "1"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if settings, err := V1(tc.r); err == nil {
+				t.Errorf("V1 succeeded with %q", settingStrings(settings))
+			}
+		})
+	}
+}
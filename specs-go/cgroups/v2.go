@@ -0,0 +1,262 @@
+package cgroups
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// CPUSharesToWeight converts cgroup v1 CPU shares, from 2 to 262144, to a
+// cgroup v2 cpu.weight, from 1 to 10000, as runc and crun do.
+func CPUSharesToWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// BlkioWeightToIOWeight converts a cgroup v1 blkio weight, from 10 to 1000,
+// to a cgroup v2 io.weight, from 1 to 10000, as runc and crun do.
+func BlkioWeightToIOWeight(weight uint16) uint64 {
+	if weight < 10 {
+		weight = 10
+	}
+	return 1 + (uint64(weight)-10)*9999/990
+}
+
+// V2 returns the settings of the cgroup v2 interface files for r, merged
+// with r.Unified. Settings are sorted by file.
+//
+// Fields without a cgroup v2 equivalent are an error, except for
+// memory.kernel, which is deprecated and ignored, and for devices, which
+// are enforced with the eBPF program built by CompileDevices. HugeTLB
+// limits are written to hugetlb.<size>.max; runtimes may also write them
+// to the reservation limits. Unified entries which set a file also set by
+// a typed field to another value are reported as conflicts; a unified
+// memory.swap.max replaces the unlimited swap of an unlimited memory.
+func V2(r *specs.LinuxResources) ([]Setting, error) {
+	if r == nil {
+		return nil, nil
+	}
+	rd := &renderer{}
+	rd.cpuV2(r.CPU)
+	rd.memoryV2(r.Memory, r.Unified)
+	if r.Pids != nil && r.Pids.Limit != nil {
+		rd.set("linux.resources.pids.limit", "pids.max", limit(*r.Pids.Limit))
+	}
+	rd.ioV2(r.BlockIO)
+	for i, h := range r.HugepageLimits {
+		if h.Pagesize == "" {
+			rd.fail(fmt.Sprintf("linux.resources.hugepageLimits[%d].pageSize", i), "page size is empty")
+			continue
+		}
+		rd.set(fmt.Sprintf("linux.resources.hugepageLimits[%d]", i), "hugetlb."+h.Pagesize+".max", fmt.Sprint(h.Limit))
+	}
+	if r.Network != nil && (r.Network.ClassID != nil || len(r.Network.Priorities) > 0) {
+		rd.fail("linux.resources.network", "net_cls and net_prio have no cgroup v2 equivalent")
+	}
+	rd.rdma(r.Rdma)
+	rd.unified(r.Unified)
+	return rd.result()
+}
+
+func (rd *renderer) cpuV2(c *specs.LinuxCPU) {
+	if c == nil {
+		return
+	}
+	const path = "linux.resources.cpu"
+	if c.Shares != nil && *c.Shares != 0 {
+		if *c.Shares < 2 || *c.Shares > 262144 {
+			rd.fail(path+".shares", "%d is out of the range 2-262144", *c.Shares)
+		} else {
+			rd.set(path+".shares", "cpu.weight", fmt.Sprint(CPUSharesToWeight(*c.Shares)))
+		}
+	}
+	// A zero quota and period leave cpu.max unchanged, as with runc.
+	if (c.Quota != nil && *c.Quota != 0) || (c.Period != nil && *c.Period != 0) {
+		quota := "max"
+		if c.Quota != nil && *c.Quota > 0 {
+			quota = fmt.Sprint(*c.Quota)
+		}
+		period := uint64(100000)
+		if c.Period != nil && *c.Period != 0 {
+			period = *c.Period
+		}
+		rd.set(path+".quota", "cpu.max", fmt.Sprintf("%s %d", quota, period))
+	}
+	if c.Burst != nil {
+		rd.set(path+".burst", "cpu.max.burst", fmt.Sprint(*c.Burst))
+	}
+	if c.Idle != nil {
+		rd.set(path+".idle", "cpu.idle", fmt.Sprint(*c.Idle))
+	}
+	if c.RealtimeRuntime != nil || c.RealtimePeriod != nil {
+		rd.fail(path, "realtime scheduling has no cgroup v2 equivalent")
+	}
+	if c.Cpus != "" {
+		rd.set(path+".cpus", "cpuset.cpus", c.Cpus)
+	}
+	if c.Mems != "" {
+		rd.set(path+".mems", "cpuset.mems", c.Mems)
+	}
+}
+
+func (rd *renderer) memoryV2(m *specs.LinuxMemory, unified map[string]string) {
+	if m == nil {
+		return
+	}
+	const path = "linux.resources.memory"
+	if m.Limit != nil && *m.Limit != 0 {
+		rd.set(path+".limit", "memory.max", limit(*m.Limit))
+	}
+	if m.Reservation != nil && *m.Reservation != 0 {
+		rd.set(path+".reservation", "memory.low", limit(*m.Reservation))
+	}
+	if m.Swap != nil && *m.Swap != 0 {
+		// memory.swap.max limits the swap alone, while swap limits memory
+		// and swap together.
+		switch {
+		case *m.Swap < 0:
+			rd.set(path+".swap", "memory.swap.max", "max")
+		case m.Limit == nil || *m.Limit == 0:
+			rd.fail(path+".swap", "cannot be set without a memory limit")
+		case *m.Limit < 0:
+			rd.fail(path+".swap", "cannot be limited when memory is unlimited")
+		case *m.Swap < *m.Limit:
+			rd.fail(path+".swap", "%d is lower than the memory limit %d", *m.Swap, *m.Limit)
+		default:
+			rd.set(path+".swap", "memory.swap.max", fmt.Sprint(*m.Swap-*m.Limit))
+		}
+	} else if _, ok := unified["memory.swap.max"]; !ok && m.Limit != nil && *m.Limit < 0 {
+		// An unlimited memory without a swap limit, or with a swap limit
+		// of zero, leaves the swap unlimited, as with cgroup v1, unless
+		// a unified entry limits it.
+		rd.set(path+".limit", "memory.swap.max", "max")
+	}
+	if m.KernelTCP != nil {
+		rd.fail(path+".kernelTCP", "has no cgroup v2 equivalent")
+	}
+	if m.Swappiness != nil {
+		rd.fail(path+".swappiness", "has no cgroup v2 equivalent")
+	}
+	if m.DisableOOMKiller != nil && *m.DisableOOMKiller {
+		rd.fail(path+".disableOOMKiller", "has no cgroup v2 equivalent")
+	}
+	if m.UseHierarchy != nil && !*m.UseHierarchy {
+		rd.fail(path+".useHierarchy", "accounting is always hierarchical with cgroup v2")
+	}
+}
+
+func (rd *renderer) ioV2(b *specs.LinuxBlockIO) {
+	if b == nil {
+		return
+	}
+	const path = "linux.resources.blockIO"
+	if b.Weight != nil {
+		rd.set(path+".weight", "io.weight", fmt.Sprintf("default %d", BlkioWeightToIOWeight(*b.Weight)))
+	}
+	if b.LeafWeight != nil {
+		rd.fail(path+".leafWeight", "has no cgroup v2 equivalent")
+	}
+	for i, wd := range b.WeightDevice {
+		p := fmt.Sprintf("%s.weightDevice[%d]", path, i)
+		if wd.Weight != nil {
+			rd.set(p, "io.weight", fmt.Sprintf("%s %d", device(wd.Major, wd.Minor), BlkioWeightToIOWeight(*wd.Weight)))
+		}
+		if wd.LeafWeight != nil {
+			rd.fail(p+".leafWeight", "has no cgroup v2 equivalent")
+		}
+	}
+
+	// io.max takes all the limits of a device in one line.
+	type limits struct {
+		dev  specs.LinuxBlockIODevice
+		keys []string
+	}
+	var devices []*limits
+	byDevice := make(map[specs.LinuxBlockIODevice]*limits)
+	for _, t := range []struct {
+		key  string
+		list []specs.LinuxThrottleDevice
+	}{
+		{"rbps", b.ThrottleReadBpsDevice},
+		{"wbps", b.ThrottleWriteBpsDevice},
+		{"riops", b.ThrottleReadIOPSDevice},
+		{"wiops", b.ThrottleWriteIOPSDevice},
+	} {
+		for _, td := range t.list {
+			l := byDevice[td.LinuxBlockIODevice]
+			if l == nil {
+				l = &limits{dev: td.LinuxBlockIODevice}
+				byDevice[td.LinuxBlockIODevice] = l
+				devices = append(devices, l)
+			}
+			rate := "max"
+			if td.Rate != 0 {
+				rate = fmt.Sprint(td.Rate)
+			}
+			l.keys = append(l.keys, t.key+"="+rate)
+		}
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		a, b := devices[i].dev, devices[j].dev
+		if a.Major != b.Major {
+			return a.Major < b.Major
+		}
+		return a.Minor < b.Minor
+	})
+	for _, l := range devices {
+		rd.set(path, "io.max", device(l.dev.Major, l.dev.Minor)+" "+strings.Join(l.keys, " "))
+	}
+}
+
+func (rd *renderer) rdma(r map[string]specs.LinuxRdma) {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := r[name]
+		handles, objects := "max", "max"
+		if l.HcaHandles != nil {
+			handles = fmt.Sprint(*l.HcaHandles)
+		}
+		if l.HcaObjects != nil {
+			objects = fmt.Sprint(*l.HcaObjects)
+		}
+		rd.set(fmt.Sprintf("linux.resources.rdma[%q]", name), "rdma.max", fmt.Sprintf("%s hca_handle=%s hca_object=%s", name, handles, objects))
+	}
+}
+
+// unified adds the unified entries, after checking them against the
+// settings of the typed fields.
+func (rd *renderer) unified(u map[string]string) {
+	keys := make([]string, 0, len(u))
+	for k := range u {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path := fmt.Sprintf("linux.resources.unified[%q]", k)
+		if k == "" || strings.Contains(k, "/") || !strings.Contains(k, ".") {
+			rd.fail(path, "not the name of an interface file")
+			continue
+		}
+		var typed []int
+		for i, s := range rd.settings {
+			if s.File == k {
+				typed = append(typed, i)
+			}
+		}
+		if len(typed) == 0 {
+			rd.set(path, k, u[k])
+			continue
+		}
+		if len(typed) > 1 || rd.settings[typed[0]].Value != u[k] {
+			rd.fail(path, "conflicts with %s", rd.paths[typed[0]])
+		}
+	}
+}
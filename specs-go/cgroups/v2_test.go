@@ -0,0 +1,201 @@
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func i64(v int64) *int64   { return &v }
+func u64(v uint64) *uint64 { return &v }
+func u16(v uint16) *uint16 { return &v }
+func boolPtr(v bool) *bool { return &v }
+
+// settingStrings returns the settings as "file=value" strings.
+func settingStrings(settings []Setting) []string {
+	var list []string
+	for _, s := range settings {
+		list = append(list, s.String())
+	}
+	return list
+}
+
+func TestV2(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    *specs.LinuxResources
+		want []string
+	}{
+		{name: "nil"},
+		{
+			name: "cpu",
+			r: &specs.LinuxResources{CPU: &specs.LinuxCPU{
+				Shares: u64(1024),
+				Quota:  i64(50000),
+				Burst:  u64(1000),
+				Cpus:   "0-3",
+				Mems:   "0",
+			}},
+			want: []string{"cpu.max=50000 100000", "cpu.max.burst=1000", "cpu.weight=39", "cpuset.cpus=0-3", "cpuset.mems=0"},
+		},
+		{
+			name: "unlimited cpu quota",
+			r:    &specs.LinuxResources{CPU: &specs.LinuxCPU{Quota: i64(-1), Period: u64(50000)}},
+			want: []string{"cpu.max=max 50000"},
+		},
+		{
+			name: "zero cpu quota and period",
+			r:    &specs.LinuxResources{CPU: &specs.LinuxCPU{Quota: i64(0), Period: u64(0), Idle: i64(1)}},
+			want: []string{"cpu.idle=1"},
+		},
+		{
+			name: "zero cpu quota",
+			r:    &specs.LinuxResources{CPU: &specs.LinuxCPU{Quota: i64(0), Period: u64(50000)}},
+			want: []string{"cpu.max=max 50000"},
+		},
+		{
+			name: "memory and swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(300), Reservation: i64(50)}},
+			want: []string{"memory.low=50", "memory.max=100", "memory.swap.max=200"},
+		},
+		{
+			name: "unlimited swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(-1)}},
+			want: []string{"memory.max=100", "memory.swap.max=max"},
+		},
+		{
+			name: "unlimited memory",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1)}},
+			want: []string{"memory.max=max", "memory.swap.max=max"},
+		},
+		{
+			name: "unlimited memory with zero swap",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1), Swap: i64(0)}},
+			want: []string{"memory.max=max", "memory.swap.max=max"},
+		},
+		{
+			name: "unlimited memory with unified swap",
+			r: &specs.LinuxResources{
+				Memory:  &specs.LinuxMemory{Limit: i64(-1)},
+				Unified: map[string]string{"memory.swap.max": "0"},
+			},
+			want: []string{"memory.max=max", "memory.swap.max=0"},
+		},
+		{
+			name: "swap equal to the limit",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(100)}},
+			want: []string{"memory.max=100", "memory.swap.max=0"},
+		},
+		{
+			name: "pids",
+			r:    &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: i64(-1)}},
+			want: []string{"pids.max=max"},
+		},
+		{
+			name: "io",
+			r: &specs.LinuxResources{BlockIO: &specs.LinuxBlockIO{
+				Weight: u16(1000),
+				WeightDevice: []specs.LinuxWeightDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Weight: u16(10)},
+				},
+				ThrottleReadBpsDevice: []specs.LinuxThrottleDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 1000},
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 0}, Rate: 0},
+				},
+				ThrottleWriteIOPSDevice: []specs.LinuxThrottleDevice{
+					{LinuxBlockIODevice: specs.LinuxBlockIODevice{Major: 8, Minor: 16}, Rate: 10},
+				},
+			}},
+			want: []string{"io.max=8:0 rbps=max", "io.max=8:16 rbps=1000 wiops=10", "io.weight=default 10000", "io.weight=8:0 1"},
+		},
+		{
+			name: "hugetlb and rdma",
+			r: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 1 << 30}},
+				Rdma: map[string]specs.LinuxRdma{
+					"mlx5_1": {HcaHandles: func() *uint32 { v := uint32(3); return &v }()},
+					"mlx5_0": {},
+				},
+			},
+			want: []string{"hugetlb.2MB.max=1073741824", "rdma.max=mlx5_0 hca_handle=max hca_object=max", "rdma.max=mlx5_1 hca_handle=3 hca_object=max"},
+		},
+		{
+			name: "unified",
+			r: &specs.LinuxResources{
+				Pids:    &specs.LinuxPids{Limit: i64(10)},
+				Unified: map[string]string{"pids.max": "10", "memory.high": "1000"},
+			},
+			want: []string{"memory.high=1000", "pids.max=10"},
+		},
+		{
+			name: "deprecated kernel memory",
+			r:    &specs.LinuxResources{Memory: &specs.LinuxMemory{Kernel: i64(100)}}, //nolint:staticcheck // Kernel is deprecated.
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, err := V2(tc.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := settingStrings(settings); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestV2Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		r    *specs.LinuxResources
+	}{
+		{name: "shares", r: &specs.LinuxResources{CPU: &specs.LinuxCPU{Shares: u64(1)}}},
+		{name: "realtime", r: &specs.LinuxResources{CPU: &specs.LinuxCPU{RealtimeRuntime: i64(1)}}},
+		{name: "swap without limit", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Swap: i64(100)}}},
+		{name: "swap with unlimited memory", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(-1), Swap: i64(100)}}},
+		{name: "swap below limit", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: i64(100), Swap: i64(50)}}},
+		{name: "swappiness", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{Swappiness: u64(10)}}},
+		{name: "oom killer", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{DisableOOMKiller: boolPtr(true)}}},
+		{name: "hierarchy", r: &specs.LinuxResources{Memory: &specs.LinuxMemory{UseHierarchy: boolPtr(false)}}},
+		{name: "leaf weight", r: &specs.LinuxResources{BlockIO: &specs.LinuxBlockIO{LeafWeight: u16(10)}}},
+		{name: "page size", r: &specs.LinuxResources{HugepageLimits: []specs.LinuxHugepageLimit{{Limit: 1}}}},
+		{name: "network", r: &specs.LinuxResources{Network: &specs.LinuxNetwork{Priorities: []specs.LinuxInterfacePriority{{Name: "eth0", Priority: 1}}}}},
+		{name: "unified key", r: &specs.LinuxResources{Unified: map[string]string{"../memory.max": "1"}}},
+		{
+			name: "unified conflict",
+			r: &specs.LinuxResources{
+				Memory:  &specs.LinuxMemory{Limit: i64(100)},
+				Unified: map[string]string{"memory.max": "200"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if settings, err := V2(tc.r); err == nil {
+				t.Errorf("V2 succeeded with %q", settingStrings(settings))
+			}
+		})
+	}
+}
+
+func TestWeightConversions(t *testing.T) {
+	for _, tc := range []struct {
+		shares, weight uint64
+	}{
+		{0, 1}, {2, 1}, {1024, 39}, {262144, 10000},
+	} {
+		if got := CPUSharesToWeight(tc.shares); got != tc.weight {
+			t.Errorf("CPUSharesToWeight(%d) = %d, want %d", tc.shares, got, tc.weight)
+		}
+	}
+	for _, tc := range []struct {
+		blkio  uint16
+		weight uint64
+	}{
+		{0, 1}, {10, 1}, {500, 4950}, {1000, 10000},
+	} {
+		if got := BlkioWeightToIOWeight(tc.blkio); got != tc.weight {
+			t.Errorf("BlkioWeightToIOWeight(%d) = %d, want %d", tc.blkio, got, tc.weight)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package decode strictly decodes the JSON documents defined by the
+// specification.
+//
+// encoding/json silently ignores members which do not correspond to a field,
+// matches member names case-insensitively and keeps the last of duplicated
+// members. The decoders of this package report all of these, locating them
+// with a JSON pointer (RFC 6901). Maps such as annotations accept arbitrary
+// keys, so extension data remains allowed where the specification allows it.
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+// Error is a strictness violation in a document.
+type Error struct {
+	// Pointer is the JSON pointer of the offending member.
+	Pointer string
+	// Msg describes the violation.
+	Msg string
+}
+
+func (e *Error) Error() string {
+	p := e.Pointer
+	if p == "" {
+		p = "/"
+	}
+	return p + ": " + e.Msg
+}
+
+// Errors is the list of strictness violations found in a document.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Spec strictly decodes a configuration.
+func Spec(data []byte) (*specs.Spec, error) {
+	var spec specs.Spec
+	if err := Into(data, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// State strictly decodes the state of a container.
+func State(data []byte) (*specs.State, error) {
+	var state specs.State
+	if err := Into(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Features strictly decodes a Features structure.
+func Features(data []byte) (*features.Features, error) {
+	var feat features.Features
+	if err := Into(data, &feat); err != nil {
+		return nil, err
+	}
+	return &feat, nil
+}
+
+// Into strictly decodes data into the value pointed to by v. Syntax and type
+// errors are returned as reported by encoding/json; unknown, misspelled and
+// duplicated members are returned as Errors.
+func Into(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decode: non-nil pointer required, got %T", v)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	c := &checker{dec: json.NewDecoder(bytes.NewReader(data))}
+	c.dec.UseNumber()
+	if err := c.value("", rv.Type().Elem()); err != nil {
+		return err
+	}
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+type checker struct {
+	dec  *json.Decoder
+	errs Errors
+}
+
+func (c *checker) report(ptr, format string, args ...interface{}) {
+	c.errs = append(c.errs, &Error{Pointer: ptr, Msg: fmt.Sprintf(format, args...)})
+}
+
+// escape escapes a reference token of a JSON pointer.
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// value checks the next value of the document against the type t. A nil t
+// accepts any value, but duplicated members are still reported.
+func (c *checker) value(ptr string, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	switch delim {
+	case '{':
+		err = c.object(ptr, t)
+	case '[':
+		err = c.array(ptr, t)
+	}
+	if err != nil {
+		return err
+	}
+	// Consume the closing delimiter.
+	_, err = c.dec.Token()
+	return err
+}
+
+func (c *checker) object(ptr string, t reflect.Type) error {
+	var fs map[string]reflect.Type
+	var elem reflect.Type
+	if t != nil {
+		switch t.Kind() {
+		case reflect.Struct:
+			fs = fields(t)
+		case reflect.Map:
+			elem = t.Elem()
+		}
+	}
+	seen := make(map[string]bool)
+	for c.dec.More() {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return errors.New("decode: object key is not a string")
+		}
+		kp := ptr + "/" + escape(key)
+		if seen[key] {
+			c.report(kp, "duplicate key %q", key)
+		}
+		seen[key] = true
+
+		next := elem
+		if fs != nil {
+			ft, ok := fs[key]
+			if !ok {
+				if name := fold(fs, key); name != "" {
+					c.report(kp, "unknown field %q, did you mean %q?", key, name)
+				} else {
+					c.report(kp, "unknown field %q", key)
+				}
+			}
+			next = ft
+		}
+		if err := c.value(kp, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *checker) array(ptr string, t reflect.Type) error {
+	var elem reflect.Type
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		elem = t.Elem()
+	}
+	for i := 0; c.dec.More(); i++ {
+		if err := c.value(ptr+"/"+strconv.Itoa(i), elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields returns the types of the members of the struct type t by JSON
+// name, flattening embedded structs the way encoding/json does.
+func fields(t reflect.Type) map[string]reflect.Type {
+	m := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for n, ft := range fields(f.Type) {
+				m[n] = ft
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		m[name] = f.Type
+	}
+	return m
+}
+
+// fold returns the field name matching key case-insensitively, if any.
+func fold(fs map[string]reflect.Type, key string) string {
+	for name := range fs {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package decode
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "valid",
+			doc:  `{"ociVersion": "1.0.0", "root": {"path": "rootfs"}, "annotations": {"org.example/a~b": "1"}}`,
+		},
+		{
+			name: "misspelled",
+			doc:  `{"ociVersion": "1.0.0", "root": {"path": "rootfs", "readOnly": true}}`,
+			want: []string{`/root/readOnly: unknown field "readOnly", did you mean "readonly"?`},
+		},
+		{
+			name: "unknown",
+			doc:  `{"ociVersion": "1.0.0", "linux": {"resources": {"blockIO": {"weightDevice": [{"major": 8, "minor": 0, "bogus": 1}]}}}}`,
+			want: []string{`/linux/resources/blockIO/weightDevice/0/bogus: unknown field "bogus"`},
+		},
+		{
+			name: "duplicate",
+			doc:  `{"ociVersion": "1.0.0", "process": {"cwd": "/", "cwd": "/x"}, "annotations": {"a/b": "1", "a/b": "2"}}`,
+			want: []string{
+				`/process/cwd: duplicate key "cwd"`,
+				`/annotations/a~1b: duplicate key "a/b"`,
+			},
+		},
+		{
+			name: "duplicate in free-form value",
+			doc:  `{"ociVersion": "1.0.0", "windows": {"layerFolders": [], "credentialSpec": {"a": 1, "a": 2}}}`,
+			want: []string{`/windows/credentialSpec/a: duplicate key "a"`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Spec([]byte(tc.doc))
+			var got []string
+			var errs Errors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					got = append(got, e.Error())
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSyntaxAndTypeErrors(t *testing.T) {
+	for _, doc := range []string{`{"ociVersion": 1}`, `{"ociVersion": "1.0.0"`, `[]`} {
+		_, err := Spec([]byte(doc))
+		var errs Errors
+		if err == nil || errors.As(err, &errs) {
+			t.Errorf("Spec(%s) = %v, want an encoding/json error", doc, err)
+		}
+	}
+	var s struct{}
+	if err := Into([]byte(`{}`), s); err == nil {
+		t.Error("Into accepted a non-pointer")
+	}
+}
+
+func TestStateAndFeatures(t *testing.T) {
+	if _, err := State([]byte(`{"ociVersion": "1.0.0", "id": "x", "status": "running", "bundle": "/b"}`)); err != nil {
+		t.Error(err)
+	}
+	if _, err := State([]byte(`{"ociVersion": "1.0.0", "id": "x", "status": "running", "bundle": "/b", "Pid": 1}`)); err == nil {
+		t.Error("State accepted a misspelled member")
+	}
+	if _, err := Features([]byte(`{"linux": {"bad": 1}}`)); err == nil {
+		t.Error("Features accepted an unknown member")
+	}
+}
+
+func TestSchemaExamples(t *testing.T) {
+	dir := filepath.Join("..", "..", "schema", "test")
+	for _, tc := range []struct {
+		file   string
+		decode func([]byte) error
+	}{
+		{"config/good/minimal.json", func(b []byte) error { _, err := Spec(b); return err }},
+		{"state/good/spec-example.json", func(b []byte) error { _, err := State(b); return err }},
+		{"features/good/runc.json", func(b []byte) error { _, err := Features(b); return err }},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, tc.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.decode(data); err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package devices builds the device entries of a configuration from the
+// device nodes of the host, along with the cgroup rules allowing access to
+// them.
+//
+// Reading device nodes is only available on Linux.
+package devices
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Access is the access allowed to devices by Rule: read, write and mknod.
+const Access = "rwm"
+
+// Rule returns the cgroup rule allowing access to d, which must be a block
+// or character device. FIFOs are not subject to the device cgroup and have
+// no rule.
+func Rule(d specs.LinuxDevice) (specs.LinuxDeviceCgroup, bool) {
+	var typ string
+	switch d.Type {
+	case "b":
+		typ = "b"
+	case "c", "u":
+		typ = "c"
+	default:
+		return specs.LinuxDeviceCgroup{}, false
+	}
+	major, minor := d.Major, d.Minor
+	return specs.LinuxDeviceCgroup{
+		Allow:  true,
+		Type:   typ,
+		Major:  &major,
+		Minor:  &minor,
+		Access: Access,
+	}, true
+}
+
+// Rules returns the cgroup rules allowing access to the block and
+// character devices of devices.
+func Rules(devices []specs.LinuxDevice) []specs.LinuxDeviceCgroup {
+	var rules []specs.LinuxDeviceCgroup
+	for _, d := range devices {
+		if r, ok := Rule(d); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,58 @@
+package devices
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func i64(v int64) *int64 { return &v }
+
+func TestRules(t *testing.T) {
+	devices := []specs.LinuxDevice{
+		{Path: "/dev/sda", Type: "b", Major: 8, Minor: 0},
+		{Path: "/dev/null", Type: "c", Major: 1, Minor: 3},
+		{Path: "/dev/fifo", Type: "p"},
+		{Path: "/dev/tty1", Type: "u", Major: 4, Minor: 1},
+		{Path: "/dev/x", Type: "x", Major: 1, Minor: 1},
+	}
+	wantRules := []specs.LinuxDeviceCgroup{
+		{Allow: true, Type: "b", Major: i64(8), Minor: i64(0), Access: "rwm"},
+		{Allow: true, Type: "c", Major: i64(1), Minor: i64(3), Access: "rwm"},
+		{Allow: true, Type: "c", Major: i64(4), Minor: i64(1), Access: "rwm"},
+	}
+	for _, tc := range []struct {
+		d    specs.LinuxDevice
+		want *specs.LinuxDeviceCgroup
+	}{
+		{devices[0], &wantRules[0]},
+		{devices[1], &wantRules[1]},
+		{devices[2], nil},
+		{devices[3], &wantRules[2]},
+		{devices[4], nil},
+	} {
+		t.Run(tc.d.Type, func(t *testing.T) {
+			got, ok := Rule(tc.d)
+			if ok != (tc.want != nil) {
+				t.Fatalf("Rule(%+v) = %+v, %v", tc.d, got, ok)
+			}
+			if ok && !reflect.DeepEqual(got, *tc.want) {
+				t.Errorf("Rule(%+v) = %+v, want %+v", tc.d, got, *tc.want)
+			}
+		})
+	}
+	if got := Rules(devices); !reflect.DeepEqual(got, wantRules) {
+		t.Errorf("Rules = %+v, want %+v", got, wantRules)
+	}
+	if got := Rules(nil); got != nil {
+		t.Errorf("Rules(nil) = %+v", got)
+	}
+
+	// The numbers of the rules are copies.
+	rule, _ := Rule(devices[0])
+	*rule.Major = 9
+	if devices[0].Major != 8 {
+		t.Error("changing a rule changed the device")
+	}
+}
@@ -0,0 +1,114 @@
+//go:build linux
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ErrNotDevice is returned by FromPath for files which are not device
+// nodes or FIFOs.
+var ErrNotDevice = errors.New("not a device node")
+
+// FromPath returns the device entry of the device node or FIFO at path,
+// following symbolic links. The entry has the same path in the container,
+// and the permission bits and owner of the node.
+func FromPath(path string) (specs.LinuxDevice, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return specs.LinuxDevice{}, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return fromStat(path, &st)
+}
+
+func fromStat(path string, st *syscall.Stat_t) (specs.LinuxDevice, error) {
+	var typ string
+	switch st.Mode & syscall.S_IFMT {
+	case syscall.S_IFBLK:
+		typ = "b"
+	case syscall.S_IFCHR:
+		typ = "c"
+	case syscall.S_IFIFO:
+		typ = "p"
+	default:
+		return specs.LinuxDevice{}, fmt.Errorf("%s: %w", path, ErrNotDevice)
+	}
+	d := specs.LinuxDevice{Path: path, Type: typ}
+	if typ != "p" {
+		d.Major, d.Minor = devNumbers(uint64(st.Rdev))
+	}
+	mode := os.FileMode(st.Mode).Perm()
+	uid, gid := st.Uid, st.Gid
+	d.FileMode, d.UID, d.GID = &mode, &uid, &gid
+	return d, nil
+}
+
+// devNumbers splits a device number into its major and minor numbers, as
+// major(3) and minor(3) do.
+func devNumbers(rdev uint64) (major, minor int64) {
+	major = int64((rdev>>8)&0xfff | (rdev>>32)&0xfffff000)
+	minor = int64(rdev&0xff | (rdev>>12)&0xffffff00)
+	return major, minor
+}
+
+// skipDirs are the directories of /dev holding no devices to pass to a
+// container, or devices which belong to the host.
+var skipDirs = map[string]bool{
+	"pts":         true,
+	"shm":         true,
+	"fd":          true,
+	"mqueue":      true,
+	".lxc":        true,
+	".lxd-mounts": true,
+	".udev":       true,
+}
+
+// FromDir returns the device entries of the device nodes under dir, in
+// lexical order. FIFOs, symbolic links, and the directories of /dev which
+// are private to the host, such as pts and shm, are skipped. Nodes removed
+// while dir is walked are skipped.
+func FromDir(dir string) ([]specs.LinuxDevice, error) {
+	var devices []specs.LinuxDevice
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if e.IsDir() {
+			if path != dir && skipDirs[e.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e.Type()&(fs.ModeDevice|fs.ModeCharDevice) == 0 {
+			return nil
+		}
+		var st syscall.Stat_t
+		if err := syscall.Lstat(path, &st); err != nil {
+			if err == syscall.ENOENT {
+				return nil
+			}
+			return &os.PathError{Op: "lstat", Path: path, Err: err}
+		}
+		d, err := fromStat(path, &st)
+		if err != nil || d.Type == "p" {
+			// The node was replaced since it was listed.
+			return nil
+		}
+		devices = append(devices, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
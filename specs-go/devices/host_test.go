@@ -0,0 +1,176 @@
+//go:build linux
+
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestFromStat(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		st   syscall.Stat_t
+		want specs.LinuxDevice
+	}{
+		{
+			name: "null",
+			st:   syscall.Stat_t{Mode: syscall.S_IFCHR | 0o666, Rdev: 0x103},
+			want: specs.LinuxDevice{Type: "c", Major: 1, Minor: 3},
+		},
+		{
+			name: "large minor",
+			st:   syscall.Stat_t{Mode: syscall.S_IFBLK | 0o660, Rdev: 0x493103e0, Gid: 6},
+			want: specs.LinuxDevice{Type: "b", Major: 259, Minor: 300000},
+		},
+		{
+			name: "largest 12-bit major",
+			st:   syscall.Stat_t{Mode: syscall.S_IFCHR | 0o600, Rdev: 0xfff00, Uid: 1000},
+			want: specs.LinuxDevice{Type: "c", Major: 4095, Minor: 0},
+		},
+		{
+			name: "fifo",
+			st:   syscall.Stat_t{Mode: syscall.S_IFIFO | 0o644, Rdev: 0x103},
+			want: specs.LinuxDevice{Type: "p"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fromStat("/dev/x", &tc.st)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mode := os.FileMode(tc.st.Mode).Perm()
+			uid, gid := tc.st.Uid, tc.st.Gid
+			want := tc.want
+			want.Path, want.FileMode, want.UID, want.GID = "/dev/x", &mode, &uid, &gid
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+	for _, mode := range []uint32{syscall.S_IFREG, syscall.S_IFDIR, syscall.S_IFLNK, syscall.S_IFSOCK} {
+		st := syscall.Stat_t{Mode: mode | 0o644}
+		if _, err := fromStat("/dev/x", &st); !errors.Is(err, ErrNotDevice) {
+			t.Errorf("mode %#o: got %v, want ErrNotDevice", mode, err)
+		}
+	}
+}
+
+func TestDevNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		rdev         uint64
+		major, minor int64
+	}{
+		{0x103, 1, 3},
+		{0x493103e0, 259, 300000},
+		{0xfff00, 4095, 0},
+		{0x100056723489, 4660, 354185},
+		{^uint64(0), 0xffffffff, 0xffffffff},
+	} {
+		if major, minor := devNumbers(tc.rdev); major != tc.major || minor != tc.minor {
+			t.Errorf("devNumbers(%#x) = %d, %d, want %d, %d", tc.rdev, major, minor, tc.major, tc.minor)
+		}
+	}
+}
+
+func TestFromPath(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// Set the mode regardless of the umask.
+	if err := os.Chmod(fifo, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromPath(fifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Path != fifo || d.Type != "p" || d.Major != 0 || d.Minor != 0 || *d.FileMode != 0o640 {
+		t.Errorf("got %+v", d)
+	}
+	if _, ok := Rule(d); ok {
+		t.Error("FIFOs have a cgroup rule")
+	}
+
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(fifo, link); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := FromPath(link); err != nil || d.Type != "p" || d.Path != link {
+		t.Errorf("following a link: got %+v, %v", d, err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromPath(file); !errors.Is(err, ErrNotDevice) {
+		t.Errorf("regular file: got %v, want ErrNotDevice", err)
+	}
+	if _, err := FromPath(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestFromDir(t *testing.T) {
+	dir := t.TempDir()
+	mknod := func(name string, mode uint32, dev int) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Mknod(path, mode, dev); err != nil {
+			if errors.Is(err, syscall.EPERM) {
+				t.Skip("creating device nodes is not permitted")
+			}
+			t.Fatal(err)
+		}
+	}
+	mknod("null", syscall.S_IFCHR|0o666, 0x103)
+	mknod("sub/sda", syscall.S_IFBLK|0o660, 0x800)
+	mknod("fifo", syscall.S_IFIFO|0o600, 0)
+	for _, skip := range []string{"pts/0", "shm/x", "fd/x", "mqueue/x", ".udev/x"} {
+		mknod(skip, syscall.S_IFCHR|0o600, 0x8800)
+	}
+	if err := os.Symlink("null", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	devices, err := FromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range devices {
+		r, ok := Rule(d)
+		if !ok {
+			t.Errorf("%s: no cgroup rule", d.Path)
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %d:%d", d.Path, d.Type, r.Type, *r.Major, *r.Minor))
+	}
+	want := []string{
+		filepath.Join(dir, "null") + " c c 1:3",
+		filepath.Join(dir, "sub/sda") + " b b 8:0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := FromDir(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing directory: got %v, want ErrNotExist", err)
+	}
+}
@@ -0,0 +1,393 @@
+// Package diff computes structural differences between two configurations.
+//
+// Lists whose elements have a natural identity are matched by that identity
+// rather than by index: mounts by destination, namespaces by type, devices
+// by path, rlimits by type and seccomp rules by syscall names. Lists used as
+// sets, such as capabilities, are compared regardless of order.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Op is the kind of a change.
+type Op string
+
+const (
+	// Add means that the value only exists in the second configuration.
+	Add Op = "add"
+	// Remove means that the value only exists in the first configuration.
+	Remove Op = "remove"
+	// Modify means that the value differs between the configurations.
+	Modify Op = "modify"
+	// Reorder means that a keyed list holds the same elements in a
+	// different order. Old and New hold the keys in their respective order.
+	Reorder Op = "reorder"
+)
+
+// Change is a single difference between two configurations.
+type Change struct {
+	// Op is the kind of change.
+	Op Op `json:"op"`
+	// Path is the JSON path of the changed value. Elements of keyed lists
+	// are addressed by their key, e.g. `mounts[destination="/tmp"]`.
+	Path string `json:"path"`
+	// Old is the value in the first configuration, if any.
+	Old interface{} `json:"old,omitempty"`
+	// New is the value in the second configuration, if any.
+	New interface{} `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case Add:
+		return fmt.Sprintf("+ %s: %s", c.Path, format(c.New))
+	case Remove:
+		return fmt.Sprintf("- %s: %s", c.Path, format(c.Old))
+	case Reorder:
+		return fmt.Sprintf("~ %s: order %s -> %s", c.Path, format(c.Old), format(c.New))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, format(c.Old), format(c.New))
+}
+
+// KeyFunc returns the identity of a list element, and the name of the
+// identifying field used in paths.
+type KeyFunc func(elem interface{}) (field string, key string)
+
+// Keys maps list element types to the function computing their identity.
+// Lists whose element type is not in Keys are compared by index.
+var Keys = map[reflect.Type]KeyFunc{
+	reflect.TypeOf(specs.Mount{}): func(e interface{}) (string, string) {
+		return "destination", path.Clean(e.(specs.Mount).Destination)
+	},
+	reflect.TypeOf(specs.LinuxNamespace{}): func(e interface{}) (string, string) {
+		return "type", string(e.(specs.LinuxNamespace).Type)
+	},
+	reflect.TypeOf(specs.ZOSNamespace{}): func(e interface{}) (string, string) {
+		return "type", string(e.(specs.ZOSNamespace).Type)
+	},
+	reflect.TypeOf(specs.LinuxDevice{}): func(e interface{}) (string, string) {
+		return "path", e.(specs.LinuxDevice).Path
+	},
+	reflect.TypeOf(specs.FreeBSDDevice{}): func(e interface{}) (string, string) {
+		return "path", e.(specs.FreeBSDDevice).Path
+	},
+	reflect.TypeOf(specs.POSIXRlimit{}): func(e interface{}) (string, string) {
+		return "type", e.(specs.POSIXRlimit).Type
+	},
+	reflect.TypeOf(specs.LinuxSyscall{}): func(e interface{}) (string, string) {
+		names := append([]string(nil), e.(specs.LinuxSyscall).Names...)
+		sort.Strings(names)
+		return "names", strings.Join(names, ",")
+	},
+}
+
+// Sets holds the paths of string lists whose order is not significant.
+// Their elements are reported as added to or removed from the list.
+var Sets = map[string]bool{
+	"process.capabilities.bounding":    true,
+	"process.capabilities.effective":   true,
+	"process.capabilities.inheritable": true,
+	"process.capabilities.permitted":   true,
+	"process.capabilities.ambient":     true,
+	"process.user.additionalGids":      true,
+	"linux.maskedPaths":                true,
+	"linux.readonlyPaths":              true,
+	"linux.seccomp.architectures":      true,
+	"linux.seccomp.flags":              true,
+}
+
+// Diff returns the changes turning a into b. A nil configuration is
+// treated as empty.
+func Diff(a, b *specs.Spec) []Change {
+	if a == nil {
+		a = &specs.Spec{}
+	}
+	if b == nil {
+		b = &specs.Spec{}
+	}
+	var d differ
+	d.walkStruct("", reflect.ValueOf(*a), reflect.ValueOf(*b))
+	return d.changes
+}
+
+// Report writes a human-readable report of changes to w, one change per
+// line.
+func Report(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(op Op, p string, old, new interface{}) {
+	d.changes = append(d.changes, Change{Op: op, Path: p, Old: old, New: new})
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// isEmpty reports whether v would be omitted from the JSON encoding.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Struct:
+		return v.IsZero()
+	}
+	return v.IsZero()
+}
+
+// isScalar reports whether values of t are neither pointers nor
+// composite.
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
+		return false
+	}
+	return true
+}
+
+// value returns the interface value of v, dereferencing pointers.
+func value(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func (d *differ) walk(p string, a, b reflect.Value) {
+	switch {
+	case isEmpty(a) && isEmpty(b):
+		return
+	case isEmpty(a):
+		d.add(Add, p, nil, value(b))
+		return
+	case isEmpty(b):
+		d.add(Remove, p, value(a), nil)
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		// Both pointers are set, so the values they point to are present
+		// even when zero.
+		if a.Elem().Kind() == reflect.Struct {
+			d.walkStruct(p, a.Elem(), b.Elem())
+		} else {
+			d.walkElem(p, a.Elem(), b.Elem())
+		}
+	case reflect.Struct:
+		d.walkStruct(p, a, b)
+	case reflect.Slice:
+		if keyFn, ok := Keys[a.Type().Elem()]; ok {
+			d.walkKeyedSlice(p, a, b, keyFn)
+		} else if Sets[p] {
+			d.walkSet(p, a, b)
+		} else {
+			d.walkSlice(p, a, b)
+		}
+	case reflect.Map:
+		d.walkMap(p, a, b)
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(Modify, p, value(a), value(b))
+		}
+	}
+}
+
+func (d *differ) walkStruct(p string, a, b reflect.Value) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			d.walk(p, a.Field(i), b.Field(i))
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !strings.Contains(tag, ",omitempty") {
+			// Zero values of fields which are always encoded are
+			// present.
+			switch {
+			case f.Type.Kind() == reflect.Struct:
+				d.walkStruct(join(p, name), a.Field(i), b.Field(i))
+				continue
+			case isScalar(f.Type):
+				d.walkElem(join(p, name), a.Field(i), b.Field(i))
+				continue
+			}
+		}
+		d.walk(join(p, name), a.Field(i), b.Field(i))
+	}
+}
+
+func (d *differ) walkSlice(p string, a, b reflect.Value) {
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	for i := 0; i < n; i++ {
+		ip := fmt.Sprintf("%s[%d]", p, i)
+		switch {
+		case i >= a.Len():
+			d.add(Add, ip, nil, value(b.Index(i)))
+		case i >= b.Len():
+			d.add(Remove, ip, value(a.Index(i)), nil)
+		default:
+			d.walkElem(ip, a.Index(i), b.Index(i))
+		}
+	}
+}
+
+func (d *differ) walkSet(p string, a, b reflect.Value) {
+	count := func(s reflect.Value) map[interface{}]int {
+		m := make(map[interface{}]int)
+		for i := 0; i < s.Len(); i++ {
+			m[s.Index(i).Interface()]++
+		}
+		return m
+	}
+	ca, cb := count(a), count(b)
+	for i := 0; i < a.Len(); i++ {
+		e := a.Index(i).Interface()
+		if cb[e] > 0 {
+			cb[e]--
+		} else {
+			d.add(Remove, p, e, nil)
+		}
+	}
+	for i := 0; i < b.Len(); i++ {
+		e := b.Index(i).Interface()
+		if ca[e] > 0 {
+			ca[e]--
+		} else {
+			d.add(Add, p, nil, e)
+		}
+	}
+}
+
+// walkElem compares two list elements. Unlike struct fields, empty
+// elements are significant.
+func (d *differ) walkElem(p string, a, b reflect.Value) {
+	if a.Kind() == reflect.Struct || a.Kind() == reflect.Ptr {
+		d.walk(p, a, b)
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		d.add(Modify, p, value(a), value(b))
+	}
+}
+
+func (d *differ) walkKeyedSlice(p string, a, b reflect.Value, keyFn KeyFunc) {
+	type elem struct {
+		key string
+		v   reflect.Value
+	}
+	collect := func(s reflect.Value) ([]elem, map[string][]int) {
+		elems := make([]elem, s.Len())
+		index := make(map[string][]int)
+		for i := range elems {
+			_, key := keyFn(s.Index(i).Interface())
+			elems[i] = elem{key, s.Index(i)}
+			index[key] = append(index[key], i)
+		}
+		return elems, index
+	}
+	field, _ := keyFn(reflect.Zero(a.Type().Elem()).Interface())
+	elemPath := func(key string) string {
+		return fmt.Sprintf("%s[%s=%q]", p, field, key)
+	}
+
+	ea, _ := collect(a)
+	eb, ib := collect(b)
+	matched := make([]bool, len(eb))
+	var orderA []string
+	for _, e := range ea {
+		if idx := ib[e.key]; len(idx) > 0 {
+			j := idx[0]
+			ib[e.key] = idx[1:]
+			matched[j] = true
+			orderA = append(orderA, e.key)
+			d.walk(elemPath(e.key), e.v, eb[j].v)
+		} else {
+			d.add(Remove, elemPath(e.key), value(e.v), nil)
+		}
+	}
+	var orderB []string
+	for j, e := range eb {
+		if matched[j] {
+			orderB = append(orderB, e.key)
+		} else {
+			d.add(Add, elemPath(e.key), nil, value(e.v))
+		}
+	}
+	if !reflect.DeepEqual(orderA, orderB) {
+		d.add(Reorder, p, orderA, orderB)
+	}
+}
+
+func (d *differ) walkMap(p string, a, b reflect.Value) {
+	keys := make(map[string]reflect.Value)
+	for _, k := range a.MapKeys() {
+		keys[fmt.Sprint(k.Interface())] = k
+	}
+	for _, k := range b.MapKeys() {
+		keys[fmt.Sprint(k.Interface())] = k
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := keys[name]
+		kp := fmt.Sprintf("%s[%q]", p, name)
+		va, vb := a.MapIndex(k), b.MapIndex(k)
+		switch {
+		case !va.IsValid():
+			d.add(Add, kp, nil, value(vb))
+		case !vb.IsValid():
+			d.add(Remove, kp, value(va), nil)
+		default:
+			d.walkElem(kp, va, vb)
+		}
+	}
+}
+
+func format(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
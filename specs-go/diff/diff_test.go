@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b specs.Spec
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    specs.Spec{Version: "1.0.0", Hostname: "a"},
+			b:    specs.Spec{Version: "1.0.0", Hostname: "a"},
+		},
+		{
+			name: "scalars",
+			a:    specs.Spec{Version: "1.0.0", Hostname: "a"},
+			b:    specs.Spec{Version: "1.1.0", Domainname: "example.com"},
+			want: []string{
+				`~ ociVersion: "1.0.0" -> "1.1.0"`,
+				`- hostname: "a"`,
+				`+ domainname: "example.com"`,
+			},
+		},
+		{
+			name: "pointer to zero",
+			a: specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{
+				Pids:   &specs.LinuxPids{Limit: int64Ptr(0)},
+				Memory: &specs.LinuxMemory{Swap: int64Ptr(0)},
+			}}},
+			b: specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{
+				Pids:   &specs.LinuxPids{Limit: int64Ptr(5)},
+				Memory: &specs.LinuxMemory{},
+			}}},
+			want: []string{
+				`- linux.resources.memory.swap: 0`,
+				`~ linux.resources.pids.limit: 0 -> 5`,
+			},
+		},
+		{
+			name: "pointer added",
+			a:    specs.Spec{Process: &specs.Process{}},
+			b:    specs.Spec{Process: &specs.Process{OOMScoreAdj: func() *int { i := 0; return &i }()}},
+			want: []string{`+ process.oomScoreAdj: 0`},
+		},
+		{
+			name: "encoded zero",
+			a:    specs.Spec{Process: &specs.Process{User: specs.User{UID: 0}}},
+			b:    specs.Spec{Process: &specs.Process{User: specs.User{UID: 1000}}},
+			want: []string{`~ process.user.uid: 0 -> 1000`},
+		},
+		{
+			name: "keyed list",
+			a: specs.Spec{Mounts: []specs.Mount{
+				{Destination: "/proc", Type: "proc"},
+				{Destination: "/dev", Type: "tmpfs"},
+				{Destination: "/sys", Type: "sysfs"},
+			}},
+			b: specs.Spec{Mounts: []specs.Mount{
+				{Destination: "/dev/", Type: "devtmpfs"},
+				{Destination: "/proc", Type: "proc"},
+				{Destination: "/tmp", Type: "tmpfs"},
+			}},
+			want: []string{
+				`~ mounts[destination="/dev"].destination: "/dev" -> "/dev/"`,
+				`~ mounts[destination="/dev"].type: "tmpfs" -> "devtmpfs"`,
+				`- mounts[destination="/sys"]: {"destination":"/sys","type":"sysfs"}`,
+				`+ mounts[destination="/tmp"]: {"destination":"/tmp","type":"tmpfs"}`,
+				`~ mounts: order ["/proc","/dev"] -> ["/dev","/proc"]`,
+			},
+		},
+		{
+			name: "set",
+			a: specs.Spec{Process: &specs.Process{Capabilities: &specs.LinuxCapabilities{
+				Bounding: []string{"CAP_CHOWN", "CAP_KILL"},
+			}}},
+			b: specs.Spec{Process: &specs.Process{Capabilities: &specs.LinuxCapabilities{
+				Bounding: []string{"CAP_NET_RAW", "CAP_CHOWN"},
+			}}},
+			want: []string{
+				`- process.capabilities.bounding: "CAP_KILL"`,
+				`+ process.capabilities.bounding: "CAP_NET_RAW"`,
+			},
+		},
+		{
+			name: "indexed list and map",
+			a: specs.Spec{
+				Process:     &specs.Process{Args: []string{"sh", "-c"}},
+				Annotations: map[string]string{"a": "1", "b": ""},
+			},
+			b: specs.Spec{
+				Process:     &specs.Process{Args: []string{"bash"}},
+				Annotations: map[string]string{"b": "2", "c": "3"},
+			},
+			want: []string{
+				`~ process.args[0]: "sh" -> "bash"`,
+				`- process.args[1]: "-c"`,
+				`- annotations["a"]: "1"`,
+				`~ annotations["b"]: "" -> "2"`,
+				`+ annotations["c"]: "3"`,
+			},
+		},
+		{
+			name: "seccomp rules",
+			a: specs.Spec{Linux: &specs.Linux{Seccomp: &specs.LinuxSeccomp{Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"read", "write"}, Action: specs.ActAllow},
+			}}}},
+			b: specs.Spec{Linux: &specs.Linux{Seccomp: &specs.LinuxSeccomp{Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"write", "read"}, Action: specs.ActLog},
+			}}}},
+			want: []string{
+				`~ linux.seccomp.syscalls[names="read,write"].names[0]: "read" -> "write"`,
+				`~ linux.seccomp.syscalls[names="read,write"].names[1]: "write" -> "read"`,
+				`~ linux.seccomp.syscalls[names="read,write"].action: "SCMP_ACT_ALLOW" -> "SCMP_ACT_LOG"`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Diff(&tc.a, &tc.b) {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got changes\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffModifyOp(t *testing.T) {
+	a := &specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: int64Ptr(0)}}}}
+	b := &specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: int64Ptr(5)}}}}
+	want := []Change{{Op: Modify, Path: "linux.resources.pids.limit", Old: int64(0), New: int64(5)}}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	changes := Diff(nil, &specs.Spec{Hostname: "a"})
+	if err := Report(&buf, changes); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "+ hostname: \"a\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package generate
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+func newFreeBSD(o *options) *specs.Spec {
+	return &specs.Spec{
+		Root: &specs.Root{
+			Path: "rootfs",
+		},
+		Process: posixProcess(o),
+		Mounts: []specs.Mount{
+			{
+				Destination: "/dev",
+				Type:        "devfs",
+				Source:      "devfs",
+				Options:     []string{"ruleset=4"},
+			},
+			{
+				Destination: "/dev/fd",
+				Type:        "fdescfs",
+				Source:      "fdescfs",
+			},
+		},
+		FreeBSD: &specs.FreeBSD{
+			Jail: &specs.FreeBSDJail{
+				Host:          specs.FreeBSDShareNew,
+				EnforceStatfs: intPtr(2),
+			},
+		},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
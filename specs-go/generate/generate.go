@@ -0,0 +1,93 @@
+// Package generate produces ready-to-run configurations with secure
+// platform-specific defaults.
+package generate
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Option customizes the configuration produced by New.
+type Option func(*options)
+
+type options struct {
+	rootless    bool
+	terminal    bool
+	uidMappings []specs.LinuxIDMapping
+	gidMappings []specs.LinuxIDMapping
+}
+
+// WithRootless adapts the configuration for a runtime running as an
+// unprivileged user. It is only supported on Linux.
+func WithRootless() Option {
+	return func(o *options) {
+		o.rootless = true
+	}
+}
+
+// WithTerminal sets whether a terminal is attached to the container process.
+func WithTerminal(terminal bool) Option {
+	return func(o *options) {
+		o.terminal = terminal
+	}
+}
+
+// WithUserMappings creates a user namespace with the given uid and gid
+// mappings. It is only supported on Linux.
+func WithUserMappings(uidMappings, gidMappings []specs.LinuxIDMapping) Option {
+	return func(o *options) {
+		o.uidMappings = uidMappings
+		o.gidMappings = gidMappings
+	}
+}
+
+// New returns a configuration for platform, which is one of "linux",
+// "windows", "freebsd", "solaris" or "zos". The configuration runs "sh" in
+// a root filesystem named "rootfs" relative to the bundle; on Windows the
+// caller must still fill in the root and the layer folders, as those cannot
+// be defaulted.
+func New(platform string, opts ...Option) (*specs.Spec, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if platform != "linux" && (o.rootless || o.uidMappings != nil || o.gidMappings != nil) {
+		return nil, fmt.Errorf("rootless mode and user mappings are not supported on %s", platform)
+	}
+
+	var spec *specs.Spec
+	switch platform {
+	case "linux":
+		spec = newLinux(&o)
+	case "windows":
+		spec = newWindows(&o)
+	case "freebsd":
+		spec = newFreeBSD(&o)
+	case "solaris":
+		spec = newSolaris(&o)
+	case "zos":
+		spec = newZOS(&o)
+	default:
+		return nil, fmt.Errorf("unsupported platform %q", platform)
+	}
+	spec.Version = specs.Version
+	return spec, nil
+}
+
+// posixProcess returns the process shared by the POSIX platforms.
+func posixProcess(o *options) *specs.Process {
+	return &specs.Process{
+		Terminal: o.terminal,
+		Args:     []string{"sh"},
+		Env: []string{
+			"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+			"TERM=xterm",
+		},
+		Cwd: "/",
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
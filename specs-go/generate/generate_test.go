@@ -0,0 +1,113 @@
+package generate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/validate"
+)
+
+func TestNewIsValid(t *testing.T) {
+	for _, platform := range []string{"linux", "freebsd", "solaris", "zos", "windows"} {
+		t.Run(platform, func(t *testing.T) {
+			spec, err := New(platform, WithTerminal(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.Version != specs.Version {
+				t.Errorf("version %q, want %q", spec.Version, specs.Version)
+			}
+			if !spec.Process.Terminal {
+				t.Error("terminal is not set")
+			}
+			if platform == "windows" {
+				// The root and layer folders are left to the caller.
+				spec.Root = &specs.Root{Path: `\\?\Volume{ec84d99e-3f02-11e7-ac6c-00155d7682cf}\`}
+				spec.Windows.LayerFolders = []string{`C:\layers\base`}
+			}
+			for _, err := range validate.Validate(spec, platform) {
+				var e *validate.Error
+				if !errors.As(err, &e) || e.Level == validate.Must {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewUnsupported(t *testing.T) {
+	if _, err := New("plan9"); err == nil {
+		t.Error("New accepted an unknown platform")
+	}
+	if _, err := New("windows", WithRootless()); err == nil {
+		t.Error("New accepted rootless mode on windows")
+	}
+}
+
+func TestNewLinuxDefaultsAreCopies(t *testing.T) {
+	spec, err := New("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Process.Capabilities.Bounding[0] = "CAP_SYS_ADMIN"
+	spec.Linux.MaskedPaths[0] = "/"
+	if DefaultCapabilities[0] == "CAP_SYS_ADMIN" || DefaultMaskedPaths[0] == "/" {
+		t.Error("the configuration shares its lists with the defaults")
+	}
+}
+
+func TestRootless(t *testing.T) {
+	spec, err := New("linux", WithRootless())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Linux.Resources != nil {
+		t.Error("resources are set")
+	}
+	want := []specs.LinuxIDMapping{{HostID: uint32(os.Geteuid()), Size: 1}}
+	if len(spec.Linux.UIDMappings) != 1 || spec.Linux.UIDMappings[0] != want[0] {
+		t.Errorf("uid mappings %v, want %v", spec.Linux.UIDMappings, want)
+	}
+	userNS := 0
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.UserNamespace {
+			userNS++
+		}
+	}
+	if userNS != 1 {
+		t.Errorf("%d user namespaces, want 1", userNS)
+	}
+	for _, m := range spec.Mounts {
+		if m.Destination == "/sys" && m.Source != "/sys" {
+			t.Errorf("/sys is not bind mounted: %+v", m)
+		}
+		for _, o := range m.Options {
+			if strings.HasPrefix(o, "uid=") || strings.HasPrefix(o, "gid=") {
+				t.Errorf("mount %s keeps option %q", m.Destination, o)
+			}
+		}
+	}
+}
+
+func TestUserMappings(t *testing.T) {
+	uids := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	spec, err := New("linux", WithUserMappings(uids, uids), WithRootless())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Linux.UIDMappings) != 1 || spec.Linux.UIDMappings[0] != uids[0] {
+		t.Errorf("uid mappings %v, want %v", spec.Linux.UIDMappings, uids)
+	}
+	userNS := 0
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.UserNamespace {
+			userNS++
+		}
+	}
+	if userNS != 1 {
+		t.Errorf("%d user namespaces, want 1", userNS)
+	}
+}
@@ -0,0 +1,620 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Generator mutates a configuration. Intermediate structures such as
+// Process, Linux, Linux.Resources, Linux.Seccomp and Hooks are created on
+// demand, and every method returns the Generator so that calls can be
+// chained. Invalid arguments, such as an unknown hook phase, leave the
+// configuration unchanged, and are reported by Spec.
+type Generator struct {
+	// Config is the configuration being mutated.
+	Config *specs.Spec
+	errs   []error
+}
+
+// NewGenerator returns a Generator mutating spec. If spec is nil, an empty
+// configuration for the current version of the specification is used.
+func NewGenerator(spec *specs.Spec) *Generator {
+	if spec == nil {
+		spec = &specs.Spec{Version: specs.Version}
+	}
+	return &Generator{Config: spec}
+}
+
+// Spec returns the configuration, along with the errors of the calls with
+// invalid arguments, if any.
+func (g *Generator) Spec() (*specs.Spec, error) {
+	return g.Config, errors.Join(g.errs...)
+}
+
+func (g *Generator) fail(format string, args ...interface{}) *Generator {
+	g.errs = append(g.errs, fmt.Errorf(format, args...))
+	return g
+}
+
+// CapabilitySet names one of the sets of LinuxCapabilities.
+type CapabilitySet string
+
+const (
+	// Bounding is the bounding capability set.
+	Bounding CapabilitySet = "bounding"
+	// Effective is the effective capability set.
+	Effective CapabilitySet = "effective"
+	// Inheritable is the inheritable capability set.
+	Inheritable CapabilitySet = "inheritable"
+	// Permitted is the permitted capability set.
+	Permitted CapabilitySet = "permitted"
+	// Ambient is the ambient capability set.
+	Ambient CapabilitySet = "ambient"
+)
+
+// AllCapabilitySets lists every capability set.
+var AllCapabilitySets = []CapabilitySet{Bounding, Effective, Inheritable, Permitted, Ambient}
+
+// HookPhase names a lifecycle phase of Hooks.
+type HookPhase string
+
+const (
+	// Prestart is the deprecated prestart phase.
+	Prestart HookPhase = "prestart"
+	// CreateRuntime is the createRuntime phase.
+	CreateRuntime HookPhase = "createRuntime"
+	// CreateContainer is the createContainer phase.
+	CreateContainer HookPhase = "createContainer"
+	// StartContainer is the startContainer phase.
+	StartContainer HookPhase = "startContainer"
+	// Poststart is the poststart phase.
+	Poststart HookPhase = "poststart"
+	// Poststop is the poststop phase.
+	Poststop HookPhase = "poststop"
+)
+
+func (g *Generator) initProcess() *specs.Process {
+	if g.Config.Process == nil {
+		g.Config.Process = &specs.Process{}
+	}
+	return g.Config.Process
+}
+
+func (g *Generator) initCapabilities() *specs.LinuxCapabilities {
+	proc := g.initProcess()
+	if proc.Capabilities == nil {
+		proc.Capabilities = &specs.LinuxCapabilities{}
+	}
+	return proc.Capabilities
+}
+
+func (g *Generator) initRoot() *specs.Root {
+	if g.Config.Root == nil {
+		g.Config.Root = &specs.Root{}
+	}
+	return g.Config.Root
+}
+
+func (g *Generator) initHooks() *specs.Hooks {
+	if g.Config.Hooks == nil {
+		g.Config.Hooks = &specs.Hooks{}
+	}
+	return g.Config.Hooks
+}
+
+func (g *Generator) initLinux() *specs.Linux {
+	if g.Config.Linux == nil {
+		g.Config.Linux = &specs.Linux{}
+	}
+	return g.Config.Linux
+}
+
+func (g *Generator) initResources() *specs.LinuxResources {
+	linux := g.initLinux()
+	if linux.Resources == nil {
+		linux.Resources = &specs.LinuxResources{}
+	}
+	return linux.Resources
+}
+
+func (g *Generator) initMemory() *specs.LinuxMemory {
+	r := g.initResources()
+	if r.Memory == nil {
+		r.Memory = &specs.LinuxMemory{}
+	}
+	return r.Memory
+}
+
+func (g *Generator) initCPU() *specs.LinuxCPU {
+	r := g.initResources()
+	if r.CPU == nil {
+		r.CPU = &specs.LinuxCPU{}
+	}
+	return r.CPU
+}
+
+func (g *Generator) initPids() *specs.LinuxPids {
+	r := g.initResources()
+	if r.Pids == nil {
+		r.Pids = &specs.LinuxPids{}
+	}
+	return r.Pids
+}
+
+func (g *Generator) initBlockIO() *specs.LinuxBlockIO {
+	r := g.initResources()
+	if r.BlockIO == nil {
+		r.BlockIO = &specs.LinuxBlockIO{}
+	}
+	return r.BlockIO
+}
+
+func (g *Generator) initSeccomp() *specs.LinuxSeccomp {
+	linux := g.initLinux()
+	if linux.Seccomp == nil {
+		linux.Seccomp = &specs.LinuxSeccomp{}
+	}
+	return linux.Seccomp
+}
+
+// SetHostname sets the hostname of the container.
+func (g *Generator) SetHostname(hostname string) *Generator {
+	g.Config.Hostname = hostname
+	return g
+}
+
+// SetRootPath sets the path to the root filesystem.
+func (g *Generator) SetRootPath(p string) *Generator {
+	g.initRoot().Path = p
+	return g
+}
+
+// SetRootReadonly sets whether the root filesystem is read-only.
+func (g *Generator) SetRootReadonly(readonly bool) *Generator {
+	g.initRoot().Readonly = readonly
+	return g
+}
+
+// AddAnnotation sets the annotation key to value.
+func (g *Generator) AddAnnotation(key, value string) *Generator {
+	if g.Config.Annotations == nil {
+		g.Config.Annotations = make(map[string]string)
+	}
+	g.Config.Annotations[key] = value
+	return g
+}
+
+// RemoveAnnotation removes the annotation key.
+func (g *Generator) RemoveAnnotation(key string) *Generator {
+	delete(g.Config.Annotations, key)
+	return g
+}
+
+// SetProcessArgs sets the arguments of the container process.
+func (g *Generator) SetProcessArgs(args ...string) *Generator {
+	g.initProcess().Args = args
+	return g
+}
+
+// SetProcessCwd sets the working directory of the container process.
+func (g *Generator) SetProcessCwd(cwd string) *Generator {
+	g.initProcess().Cwd = cwd
+	return g
+}
+
+// SetProcessUser sets the uid and gid of the container process.
+func (g *Generator) SetProcessUser(uid, gid uint32) *Generator {
+	proc := g.initProcess()
+	proc.User.UID = uid
+	proc.User.GID = gid
+	return g
+}
+
+// SetProcessTerminal sets whether a terminal is attached to the container
+// process.
+func (g *Generator) SetProcessTerminal(terminal bool) *Generator {
+	g.initProcess().Terminal = terminal
+	return g
+}
+
+// SetProcessNoNewPrivileges sets the no_new_privs bit of the container
+// process.
+func (g *Generator) SetProcessNoNewPrivileges(noNewPrivileges bool) *Generator {
+	g.initProcess().NoNewPrivileges = noNewPrivileges
+	return g
+}
+
+// AddProcessEnv sets the environment variable name to value, replacing any
+// previous definition.
+func (g *Generator) AddProcessEnv(name, value string) *Generator {
+	proc := g.initProcess()
+	entry := name + "=" + value
+	for i, e := range proc.Env {
+		if strings.SplitN(e, "=", 2)[0] == name {
+			proc.Env[i] = entry
+			return g
+		}
+	}
+	proc.Env = append(proc.Env, entry)
+	return g
+}
+
+// AddProcessRlimit sets the rlimit typ, replacing any previous entry of the
+// same type.
+func (g *Generator) AddProcessRlimit(typ string, hard, soft uint64) *Generator {
+	proc := g.initProcess()
+	rl := specs.POSIXRlimit{Type: typ, Hard: hard, Soft: soft}
+	for i := range proc.Rlimits {
+		if proc.Rlimits[i].Type == typ {
+			proc.Rlimits[i] = rl
+			return g
+		}
+	}
+	proc.Rlimits = append(proc.Rlimits, rl)
+	return g
+}
+
+// AddMount adds m, replacing any mount with the same destination.
+func (g *Generator) AddMount(m specs.Mount) *Generator {
+	dest := path.Clean(m.Destination)
+	for i := range g.Config.Mounts {
+		if path.Clean(g.Config.Mounts[i].Destination) == dest {
+			g.Config.Mounts[i] = m
+			return g
+		}
+	}
+	g.Config.Mounts = append(g.Config.Mounts, m)
+	return g
+}
+
+// RemoveMount removes the mounts placed at destination.
+func (g *Generator) RemoveMount(destination string) *Generator {
+	dest := path.Clean(destination)
+	mounts := g.Config.Mounts[:0]
+	for _, m := range g.Config.Mounts {
+		if path.Clean(m.Destination) != dest {
+			mounts = append(mounts, m)
+		}
+	}
+	g.Config.Mounts = mounts
+	return g
+}
+
+// ClearMounts removes every mount.
+func (g *Generator) ClearMounts() *Generator {
+	g.Config.Mounts = nil
+	return g
+}
+
+// capabilitySet returns a pointer to the capability set named set of caps,
+// or nil if set is unknown.
+func capabilitySet(caps *specs.LinuxCapabilities, set CapabilitySet) *[]string {
+	switch set {
+	case Bounding:
+		return &caps.Bounding
+	case Effective:
+		return &caps.Effective
+	case Inheritable:
+		return &caps.Inheritable
+	case Permitted:
+		return &caps.Permitted
+	case Ambient:
+		return &caps.Ambient
+	}
+	return nil
+}
+
+// checkCapabilitySets records an error for the unknown sets of sets, and
+// reports whether they are all known.
+func (g *Generator) checkCapabilitySets(sets []CapabilitySet) bool {
+	ok := true
+	for _, set := range sets {
+		if capabilitySet(&specs.LinuxCapabilities{}, set) == nil {
+			g.fail("unknown capability set %q", set)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// normalizeCapability returns the canonical upper-case, "CAP_" prefixed
+// form of capability.
+func normalizeCapability(capability string) string {
+	capability = strings.ToUpper(capability)
+	if !strings.HasPrefix(capability, "CAP_") {
+		capability = "CAP_" + capability
+	}
+	return capability
+}
+
+// AddCapability adds capability to the given sets, or to every set if none
+// is given. The name may omit the "CAP_" prefix. An unknown set is an
+// error.
+func (g *Generator) AddCapability(capability string, sets ...CapabilitySet) *Generator {
+	if !g.checkCapabilitySets(sets) {
+		return g
+	}
+	capability = normalizeCapability(capability)
+	if len(sets) == 0 {
+		sets = AllCapabilitySets
+	}
+	caps := g.initCapabilities()
+	for _, set := range sets {
+		list := capabilitySet(caps, set)
+		found := false
+		for _, c := range *list {
+			if c == capability {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*list = append(*list, capability)
+		}
+	}
+	return g
+}
+
+// DropCapability removes capability from the given sets, or from every set
+// if none is given. The name may omit the "CAP_" prefix. An unknown set is
+// an error.
+func (g *Generator) DropCapability(capability string, sets ...CapabilitySet) *Generator {
+	if !g.checkCapabilitySets(sets) || g.Config.Process == nil || g.Config.Process.Capabilities == nil {
+		return g
+	}
+	capability = normalizeCapability(capability)
+	if len(sets) == 0 {
+		sets = AllCapabilitySets
+	}
+	for _, set := range sets {
+		list := capabilitySet(g.Config.Process.Capabilities, set)
+		kept := (*list)[:0]
+		for _, c := range *list {
+			if c != capability {
+				kept = append(kept, c)
+			}
+		}
+		*list = kept
+	}
+	return g
+}
+
+// AddNamespace adds a namespace of type typ, joining nsPath if it is not
+// empty. An existing namespace of the same type is replaced.
+func (g *Generator) AddNamespace(typ specs.LinuxNamespaceType, nsPath string) *Generator {
+	linux := g.initLinux()
+	ns := specs.LinuxNamespace{Type: typ, Path: nsPath}
+	for i := range linux.Namespaces {
+		if linux.Namespaces[i].Type == typ {
+			linux.Namespaces[i] = ns
+			return g
+		}
+	}
+	linux.Namespaces = append(linux.Namespaces, ns)
+	return g
+}
+
+// RemoveNamespace removes the namespace of type typ.
+func (g *Generator) RemoveNamespace(typ specs.LinuxNamespaceType) *Generator {
+	if g.Config.Linux == nil {
+		return g
+	}
+	namespaces := g.Config.Linux.Namespaces[:0]
+	for _, ns := range g.Config.Linux.Namespaces {
+		if ns.Type != typ {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	g.Config.Linux.Namespaces = namespaces
+	return g
+}
+
+// AddUIDMapping adds a user namespace uid mapping.
+func (g *Generator) AddUIDMapping(containerID, hostID, size uint32) *Generator {
+	linux := g.initLinux()
+	linux.UIDMappings = append(linux.UIDMappings, specs.LinuxIDMapping{ContainerID: containerID, HostID: hostID, Size: size})
+	return g
+}
+
+// AddGIDMapping adds a user namespace gid mapping.
+func (g *Generator) AddGIDMapping(containerID, hostID, size uint32) *Generator {
+	linux := g.initLinux()
+	linux.GIDMappings = append(linux.GIDMappings, specs.LinuxIDMapping{ContainerID: containerID, HostID: hostID, Size: size})
+	return g
+}
+
+// AddDevice adds d, replacing any device with the same path.
+func (g *Generator) AddDevice(d specs.LinuxDevice) *Generator {
+	linux := g.initLinux()
+	for i := range linux.Devices {
+		if linux.Devices[i].Path == d.Path {
+			linux.Devices[i] = d
+			return g
+		}
+	}
+	linux.Devices = append(linux.Devices, d)
+	return g
+}
+
+// RemoveDevice removes the device at devPath.
+func (g *Generator) RemoveDevice(devPath string) *Generator {
+	if g.Config.Linux == nil {
+		return g
+	}
+	devices := g.Config.Linux.Devices[:0]
+	for _, d := range g.Config.Linux.Devices {
+		if d.Path != devPath {
+			devices = append(devices, d)
+		}
+	}
+	g.Config.Linux.Devices = devices
+	return g
+}
+
+// AddSysctl sets the sysctl key to value.
+func (g *Generator) AddSysctl(key, value string) *Generator {
+	linux := g.initLinux()
+	if linux.Sysctl == nil {
+		linux.Sysctl = make(map[string]string)
+	}
+	linux.Sysctl[key] = value
+	return g
+}
+
+// AddMaskedPath masks path inside the container.
+func (g *Generator) AddMaskedPath(p string) *Generator {
+	linux := g.initLinux()
+	linux.MaskedPaths = appendUnique(linux.MaskedPaths, p)
+	return g
+}
+
+// AddReadonlyPath makes path read-only inside the container.
+func (g *Generator) AddReadonlyPath(p string) *Generator {
+	linux := g.initLinux()
+	linux.ReadonlyPaths = appendUnique(linux.ReadonlyPaths, p)
+	return g
+}
+
+// AddHook appends h to the hooks of phase. An unknown phase is an error.
+func (g *Generator) AddHook(phase HookPhase, h specs.Hook) *Generator {
+	if hookList(&specs.Hooks{}, phase) == nil {
+		return g.fail("unknown hook phase %q", phase)
+	}
+	l := hookList(g.initHooks(), phase)
+	*l = append(*l, h)
+	return g
+}
+
+func hookList(h *specs.Hooks, phase HookPhase) *[]specs.Hook {
+	switch phase {
+	case Prestart:
+		return &h.Prestart //nolint:staticcheck // Prestart is deprecated but still valid.
+	case CreateRuntime:
+		return &h.CreateRuntime
+	case CreateContainer:
+		return &h.CreateContainer
+	case StartContainer:
+		return &h.StartContainer
+	case Poststart:
+		return &h.Poststart
+	case Poststop:
+		return &h.Poststop
+	}
+	return nil
+}
+
+// SetMemoryLimit sets the memory limit in bytes.
+func (g *Generator) SetMemoryLimit(limit int64) *Generator {
+	g.initMemory().Limit = &limit
+	return g
+}
+
+// SetMemoryReservation sets the memory soft limit in bytes.
+func (g *Generator) SetMemoryReservation(reservation int64) *Generator {
+	g.initMemory().Reservation = &reservation
+	return g
+}
+
+// SetMemorySwap sets the total memory limit (memory + swap) in bytes.
+func (g *Generator) SetMemorySwap(swap int64) *Generator {
+	g.initMemory().Swap = &swap
+	return g
+}
+
+// SetCPUShares sets the relative CPU weight.
+func (g *Generator) SetCPUShares(shares uint64) *Generator {
+	g.initCPU().Shares = &shares
+	return g
+}
+
+// SetCPUQuota sets the CPU hardcap limit in microseconds per period.
+func (g *Generator) SetCPUQuota(quota int64) *Generator {
+	g.initCPU().Quota = &quota
+	return g
+}
+
+// SetCPUPeriod sets the CPU hardcap period in microseconds.
+func (g *Generator) SetCPUPeriod(period uint64) *Generator {
+	g.initCPU().Period = &period
+	return g
+}
+
+// SetCPUs sets the CPUs the container may run on, e.g. "0-3,7".
+func (g *Generator) SetCPUs(cpus string) *Generator {
+	g.initCPU().Cpus = cpus
+	return g
+}
+
+// SetMems sets the memory nodes the container may use, e.g. "0-1".
+func (g *Generator) SetMems(mems string) *Generator {
+	g.initCPU().Mems = mems
+	return g
+}
+
+// SetPidsLimit sets the maximum number of PIDs.
+func (g *Generator) SetPidsLimit(limit int64) *Generator {
+	g.initPids().Limit = &limit
+	return g
+}
+
+// SetBlockIOWeight sets the block IO weight of the container.
+func (g *Generator) SetBlockIOWeight(weight uint16) *Generator {
+	g.initBlockIO().Weight = &weight
+	return g
+}
+
+// AddDeviceCgroupRule appends rule to the device allowlist.
+func (g *Generator) AddDeviceCgroupRule(rule specs.LinuxDeviceCgroup) *Generator {
+	r := g.initResources()
+	r.Devices = append(r.Devices, rule)
+	return g
+}
+
+// SetUnified sets the cgroup v2 interface file key to value.
+func (g *Generator) SetUnified(key, value string) *Generator {
+	r := g.initResources()
+	if r.Unified == nil {
+		r.Unified = make(map[string]string)
+	}
+	r.Unified[key] = value
+	return g
+}
+
+// SetSeccompDefaultAction sets the action taken for syscalls which match
+// no rule.
+func (g *Generator) SetSeccompDefaultAction(action specs.LinuxSeccompAction) *Generator {
+	g.initSeccomp().DefaultAction = action
+	return g
+}
+
+// AddSeccompArchitecture adds an architecture to the seccomp filter.
+func (g *Generator) AddSeccompArchitecture(arch specs.Arch) *Generator {
+	s := g.initSeccomp()
+	for _, a := range s.Architectures {
+		if a == arch {
+			return g
+		}
+	}
+	s.Architectures = append(s.Architectures, arch)
+	return g
+}
+
+// AddSeccompRule appends a seccomp rule.
+func (g *Generator) AddSeccompRule(rule specs.LinuxSyscall) *Generator {
+	s := g.initSeccomp()
+	s.Syscalls = append(s.Syscalls, rule)
+	return g
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,117 @@
+package generate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestGenerator(t *testing.T) {
+	g := NewGenerator(nil).
+		SetHostname("box").
+		SetRootPath("rootfs").
+		SetProcessArgs("sh", "-c", "true").
+		AddProcessEnv("PATH", "/bin").
+		AddProcessEnv("TERM", "xterm").
+		AddProcessEnv("PATH", "/usr/bin").
+		AddProcessRlimit("RLIMIT_NOFILE", 1024, 1024).
+		AddProcessRlimit("RLIMIT_NOFILE", 4096, 2048).
+		AddMount(specs.Mount{Destination: "/data", Type: "tmpfs"}).
+		AddMount(specs.Mount{Destination: "/data/", Type: "bind"}).
+		AddMount(specs.Mount{Destination: "/tmp", Type: "tmpfs"}).
+		RemoveMount("/tmp").
+		AddNamespace(specs.NetworkNamespace, "").
+		AddNamespace(specs.NetworkNamespace, "/proc/1/ns/net").
+		AddNamespace(specs.PIDNamespace, "").
+		RemoveNamespace(specs.PIDNamespace).
+		AddMaskedPath("/proc/kcore").
+		AddMaskedPath("/proc/kcore").
+		SetMemoryLimit(1 << 20).
+		SetPidsLimit(100)
+
+	c := g.Config
+	if c.Version != specs.Version || c.Hostname != "box" || c.Root.Path != "rootfs" {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+	if want := []string{"PATH=/usr/bin", "TERM=xterm"}; !reflect.DeepEqual(c.Process.Env, want) {
+		t.Errorf("env %v, want %v", c.Process.Env, want)
+	}
+	if want := []specs.POSIXRlimit{{Type: "RLIMIT_NOFILE", Hard: 4096, Soft: 2048}}; !reflect.DeepEqual(c.Process.Rlimits, want) {
+		t.Errorf("rlimits %v, want %v", c.Process.Rlimits, want)
+	}
+	if want := []specs.Mount{{Destination: "/data/", Type: "bind"}}; !reflect.DeepEqual(c.Mounts, want) {
+		t.Errorf("mounts %v, want %v", c.Mounts, want)
+	}
+	if want := []specs.LinuxNamespace{{Type: specs.NetworkNamespace, Path: "/proc/1/ns/net"}}; !reflect.DeepEqual(c.Linux.Namespaces, want) {
+		t.Errorf("namespaces %v, want %v", c.Linux.Namespaces, want)
+	}
+	if want := []string{"/proc/kcore"}; !reflect.DeepEqual(c.Linux.MaskedPaths, want) {
+		t.Errorf("masked paths %v, want %v", c.Linux.MaskedPaths, want)
+	}
+	if *c.Linux.Resources.Memory.Limit != 1<<20 || *c.Linux.Resources.Pids.Limit != 100 {
+		t.Errorf("unexpected resources %+v", c.Linux.Resources)
+	}
+}
+
+func TestGeneratorCapabilities(t *testing.T) {
+	g := NewGenerator(nil).
+		AddCapability("net_admin").
+		AddCapability("CAP_SYS_TIME", Bounding, Permitted).
+		AddCapability("sys_time", Bounding).
+		DropCapability("net_admin", Ambient)
+	caps := g.Config.Process.Capabilities
+	want := &specs.LinuxCapabilities{
+		Bounding:    []string{"CAP_NET_ADMIN", "CAP_SYS_TIME"},
+		Effective:   []string{"CAP_NET_ADMIN"},
+		Inheritable: []string{"CAP_NET_ADMIN"},
+		Permitted:   []string{"CAP_NET_ADMIN", "CAP_SYS_TIME"},
+		Ambient:     []string{},
+	}
+	if !reflect.DeepEqual(caps, want) {
+		t.Errorf("capabilities %+v, want %+v", caps, want)
+	}
+
+	empty := NewGenerator(nil).DropCapability("chown")
+	if empty.Config.Process != nil {
+		t.Error("DropCapability created the process")
+	}
+}
+
+func TestGeneratorAddHook(t *testing.T) {
+	spec, err := NewGenerator(nil).
+		AddHook(CreateRuntime, specs.Hook{Path: "/bin/a"}).
+		AddHook(Poststop, specs.Hook{Path: "/bin/b"}).
+		Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &specs.Hooks{
+		CreateRuntime: []specs.Hook{{Path: "/bin/a"}},
+		Poststop:      []specs.Hook{{Path: "/bin/b"}},
+	}
+	if !reflect.DeepEqual(spec.Hooks, want) {
+		t.Errorf("hooks %+v, want %+v", spec.Hooks, want)
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		call func(g *Generator) *Generator
+	}{
+		{"hook phase", func(g *Generator) *Generator { return g.AddHook("prestop", specs.Hook{Path: "/bin/a"}) }},
+		{"add capability", func(g *Generator) *Generator { return g.AddCapability("chown", Bounding, "current") }},
+		{"drop capability", func(g *Generator) *Generator { return g.DropCapability("chown", "current") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := tc.call(NewGenerator(nil)).SetHostname("box").Spec()
+			if err == nil {
+				t.Error("the call succeeded")
+			}
+			if want := (&specs.Spec{Version: specs.Version, Hostname: "box"}); !reflect.DeepEqual(spec, want) {
+				t.Errorf("got %+v, want %+v", spec, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package generate
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// DefaultCapabilities is the minimal capability set granted to the container
+// process in every set but the ambient one.
+var DefaultCapabilities = []string{
+	"CAP_AUDIT_WRITE",
+	"CAP_KILL",
+	"CAP_NET_BIND_SERVICE",
+}
+
+// DefaultMaskedPaths are the paths masked in Linux containers.
+var DefaultMaskedPaths = []string{
+	"/proc/acpi",
+	"/proc/asound",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/sys/firmware",
+	"/sys/devices/virtual/powercap",
+}
+
+// DefaultReadonlyPaths are the paths made read-only in Linux containers.
+var DefaultReadonlyPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// DefaultLinuxMounts returns the filesystems which should be made available
+// in each Linux container.
+func DefaultLinuxMounts() []specs.Mount {
+	return []specs.Mount{
+		{
+			Destination: "/proc",
+			Type:        "proc",
+			Source:      "proc",
+		},
+		{
+			Destination: "/dev",
+			Type:        "tmpfs",
+			Source:      "tmpfs",
+			Options:     []string{"nosuid", "strictatime", "mode=755", "size=65536k"},
+		},
+		{
+			Destination: "/dev/pts",
+			Type:        "devpts",
+			Source:      "devpts",
+			Options:     []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666", "mode=0620", "gid=5"},
+		},
+		{
+			Destination: "/dev/shm",
+			Type:        "tmpfs",
+			Source:      "shm",
+			Options:     []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"},
+		},
+		{
+			Destination: "/dev/mqueue",
+			Type:        "mqueue",
+			Source:      "mqueue",
+			Options:     []string{"nosuid", "noexec", "nodev"},
+		},
+		{
+			Destination: "/sys",
+			Type:        "sysfs",
+			Source:      "sysfs",
+			Options:     []string{"nosuid", "noexec", "nodev", "ro"},
+		},
+		{
+			Destination: "/sys/fs/cgroup",
+			Type:        "cgroup",
+			Source:      "cgroup",
+			Options:     []string{"nosuid", "noexec", "nodev", "relatime", "ro"},
+		},
+	}
+}
+
+// DefaultLinuxDeviceRules returns the device allowlist granting access to
+// the default devices the runtime supplies: everything is denied, then
+// mknod of any device and access to /dev/null, /dev/zero, /dev/full,
+// /dev/random, /dev/urandom, /dev/tty, /dev/console, /dev/ptmx and
+// /dev/pts/* are allowed.
+func DefaultLinuxDeviceRules() []specs.LinuxDeviceCgroup {
+	rules := []specs.LinuxDeviceCgroup{
+		{Allow: false, Access: "rwm"},
+		{Allow: true, Type: "c", Access: "m"},
+		{Allow: true, Type: "b", Access: "m"},
+	}
+	for _, d := range [][2]int64{
+		{1, 3}, // /dev/null
+		{1, 5}, // /dev/zero
+		{1, 7}, // /dev/full
+		{1, 8}, // /dev/random
+		{1, 9}, // /dev/urandom
+		{5, 0}, // /dev/tty
+		{5, 1}, // /dev/console
+		{5, 2}, // /dev/ptmx
+	} {
+		rules = append(rules, specs.LinuxDeviceCgroup{Allow: true, Type: "c", Major: int64Ptr(d[0]), Minor: int64Ptr(d[1]), Access: "rwm"})
+	}
+	// /dev/pts/*
+	return append(rules, specs.LinuxDeviceCgroup{Allow: true, Type: "c", Major: int64Ptr(136), Access: "rwm"})
+}
+
+func newLinux(o *options) *specs.Spec {
+	proc := posixProcess(o)
+	proc.NoNewPrivileges = true
+	proc.Capabilities = &specs.LinuxCapabilities{
+		Bounding:  append([]string(nil), DefaultCapabilities...),
+		Effective: append([]string(nil), DefaultCapabilities...),
+		Permitted: append([]string(nil), DefaultCapabilities...),
+	}
+	proc.Rlimits = []specs.POSIXRlimit{
+		{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024},
+	}
+
+	spec := &specs.Spec{
+		Root: &specs.Root{
+			Path:     "rootfs",
+			Readonly: true,
+		},
+		Process: proc,
+		Mounts:  DefaultLinuxMounts(),
+		Linux: &specs.Linux{
+			MaskedPaths:   append([]string(nil), DefaultMaskedPaths...),
+			ReadonlyPaths: append([]string(nil), DefaultReadonlyPaths...),
+			Resources: &specs.LinuxResources{
+				Devices: DefaultLinuxDeviceRules(),
+			},
+			Namespaces: []specs.LinuxNamespace{
+				{Type: specs.PIDNamespace},
+				{Type: specs.NetworkNamespace},
+				{Type: specs.IPCNamespace},
+				{Type: specs.UTSNamespace},
+				{Type: specs.MountNamespace},
+				{Type: specs.CgroupNamespace},
+			},
+		},
+	}
+
+	if o.uidMappings != nil || o.gidMappings != nil {
+		spec.Linux.UIDMappings = o.uidMappings
+		spec.Linux.GIDMappings = o.gidMappings
+		spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+	}
+	if o.rootless {
+		toRootless(spec)
+	}
+	return spec
+}
+
+// toRootless converts spec so that it can be run by an unprivileged user:
+// the current user is mapped to root in a new user namespace, sysfs is
+// bind mounted from the host, ownership options which require unmapped ids
+// are dropped and the cgroup settings are removed.
+func toRootless(spec *specs.Spec) {
+	if spec.Linux.UIDMappings == nil && spec.Linux.GIDMappings == nil {
+		spec.Linux.UIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(os.Geteuid()), Size: 1}}
+		spec.Linux.GIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(os.Getegid()), Size: 1}}
+	}
+	hasUserNS := false
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.UserNamespace {
+			hasUserNS = true
+		}
+	}
+	if !hasUserNS {
+		spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+	}
+
+	for i, m := range spec.Mounts {
+		if path.Clean(m.Destination) == "/sys" {
+			spec.Mounts[i] = specs.Mount{
+				Destination: "/sys",
+				Type:        "none",
+				Source:      "/sys",
+				Options:     []string{"rbind", "nosuid", "noexec", "nodev", "ro"},
+			}
+			continue
+		}
+		var opts []string
+		for _, opt := range m.Options {
+			if !strings.HasPrefix(opt, "uid=") && !strings.HasPrefix(opt, "gid=") {
+				opts = append(opts, opt)
+			}
+		}
+		spec.Mounts[i].Options = opts
+	}
+
+	spec.Linux.Resources = nil
+}
@@ -0,0 +1,20 @@
+package generate
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+func newSolaris(o *options) *specs.Spec {
+	proc := posixProcess(o)
+	proc.Rlimits = []specs.POSIXRlimit{
+		{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024},
+	}
+	return &specs.Spec{
+		Root: &specs.Root{
+			Path: "rootfs",
+		},
+		Process: proc,
+		Solaris: &specs.Solaris{
+			Milestone: "svc:/milestone/container:default",
+			LimitPriv: "default",
+		},
+	}
+}
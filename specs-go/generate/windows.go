@@ -0,0 +1,21 @@
+package generate
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+func newWindows(o *options) *specs.Spec {
+	return &specs.Spec{
+		Process: &specs.Process{
+			Terminal: o.terminal,
+			User: specs.User{
+				Username: "ContainerUser",
+			},
+			Args: []string{"cmd"},
+			Cwd:  `C:\`,
+		},
+		Windows: &specs.Windows{
+			Network: &specs.WindowsNetwork{
+				AllowUnqualifiedDNSQuery: true,
+			},
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package generate
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+func newZOS(o *options) *specs.Spec {
+	proc := posixProcess(o)
+	proc.NoNewPrivileges = true
+	proc.Rlimits = []specs.POSIXRlimit{
+		{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024},
+	}
+	return &specs.Spec{
+		Root: &specs.Root{
+			Path: "rootfs",
+		},
+		Process: proc,
+		Mounts: []specs.Mount{
+			{
+				Destination: "/proc",
+				Type:        "proc",
+				Source:      "proc",
+			},
+		},
+		ZOS: &specs.ZOS{
+			Namespaces: []specs.ZOSNamespace{
+				{Type: specs.ZOSPIDNamespace},
+				{Type: specs.ZOSIPCNamespace},
+				{Type: specs.ZOSUTSNamespace},
+				{Type: specs.ZOSMountNamespace},
+			},
+		},
+	}
+}
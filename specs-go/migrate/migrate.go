@@ -0,0 +1,230 @@
+// Package migrate rewrites configurations written for older 1.x versions of
+// the specification to the version supported by the specs-go package.
+//
+// Migration operates on the raw JSON document, so that fields unknown to
+// specs-go are preserved. Every transformation applied is reported, so that
+// stored configurations can be reviewed before they are replaced.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/version"
+)
+
+// Transformation describes a single rewrite of a configuration.
+type Transformation struct {
+	// Path is the JSON path of the rewritten value.
+	Path string `json:"path"`
+	// Msg describes the rewrite.
+	Msg string `json:"msg"`
+}
+
+func (t Transformation) String() string {
+	return t.Path + ": " + t.Msg
+}
+
+// Migrate rewrites the configuration data to the version supported by
+// specs-go and returns the rewritten configuration along with the
+// transformations applied. Deprecated constructs are replaced by their
+// modern equivalents, or dropped when they have none:
+//
+//   - hooks.prestart is prepended to hooks.createRuntime, which runs at
+//     the same point of the lifecycle and in the same namespace.
+//   - linux.resources.memory.kernel is removed.
+//   - relative Linux mount destinations are made absolute.
+//
+// The shapes used by the 1.0.0 release candidates are also rewritten: a
+// capability list becomes a capability object, the name of a seccomp rule
+// becomes a list of names and the platform object is removed.
+//
+// The document must carry a 1.x ociVersion which is not newer than the
+// version supported by specs-go.
+func Migrate(data []byte) ([]byte, []Transformation, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if doc == nil {
+		return nil, nil, fmt.Errorf("invalid configuration: not a JSON object")
+	}
+
+	m := &migration{doc: doc}
+	if err := m.checkVersion(); err != nil {
+		return nil, nil, err
+	}
+	m.platform()
+	m.capabilities()
+	m.syscallNames()
+	m.prestart()
+	m.kernelMemory()
+	m.mountDestinations()
+	if m.doc["ociVersion"] != specs.Version {
+		m.report("ociVersion", "bumped from %q to %q", m.doc["ociVersion"], specs.Version)
+		m.doc["ociVersion"] = specs.Version
+	}
+
+	out, err := json.MarshalIndent(m.doc, "", "\t")
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(out, '\n'), m.changes, nil
+}
+
+type migration struct {
+	doc     map[string]interface{}
+	changes []Transformation
+}
+
+func (m *migration) report(p, format string, args ...interface{}) {
+	m.changes = append(m.changes, Transformation{Path: p, Msg: fmt.Sprintf(format, args...)})
+}
+
+// object returns the object found by following keys from the root of the
+// document, or nil if there is none.
+func (m *migration) object(keys ...string) map[string]interface{} {
+	obj := m.doc
+	for _, k := range keys {
+		next, ok := obj[k].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		obj = next
+	}
+	return obj
+}
+
+func (m *migration) checkVersion() error {
+	s, ok := m.doc["ociVersion"].(string)
+	if !ok {
+		return fmt.Errorf("ociVersion is missing or not a string")
+	}
+	v, err := version.Parse(s)
+	if err != nil {
+		return err
+	}
+	cur := version.Current()
+	if v.Major != cur.Major {
+		return fmt.Errorf("version %s is incompatible with the supported major version %d", v, cur.Major)
+	}
+	if cur.LessThan(v) {
+		return fmt.Errorf("version %s is newer than the supported version %s", v, cur)
+	}
+	return nil
+}
+
+func (m *migration) platform() {
+	if p, ok := m.doc["platform"]; ok {
+		delete(m.doc, "platform")
+		m.report("platform", "removed obsolete platform object %s", encode(p))
+	}
+}
+
+var capabilitySets = []string{"bounding", "effective", "inheritable", "permitted"}
+
+func (m *migration) capabilities() {
+	proc := m.object("process")
+	if proc == nil {
+		return
+	}
+	caps, ok := proc["capabilities"].([]interface{})
+	if !ok {
+		return
+	}
+	obj := make(map[string]interface{})
+	for _, set := range capabilitySets {
+		obj[set] = append([]interface{}(nil), caps...)
+	}
+	proc["capabilities"] = obj
+	m.report("process.capabilities", "converted capability list to the %s sets", strings.Join(capabilitySets, ", "))
+}
+
+func (m *migration) syscallNames() {
+	seccomp := m.object("linux", "seccomp")
+	if seccomp == nil {
+		return
+	}
+	syscalls, _ := seccomp["syscalls"].([]interface{})
+	for i, s := range syscalls {
+		rule, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := rule["name"]
+		if !ok {
+			continue
+		}
+		delete(rule, "name")
+		names, _ := rule["names"].([]interface{})
+		rule["names"] = append([]interface{}{name}, names...)
+		m.report(fmt.Sprintf("linux.seccomp.syscalls[%d].name", i), "moved %s to names", encode(name))
+	}
+}
+
+func (m *migration) prestart() {
+	hooks := m.object("hooks")
+	if hooks == nil {
+		return
+	}
+	prestart, ok := hooks["prestart"]
+	if !ok {
+		return
+	}
+	delete(hooks, "prestart")
+	list, _ := prestart.([]interface{})
+	if len(list) == 0 {
+		m.report("hooks.prestart", "removed empty deprecated prestart hooks")
+		return
+	}
+	// Prestart hooks run in the runtime namespace, before the createRuntime
+	// hooks.
+	existing, _ := hooks["createRuntime"].([]interface{})
+	hooks["createRuntime"] = append(list, existing...)
+	m.report("hooks.prestart", "moved %d deprecated prestart hooks to the start of hooks.createRuntime", len(list))
+}
+
+func (m *migration) kernelMemory() {
+	mem := m.object("linux", "resources", "memory")
+	if mem == nil {
+		return
+	}
+	if k, ok := mem["kernel"]; ok {
+		delete(mem, "kernel")
+		m.report("linux.resources.memory.kernel", "removed deprecated kernel memory limit %s, which is not supported by cgroup v2", encode(k))
+	}
+}
+
+func (m *migration) mountDestinations() {
+	if m.object("linux") == nil {
+		return
+	}
+	mounts, _ := m.doc["mounts"].([]interface{})
+	for i, e := range mounts {
+		mnt, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		dest, ok := mnt["destination"].(string)
+		if !ok || dest == "" || strings.HasPrefix(dest, "/") {
+			continue
+		}
+		abs := path.Join("/", dest)
+		mnt["destination"] = abs
+		m.report(fmt.Sprintf("mounts[%d].destination", i), "made deprecated relative destination %q absolute: %q", dest, abs)
+	}
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
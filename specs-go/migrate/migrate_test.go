@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestMigrate(t *testing.T) {
+	in := `{
+		"ociVersion": "1.0.0-rc2",
+		"platform": {"os": "linux", "arch": "amd64"},
+		"x-custom": {"big": 12345678901234567890},
+		"process": {"capabilities": ["CAP_KILL"]},
+		"hooks": {"prestart": [{"path": "/a"}], "createRuntime": [{"path": "/b"}]},
+		"mounts": [{"destination": "tmp", "type": "tmpfs"}, {"destination": "/proc", "type": "proc"}],
+		"linux": {
+			"resources": {"memory": {"kernel": 1024, "limit": 10}},
+			"seccomp": {"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"name": "read", "names": ["write"], "action": "SCMP_ACT_ERRNO"}]}
+		}
+	}`
+	out, changes, err := Migrate([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	wantPaths := []string{
+		"platform",
+		"process.capabilities",
+		"linux.seccomp.syscalls[0].name",
+		"hooks.prestart",
+		"linux.resources.memory.kernel",
+		"mounts[0].destination",
+		"ociVersion",
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("transformations of %q, want %q", paths, wantPaths)
+	}
+
+	if !strings.Contains(string(out), "12345678901234567890") {
+		t.Error("unknown fields are not preserved")
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(out, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Version != specs.Version {
+		t.Errorf("version %q, want %q", spec.Version, specs.Version)
+	}
+	wantCaps := &specs.LinuxCapabilities{
+		Bounding:    []string{"CAP_KILL"},
+		Effective:   []string{"CAP_KILL"},
+		Inheritable: []string{"CAP_KILL"},
+		Permitted:   []string{"CAP_KILL"},
+	}
+	if !reflect.DeepEqual(spec.Process.Capabilities, wantCaps) {
+		t.Errorf("capabilities %+v, want %+v", spec.Process.Capabilities, wantCaps)
+	}
+	if want := []specs.Hook{{Path: "/a"}, {Path: "/b"}}; !reflect.DeepEqual(spec.Hooks.CreateRuntime, want) {
+		t.Errorf("createRuntime hooks %+v, want %+v", spec.Hooks.CreateRuntime, want)
+	}
+	if spec.Hooks.Prestart != nil { //nolint:staticcheck // Prestart is deprecated but still valid.
+		t.Error("prestart hooks are kept")
+	}
+	if spec.Mounts[0].Destination != "/tmp" || spec.Mounts[1].Destination != "/proc" {
+		t.Errorf("mounts %+v", spec.Mounts)
+	}
+	if want := []string{"read", "write"}; !reflect.DeepEqual(spec.Linux.Seccomp.Syscalls[0].Names, want) {
+		t.Errorf("syscall names %v, want %v", spec.Linux.Seccomp.Syscalls[0].Names, want)
+	}
+	if mem := spec.Linux.Resources.Memory; mem.Kernel != nil || *mem.Limit != 10 { //nolint:staticcheck // Kernel is deprecated.
+		t.Errorf("memory %+v", mem)
+	}
+}
+
+func TestMigrateCurrent(t *testing.T) {
+	in := `{"ociVersion": "` + specs.Version + `", "process": {"cwd": "/", "args": ["sh"]}}`
+	_, changes, err := Migrate([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("unexpected transformations %v", changes)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	for _, in := range []string{
+		`{"ociVersion": "2.0.0"}`,
+		`{"ociVersion": "1.99.0"}`,
+		`{"ociVersion": 1}`,
+		`{}`,
+		`null`,
+		`[]`,
+	} {
+		if _, _, err := Migrate([]byte(in)); err == nil {
+			t.Errorf("Migrate(%s) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package mount translates the mounts of a configuration into the arguments
+// of the Linux mount system calls, and builds mounts from the mount tables
+// of a host.
+package mount
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Flags of mount(2), from <linux/mount.h>.
+const (
+	msRdonly      = 0x1
+	msNosuid      = 0x2
+	msNodev       = 0x4
+	msNoexec      = 0x8
+	msSynchronous = 0x10
+	msRemount     = 0x20
+	msMandlock    = 0x40
+	msDirsync     = 0x80
+	msNosymfollow = 0x100
+	msNoatime     = 0x400
+	msNodiratime  = 0x800
+	msBind        = 0x1000
+	msRec         = 0x4000
+	msSilent      = 0x8000
+	msUnbindable  = 0x20000
+	msPrivate     = 0x40000
+	msSlave       = 0x80000
+	msShared      = 0x100000
+	msRelatime    = 0x200000
+	msIversion    = 0x800000
+	msStrictatime = 0x1000000
+	msLazytime    = 0x2000000
+)
+
+// Attributes of mount_setattr(2), from <linux/mount.h>.
+const (
+	attrRdonly      = 0x1
+	attrNosuid      = 0x2
+	attrNodev       = 0x4
+	attrNoexec      = 0x8
+	attrAtimeMask   = 0x70
+	attrRelatime    = 0x0
+	attrNoatime     = 0x10
+	attrStrictatime = 0x20
+	attrNodiratime  = 0x80
+	attrNosymfollow = 0x200000
+)
+
+// Options are the mount options of a mount, sorted by the system call
+// applying them.
+type Options struct {
+	// Flags are the MS_* flags to pass to mount(2).
+	Flags uint64
+	// ClearFlags are the MS_* flags cleared by options such as "rw" or
+	// "suid", which runtimes remounting a bind mount use to tell the flags
+	// cleared on purpose from those left unset.
+	ClearFlags uint64
+	// Propagation is the propagation type, such as MS_PRIVATE|MS_REC, to
+	// set with a separate mount(2) call; 0 when not set.
+	Propagation uint64
+	// RecursiveSet and RecursiveClear are the MOUNT_ATTR_* attributes to
+	// set and clear with mount_setattr(2) and AT_RECURSIVE, from options
+	// such as "rro". Changing the access time attributes clears
+	// MOUNT_ATTR__ATIME.
+	RecursiveSet   uint64
+	RecursiveClear uint64
+	// IDMap and RecursiveIDMap are set by "idmap" and "ridmap", for the
+	// mount to be ID-mapped with mount_setattr(2) and MOUNT_ATTR_IDMAP.
+	IDMap          bool
+	RecursiveIDMap bool
+	// TmpCopyUp is set by "tmpcopyup", for the contents of the mount
+	// point to be copied to the tmpfs mounted on it.
+	TmpCopyUp bool
+	// Data holds the filesystem-specific options, for the data argument of
+	// mount(2), separated by commas.
+	Data string
+}
+
+type flagOption struct {
+	flag  uint64
+	clear bool
+}
+
+var flagOptions = map[string]flagOption{
+	"async":         {msSynchronous, true},
+	"atime":         {msNoatime, true},
+	"bind":          {msBind, false},
+	"defaults":      {0, false},
+	"dev":           {msNodev, true},
+	"diratime":      {msNodiratime, true},
+	"dirsync":       {msDirsync, false},
+	"exec":          {msNoexec, true},
+	"iversion":      {msIversion, false},
+	"lazytime":      {msLazytime, false},
+	"loud":          {msSilent, true},
+	"mand":          {msMandlock, false},
+	"noatime":       {msNoatime, false},
+	"nodev":         {msNodev, false},
+	"nodiratime":    {msNodiratime, false},
+	"noexec":        {msNoexec, false},
+	"noiversion":    {msIversion, true},
+	"nolazytime":    {msLazytime, true},
+	"nomand":        {msMandlock, true},
+	"norelatime":    {msRelatime, true},
+	"nostrictatime": {msStrictatime, true},
+	"nosuid":        {msNosuid, false},
+	"nosymfollow":   {msNosymfollow, false},
+	"rbind":         {msBind | msRec, false},
+	"relatime":      {msRelatime, false},
+	"remount":       {msRemount, false},
+	"ro":            {msRdonly, false},
+	"rw":            {msRdonly, true},
+	"silent":        {msSilent, false},
+	"strictatime":   {msStrictatime, false},
+	"suid":          {msNosuid, true},
+	"symfollow":     {msNosymfollow, true},
+	"sync":          {msSynchronous, false},
+}
+
+// atimeFlags are the flags selecting how access times are updated, of
+// which a mount has one.
+const atimeFlags = msNoatime | msRelatime | msStrictatime
+
+var propagationOptions = map[string]uint64{
+	"private":     msPrivate,
+	"rprivate":    msPrivate | msRec,
+	"shared":      msShared,
+	"rshared":     msShared | msRec,
+	"slave":       msSlave,
+	"rslave":      msSlave | msRec,
+	"unbindable":  msUnbindable,
+	"runbindable": msUnbindable | msRec,
+}
+
+type attrOption struct {
+	attr  uint64
+	clear bool
+	// atime is set for the options selecting the access time attribute,
+	// which is set as a whole.
+	atime bool
+}
+
+var attrOptions = map[string]attrOption{
+	"rro":            {attr: attrRdonly},
+	"rrw":            {attr: attrRdonly, clear: true},
+	"rnosuid":        {attr: attrNosuid},
+	"rsuid":          {attr: attrNosuid, clear: true},
+	"rnodev":         {attr: attrNodev},
+	"rdev":           {attr: attrNodev, clear: true},
+	"rnoexec":        {attr: attrNoexec},
+	"rexec":          {attr: attrNoexec, clear: true},
+	"rnodiratime":    {attr: attrNodiratime},
+	"rdiratime":      {attr: attrNodiratime, clear: true},
+	"rnosymfollow":   {attr: attrNosymfollow},
+	"rsymfollow":     {attr: attrNosymfollow, clear: true},
+	"ratime":         {attr: attrRelatime, atime: true},
+	"rnoatime":       {attr: attrNoatime, atime: true},
+	"rrelatime":      {attr: attrRelatime, atime: true},
+	"rnorelatime":    {attr: attrRelatime, atime: true},
+	"rstrictatime":   {attr: attrStrictatime, atime: true},
+	"rnostrictatime": {attr: attrRelatime, atime: true},
+}
+
+// Recognized returns the options recognized by Parse, sorted, as listed by
+// the mountOptions of the features of a runtime.
+func Recognized() []string {
+	var list []string
+	for name := range flagOptions {
+		list = append(list, name)
+	}
+	for name := range propagationOptions {
+		list = append(list, name)
+	}
+	for name := range attrOptions {
+		list = append(list, name)
+	}
+	list = append(list, "idmap", "ridmap", "tmpcopyup")
+	sort.Strings(list)
+	return list
+}
+
+// Parse parses the options of a mount. Options which are not recognized
+// are filesystem-specific, and kept in the data of the mount.
+//
+// Options setting and clearing the same flag, such as "ro" and "rw", are
+// contradictory, and so are different access time modes, such as
+// "noatime" and "strictatime", different propagation types, and "idmap"
+// along with "ridmap". Recursive options are checked likewise.
+func Parse(options []string) (*Options, error) {
+	o := &Options{}
+	// flagBy and attrBy hold the option setting or clearing each flag
+	// and attribute.
+	flagBy := make(map[uint64]string)
+	attrBy := make(map[uint64]string)
+	var atimeBy, ratimeBy, propagationBy string
+	var data []string
+	conflict := func(a, b string) error {
+		return fmt.Errorf("contradictory mount options %q and %q", a, b)
+	}
+	for _, opt := range options {
+		if f, ok := flagOptions[opt]; ok {
+			for bit := uint64(1); bit != 0 && bit <= f.flag; bit <<= 1 {
+				if f.flag&bit == 0 {
+					continue
+				}
+				if prev, ok := flagBy[bit]; ok && flagOptions[prev].clear != f.clear {
+					return nil, conflict(prev, opt)
+				}
+				flagBy[bit] = opt
+			}
+			if f.clear {
+				o.ClearFlags |= f.flag
+				continue
+			}
+			if f.flag&atimeFlags != 0 {
+				if atimeBy != "" && flagOptions[atimeBy].flag != f.flag {
+					return nil, conflict(atimeBy, opt)
+				}
+				atimeBy = opt
+			}
+			o.Flags |= f.flag
+			continue
+		}
+		if p, ok := propagationOptions[opt]; ok {
+			if propagationBy != "" && o.Propagation != p {
+				return nil, conflict(propagationBy, opt)
+			}
+			o.Propagation, propagationBy = p, opt
+			continue
+		}
+		if a, ok := attrOptions[opt]; ok {
+			if a.atime {
+				if ratimeBy != "" && (attrOptions[ratimeBy].attr != a.attr || negates(ratimeBy, opt)) {
+					return nil, conflict(ratimeBy, opt)
+				}
+				ratimeBy = opt
+				o.RecursiveClear |= attrAtimeMask
+				o.RecursiveSet |= a.attr
+				continue
+			}
+			if prev, ok := attrBy[a.attr]; ok && attrOptions[prev].clear != a.clear {
+				return nil, conflict(prev, opt)
+			}
+			attrBy[a.attr] = opt
+			if a.clear {
+				o.RecursiveClear |= a.attr
+			} else {
+				o.RecursiveSet |= a.attr
+			}
+			continue
+		}
+		switch opt {
+		case "idmap":
+			o.IDMap = true
+		case "ridmap":
+			o.RecursiveIDMap = true
+		case "tmpcopyup":
+			o.TmpCopyUp = true
+		default:
+			data = append(data, opt)
+		}
+	}
+	if o.IDMap && o.RecursiveIDMap {
+		return nil, conflict("idmap", "ridmap")
+	}
+	o.Data = strings.Join(data, ",")
+	return o, nil
+}
+
+// negates reports whether one of the recursive options a and b is the
+// negation of the other, such as "rrelatime" and "rnorelatime".
+func negates(a, b string) bool {
+	return "rno"+strings.TrimPrefix(a, "r") == b || "rno"+strings.TrimPrefix(b, "r") == a
+}
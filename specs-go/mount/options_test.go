@@ -0,0 +1,114 @@
+package mount
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options []string
+		want    Options
+	}{
+		{name: "none"},
+		{name: "defaults", options: []string{"defaults"}},
+		{
+			name:    "flags",
+			options: []string{"ro", "nosuid", "nodev", "noexec"},
+			want:    Options{Flags: msRdonly | msNosuid | msNodev | msNoexec},
+		},
+		{
+			name:    "cleared flags",
+			options: []string{"rw", "suid", "exec"},
+			want:    Options{ClearFlags: msRdonly | msNosuid | msNoexec},
+		},
+		{
+			name:    "repeated",
+			options: []string{"ro", "noatime", "ro", "noatime"},
+			want:    Options{Flags: msRdonly | msNoatime},
+		},
+		{
+			name:    "bind",
+			options: []string{"rbind", "rprivate"},
+			want:    Options{Flags: msBind | msRec, Propagation: msPrivate | msRec},
+		},
+		{
+			name:    "data",
+			options: []string{"nosuid", "mode=755", "size=65536k", "strictatime"},
+			want:    Options{Flags: msNosuid | msStrictatime, Data: "mode=755,size=65536k"},
+		},
+		{
+			name:    "recursive",
+			options: []string{"rro", "rnosuid", "rdev", "rnoatime"},
+			want:    Options{RecursiveSet: attrRdonly | attrNosuid | attrNoatime, RecursiveClear: attrNodev | attrAtimeMask},
+		},
+		{
+			name:    "recursive relatime",
+			options: []string{"rrelatime", "ratime"},
+			want:    Options{RecursiveSet: attrRelatime, RecursiveClear: attrAtimeMask},
+		},
+		{
+			name:    "idmap and copy up",
+			options: []string{"bind", "idmap", "tmpcopyup"},
+			want:    Options{Flags: msBind, IDMap: true, TmpCopyUp: true},
+		},
+		{
+			name:    "recursive idmap",
+			options: []string{"ridmap"},
+			want:    Options{RecursiveIDMap: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, options := range [][]string{
+		{"ro", "rw"},
+		{"nosuid", "suid"},
+		{"noatime", "atime"},
+		{"noatime", "strictatime"},
+		{"relatime", "norelatime"},
+		{"private", "rshared"},
+		{"idmap", "ridmap"},
+		{"rro", "rrw"},
+		{"rnoexec", "rexec"},
+		{"rnoatime", "rstrictatime"},
+		{"rrelatime", "rnorelatime"},
+		{"rstrictatime", "rnostrictatime"},
+	} {
+		if o, err := Parse(options); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", options, o)
+		}
+	}
+}
+
+func TestRecognized(t *testing.T) {
+	list := Recognized()
+	if !sort.StringsAreSorted(list) {
+		t.Errorf("%q is not sorted", list)
+	}
+	for i, opt := range list {
+		if i > 0 && list[i-1] == opt {
+			t.Errorf("%q is listed twice", opt)
+		}
+		o, err := Parse([]string{opt})
+		if err != nil {
+			t.Errorf("Parse(%q): %v", opt, err)
+			continue
+		}
+		if o.Data != "" {
+			t.Errorf("%q is parsed as filesystem data", opt)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Entry is a mount of a mount table, such as /etc/fstab or
+// /proc/self/mountinfo.
+type Entry struct {
+	// ID and ParentID identify the mount and its parent, in mountinfo.
+	ID       int
+	ParentID int
+	// Source is the mounted device or filesystem, such as "/dev/sda1",
+	// "UUID=..." or "tmpfs".
+	Source string
+	// Root is the directory of the filesystem mounted, in mountinfo.
+	Root string
+	// Target is the mount point.
+	Target string
+	Type   string
+	// Options are the options of the mount, followed, in mountinfo, by
+	// those of its filesystem.
+	Options []string
+}
+
+// ParseFstab parses a table of filesystems in the format of /etc/fstab.
+// Swap areas, and filesystems not mounted at boot with "noauto", are
+// skipped. Options only used by mount(8) and other tools, such as "nofail"
+// or "x-systemd.automount", are removed. Sources given by UUID or LABEL
+// are returned as they are.
+func ParseFstab(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		f := strings.Fields(text)
+		if len(f) < 3 {
+			return nil, fmt.Errorf("fstab line %d: expected at least 3 fields, got %d", line, len(f))
+		}
+		e := Entry{Source: unescape(f[0]), Target: unescape(f[1]), Type: f[2]}
+		if e.Type == "swap" {
+			continue
+		}
+		noauto := false
+		if len(f) > 3 {
+			for _, opt := range strings.Split(f[3], ",") {
+				switch {
+				case opt == "noauto":
+					noauto = true
+				case userspaceOption(opt):
+				default:
+					e.Options = append(e.Options, unescape(opt))
+				}
+			}
+		}
+		if !noauto {
+			entries = append(entries, e)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// userspaceOption reports whether opt is only used by mount(8) or other
+// tools, and not by the kernel.
+func userspaceOption(opt string) bool {
+	switch opt {
+	case "auto", "nofail", "user", "nouser", "users", "owner", "group", "_netdev":
+		return true
+	}
+	return strings.HasPrefix(opt, "x-") || strings.HasPrefix(opt, "comment=")
+}
+
+// ParseMountinfo parses a table of mounts in the format of
+// /proc/self/mountinfo. The "ro" and "rw" options of filesystems are
+// dropped, as those of mounts take precedence.
+func ParseMountinfo(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		fields, super, ok := strings.Cut(text, " - ")
+		f := strings.Fields(fields)
+		g := strings.Fields(super)
+		if !ok || len(f) < 6 || len(g) < 2 {
+			return nil, fmt.Errorf("mountinfo line %d: invalid format", line)
+		}
+		id, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("mountinfo line %d: %w", line, err)
+		}
+		parent, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("mountinfo line %d: %w", line, err)
+		}
+		e := Entry{
+			ID:       id,
+			ParentID: parent,
+			Root:     unescape(f[3]),
+			Target:   unescape(f[4]),
+			Type:     g[0],
+			Source:   unescape(g[1]),
+			Options:  strings.Split(f[5], ","),
+		}
+		if len(g) > 2 {
+			for _, opt := range strings.Split(g[2], ",") {
+				if opt != "ro" && opt != "rw" {
+					e.Options = append(e.Options, unescape(opt))
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// unescape decodes the octal escapes of the fields of mount tables, such
+// as "\040" for a space.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// pseudoFilesystems are the filesystems with no backing storage, which
+// expose the state of the kernel.
+var pseudoFilesystems = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"proc":        true,
+	"pstore":      true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"sysfs":       true,
+	"tracefs":     true,
+}
+
+// IsPseudo reports whether filesystems of type typ have no backing
+// storage, and expose the state of the kernel, as proc and sysfs do.
+// Memory-backed filesystems such as tmpfs are not pseudo-filesystems.
+func IsPseudo(typ string) bool {
+	return pseudoFilesystems[typ]
+}
+
+// TableOption customizes the mounts produced by Mounts.
+type TableOption func(*tableOptions)
+
+type tableOptions struct {
+	from, to   string
+	skipPseudo bool
+	bind       bool
+	filter     func(Entry) bool
+}
+
+// WithRebase keeps the mounts under the directory from of the host, and
+// mounts them under the directory to of the container. The mount of from
+// itself is skipped. The default is to keep all mounts, under the same
+// paths, except the root one.
+func WithRebase(from, to string) TableOption {
+	return func(o *tableOptions) {
+		o.from, o.to = path.Clean(from), path.Clean(to)
+	}
+}
+
+// WithoutPseudo skips the mounts of pseudo-filesystems, as reported by
+// IsPseudo, which runtimes mount for the container themselves.
+func WithoutPseudo() TableOption {
+	return func(o *tableOptions) {
+		o.skipPseudo = true
+	}
+}
+
+// WithFilter skips the mounts for which keep returns false.
+func WithFilter(keep func(Entry) bool) TableOption {
+	return func(o *tableOptions) {
+		o.filter = keep
+	}
+}
+
+// WithBind makes the mounts recursive bind mounts of the mount points of
+// the host, rather than new mounts of their filesystems. Only the options
+// recognized by Parse which apply to bind mounts are kept.
+func WithBind() TableOption {
+	return func(o *tableOptions) {
+		o.bind = true
+	}
+}
+
+// Mounts returns the mounts of the configuration of a container for the
+// entries of a mount table, in the order of the table, which mounts parents
+// before their children. Entries of mountinfo whose Root is not "/", such
+// as bind mounts of a directory, are skipped unless WithBind is given, as a
+// new mount of their filesystem would expose all of it.
+func Mounts(entries []Entry, opts ...TableOption) []specs.Mount {
+	o := tableOptions{from: "/", to: "/"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var mounts []specs.Mount
+	for _, e := range entries {
+		if o.skipPseudo && IsPseudo(e.Type) {
+			continue
+		}
+		if o.filter != nil && !o.filter(e) {
+			continue
+		}
+		if !o.bind && e.Root != "" && e.Root != "/" {
+			continue
+		}
+		target := path.Clean(e.Target)
+		rel, ok := relative(o.from, target)
+		if !ok || rel == "" {
+			continue
+		}
+		m := specs.Mount{
+			Destination: path.Join(o.to, rel),
+			Type:        e.Type,
+			Source:      e.Source,
+			Options:     e.Options,
+		}
+		if o.bind {
+			m.Type = "bind"
+			m.Source = target
+			m.Options = []string{"rbind"}
+			for _, opt := range e.Options {
+				if f, ok := flagOptions[opt]; ok && f.flag&bindFlags == f.flag && f.flag != 0 {
+					m.Options = append(m.Options, opt)
+				}
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// bindFlags are the flags of mount(2) which apply to bind mounts.
+const bindFlags = msRdonly | msNosuid | msNodev | msNoexec | msNosymfollow | atimeFlags | msNodiratime
+
+// relative returns target relative to dir, and whether target is within
+// dir.
+func relative(dir, target string) (string, bool) {
+	if dir == "/" {
+		return strings.TrimPrefix(target, "/"), strings.HasPrefix(target, "/")
+	}
+	if target == dir {
+		return "", true
+	}
+	rel := strings.TrimPrefix(target, dir+"/")
+	return rel, rel != target
+}
@@ -0,0 +1,194 @@
+package mount
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const fstab = `# /etc/fstab
+UUID=0a1b / ext4 defaults,errors=remount-ro 0 1
+
+/dev/sda2	/mnt/my\040disk ext4 rw 0 2
+/dev/sda3 /mnt/backup ext4 noauto,nofail 0 2
+/dev/sdb1 /data xfs defaults,nofail,x-systemd.automount,comment=x 0 2
+/swapfile none swap sw 0 0
+tmpfs /tmp tmpfs
+server:/export /srv/nfs nfs _netdev,ro
+`
+
+const mountinfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+24 22 0:22 / /sys rw,nosuid,nodev,noexec,relatime shared:7 master:2 - sysfs sysfs rw
+25 22 8:1 /srv/data /mnt/data\040dir rw,relatime - ext4 /dev/sda1 rw,errors=remount-ro
+26 22 0:23 / /run rw,nosuid,nodev shared:5 - tmpfs tmpfs rw,size=1000k,mode=755
+27 26 0:24 / /run/user/1000 rw,nosuid,nodev,relatime - tmpfs tmpfs ro,size=100k,uid=1000
+28 22 0:25 / /runner rw - tmpfs tmpfs rw
+`
+
+func TestParseFstab(t *testing.T) {
+	got, err := ParseFstab(strings.NewReader(fstab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Source: "UUID=0a1b", Target: "/", Type: "ext4", Options: []string{"defaults", "errors=remount-ro"}},
+		{Source: "/dev/sda2", Target: "/mnt/my disk", Type: "ext4", Options: []string{"rw"}},
+		{Source: "/dev/sdb1", Target: "/data", Type: "xfs", Options: []string{"defaults"}},
+		{Source: "tmpfs", Target: "/tmp", Type: "tmpfs"},
+		{Source: "server:/export", Target: "/srv/nfs", Type: "nfs", Options: []string{"ro"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMountinfo(t *testing.T) {
+	got, err := ParseMountinfo(strings.NewReader(mountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{ID: 22, ParentID: 1, Source: "/dev/sda1", Root: "/", Target: "/", Type: "ext4", Options: []string{"rw", "relatime", "errors=remount-ro"}},
+		{ID: 23, ParentID: 22, Source: "proc", Root: "/", Target: "/proc", Type: "proc", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+		{ID: 24, ParentID: 22, Source: "sysfs", Root: "/", Target: "/sys", Type: "sysfs", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+		{ID: 25, ParentID: 22, Source: "/dev/sda1", Root: "/srv/data", Target: "/mnt/data dir", Type: "ext4", Options: []string{"rw", "relatime", "errors=remount-ro"}},
+		{ID: 26, ParentID: 22, Source: "tmpfs", Root: "/", Target: "/run", Type: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "size=1000k", "mode=755"}},
+		{ID: 27, ParentID: 26, Source: "tmpfs", Root: "/", Target: "/run/user/1000", Type: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "relatime", "size=100k", "uid=1000"}},
+		{ID: 28, ParentID: 22, Source: "tmpfs", Root: "/", Target: "/runner", Type: "tmpfs", Options: []string{"rw"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		parse func(string) ([]Entry, error)
+		table string
+	}{
+		{"fstab fields", func(s string) ([]Entry, error) { return ParseFstab(strings.NewReader(s)) }, "/dev/sda1 /\n"},
+		{"mountinfo separator", func(s string) ([]Entry, error) { return ParseMountinfo(strings.NewReader(s)) }, "22 1 8:1 / / rw ext4 /dev/sda1 rw\n"},
+		{"mountinfo fields", func(s string) ([]Entry, error) { return ParseMountinfo(strings.NewReader(s)) }, "22 1 8:1 / / - ext4 /dev/sda1 rw\n"},
+		{"mountinfo id", func(s string) ([]Entry, error) { return ParseMountinfo(strings.NewReader(s)) }, "x 1 8:1 / / rw - ext4 /dev/sda1 rw\n"},
+		{"mountinfo parent", func(s string) ([]Entry, error) { return ParseMountinfo(strings.NewReader(s)) }, "22 x 8:1 / / rw - ext4 /dev/sda1 rw\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if entries, err := tc.parse(tc.table); err == nil {
+				t.Errorf("parsed %q as %+v", tc.table, entries)
+			}
+		})
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`/mnt/plain`, "/mnt/plain"},
+		{`/mnt/a\040b`, "/mnt/a b"},
+		{`/mnt/tab\011and\012newline`, "/mnt/tab\tand\nnewline"},
+		{`/mnt/back\134slash`, `/mnt/back\slash`},
+		{`/mnt/short\04`, `/mnt/short\04`},
+		{`/mnt/not\999octal`, `/mnt/not\999octal`},
+	} {
+		if got := unescape(tc.in); got != tc.want {
+			t.Errorf("unescape(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMounts(t *testing.T) {
+	fstabEntries, err := ParseFstab(strings.NewReader(fstab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountinfoEntries, err := ParseMountinfo(strings.NewReader(mountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		entries []Entry
+		opts    []TableOption
+		want    []specs.Mount
+	}{
+		{
+			name:    "fstab",
+			entries: fstabEntries,
+			want: []specs.Mount{
+				{Destination: "/mnt/my disk", Type: "ext4", Source: "/dev/sda2", Options: []string{"rw"}},
+				{Destination: "/data", Type: "xfs", Source: "/dev/sdb1", Options: []string{"defaults"}},
+				{Destination: "/tmp", Type: "tmpfs", Source: "tmpfs"},
+				{Destination: "/srv/nfs", Type: "nfs", Source: "server:/export", Options: []string{"ro"}},
+			},
+		},
+		{
+			name:    "mountinfo",
+			entries: mountinfoEntries,
+			want: []specs.Mount{
+				{Destination: "/proc", Type: "proc", Source: "proc", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+				{Destination: "/sys", Type: "sysfs", Source: "sysfs", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+				{Destination: "/run", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "size=1000k", "mode=755"}},
+				{Destination: "/run/user/1000", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "relatime", "size=100k", "uid=1000"}},
+				{Destination: "/runner", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw"}},
+			},
+		},
+		{
+			name:    "without pseudo",
+			entries: mountinfoEntries,
+			opts:    []TableOption{WithoutPseudo(), WithFilter(func(e Entry) bool { return e.Target != "/runner" })},
+			want: []specs.Mount{
+				{Destination: "/run", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "size=1000k", "mode=755"}},
+				{Destination: "/run/user/1000", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "relatime", "size=100k", "uid=1000"}},
+			},
+		},
+		{
+			name:    "rebase",
+			entries: mountinfoEntries,
+			opts:    []TableOption{WithRebase("/run/", "/host/run")},
+			want: []specs.Mount{
+				{Destination: "/host/run/user/1000", Type: "tmpfs", Source: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "relatime", "size=100k", "uid=1000"}},
+			},
+		},
+		{
+			name:    "rebase to the root",
+			entries: fstabEntries,
+			opts:    []TableOption{WithRebase("/mnt", "/")},
+			want: []specs.Mount{
+				{Destination: "/my disk", Type: "ext4", Source: "/dev/sda2", Options: []string{"rw"}},
+			},
+		},
+		{
+			name:    "bind",
+			entries: mountinfoEntries,
+			opts:    []TableOption{WithBind(), WithoutPseudo(), WithFilter(func(e Entry) bool { return e.ParentID == 22 })},
+			want: []specs.Mount{
+				{Destination: "/mnt/data dir", Type: "bind", Source: "/mnt/data dir", Options: []string{"rbind", "rw", "relatime"}},
+				{Destination: "/run", Type: "bind", Source: "/run", Options: []string{"rbind", "rw", "nosuid", "nodev"}},
+				{Destination: "/runner", Type: "bind", Source: "/runner", Options: []string{"rbind", "rw"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Mounts(tc.entries, tc.opts...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsPseudo(t *testing.T) {
+	for typ, want := range map[string]bool{
+		"proc": true, "sysfs": true, "cgroup2": true, "devpts": true,
+		"tmpfs": false, "ext4": false, "overlay": false, "": false,
+	} {
+		if got := IsPseudo(typ); got != want {
+			t.Errorf("IsPseudo(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+// Package overlay layers partial documents on top of configurations.
+//
+// Two formats are supported. MergePatch applies an RFC 7396 JSON merge
+// patch, which replaces lists as a whole. Apply applies a strategic overlay,
+// which has the shape of a configuration but merges lists according to the
+// semantics of the list:
+//
+//   - Lists whose elements have an identity (see diff.Keys) are merged by
+//     identity: an element of the overlay is merged into the element of the
+//     configuration with the same key, or appended if there is none.
+//   - Lists used as sets (see diff.Sets) are merged by union.
+//   - Any other list is replaced.
+//
+// The default behaviour of a list can be overridden by giving an object
+// made of directives instead of an array. Directives are applied in the
+// order $replace, $remove, $merge, $append:
+//
+//	{"mounts": {"$remove": ["/tmp"], "$append": [{"destination": "/tmp", "type": "tmpfs"}]}}
+//
+// Elements to remove from a keyed list are given either by their key or by
+// an object which carries the key. A "*" member of an object applies its
+// value to every non-empty member of the configuration at that level, so
+// that CAP_SYS_ADMIN is dropped from every capability set with:
+//
+//	{"process": {"capabilities": {"*": {"$remove": ["CAP_SYS_ADMIN"]}}}}
+//
+// A null value resets the corresponding member of the configuration.
+package overlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/diff"
+)
+
+// Directives of a strategic overlay.
+const (
+	// Replace replaces the list with the given elements.
+	Replace = "$replace"
+	// Remove removes the given elements from the list.
+	Remove = "$remove"
+	// Merge merges the given elements into the list.
+	Merge = "$merge"
+	// Append appends the given elements to the list.
+	Append = "$append"
+	// Wildcard applies its value to every non-empty member of an object.
+	Wildcard = "*"
+)
+
+var directives = []string{Replace, Remove, Merge, Append}
+
+// Apply applies the strategic overlay doc to spec and returns the resulting
+// configuration. spec is not modified.
+func Apply(spec *specs.Spec, doc []byte) (*specs.Spec, error) {
+	out, err := clone(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := merge("", reflect.ValueOf(out).Elem(), doc); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MergePatch applies the RFC 7396 JSON merge patch doc to spec and returns
+// the resulting configuration. spec is not modified.
+func MergePatch(spec *specs.Spec, doc []byte) (*specs.Spec, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var target, patch interface{}
+	if err := json.Unmarshal(data, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(doc, &patch); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if data, err = json.Marshal(mergePatch(target, patch)); err != nil {
+		return nil, err
+	}
+	var out specs.Spec
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("merge patch produces an invalid configuration: %w", err)
+	}
+	return &out, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+func clone(spec *specs.Spec) (*specs.Spec, error) {
+	out := &specs.Spec{}
+	if spec == nil {
+		return out, nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func isNull(raw []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+func isObject(raw []byte) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && raw[0] == '{'
+}
+
+func errorf(p, format string, args ...interface{}) error {
+	if p == "" {
+		p = "$"
+	}
+	return fmt.Errorf("%s: %s", p, fmt.Sprintf(format, args...))
+}
+
+// merge merges the overlay raw into the addressable value v.
+func merge(p string, v reflect.Value, raw []byte) error {
+	if isNull(raw) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return merge(p, v.Elem(), raw)
+	case reflect.Struct:
+		return mergeStruct(p, v, raw)
+	case reflect.Map:
+		return mergeMap(p, v, raw)
+	case reflect.Slice:
+		return mergeSlice(p, v, raw)
+	}
+	n := reflect.New(v.Type())
+	if err := json.Unmarshal(raw, n.Interface()); err != nil {
+		return errorf(p, "%v", err)
+	}
+	v.Set(n.Elem())
+	return nil
+}
+
+func decodeObject(p string, raw []byte) (map[string]json.RawMessage, []string, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, nil, errorf(p, "%v", err)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		if k != Wildcard {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return obj, keys, nil
+}
+
+// fields returns the members of the struct type t by JSON name, flattening
+// embedded structs the way encoding/json does.
+func fields(t reflect.Type) map[string][]int {
+	m := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for n, idx := range fields(f.Type) {
+				m[n] = append([]int{i}, idx...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		m[name] = []int{i}
+	}
+	return m
+}
+
+func mergeStruct(p string, v reflect.Value, raw []byte) error {
+	obj, keys, err := decodeObject(p, raw)
+	if err != nil {
+		return err
+	}
+	fs := fields(v.Type())
+	if w, ok := obj[Wildcard]; ok {
+		names := make([]string, 0, len(fs))
+		for name := range fs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f := v.FieldByIndex(fs[name])
+			if f.IsZero() {
+				continue
+			}
+			if err := merge(join(p, name), f, w); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range keys {
+		idx, ok := fs[k]
+		if !ok {
+			return errorf(join(p, k), "unknown field")
+		}
+		if err := merge(join(p, k), v.FieldByIndex(idx), obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeMap(p string, v reflect.Value, raw []byte) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return errorf(p, "unsupported map key type %s", v.Type().Key())
+	}
+	obj, keys, err := decodeObject(p, raw)
+	if err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	elemType := v.Type().Elem()
+	apply := func(k reflect.Value, raw []byte) error {
+		kp := fmt.Sprintf("%s[%q]", p, k.String())
+		if isNull(raw) {
+			v.SetMapIndex(k, reflect.Value{})
+			return nil
+		}
+		e := reflect.New(elemType).Elem()
+		if old := v.MapIndex(k); old.IsValid() {
+			e.Set(old)
+		}
+		if err := merge(kp, e, raw); err != nil {
+			return err
+		}
+		v.SetMapIndex(k, e)
+		return nil
+	}
+	if w, ok := obj[Wildcard]; ok {
+		existing := v.MapKeys()
+		sort.Slice(existing, func(i, j int) bool { return existing[i].String() < existing[j].String() })
+		for _, k := range existing {
+			if err := apply(k, w); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range keys {
+		if err := apply(reflect.ValueOf(k).Convert(v.Type().Key()), obj[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeSlice(p string, v reflect.Value, raw []byte) error {
+	ops := map[string]json.RawMessage{}
+	if isObject(raw) {
+		obj, keys, err := decodeObject(p, raw)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if !isDirective(k) {
+				return errorf(p, "unknown list directive %q", k)
+			}
+			ops[k] = obj[k]
+		}
+		if _, ok := obj[Wildcard]; ok {
+			return errorf(p, "%q is not allowed in a list", Wildcard)
+		}
+	} else {
+		ops[defaultDirective(p, v.Type())] = raw
+	}
+
+	keyFn := diff.Keys[v.Type().Elem()]
+	for _, d := range directives {
+		raw, ok := ops[d]
+		if !ok {
+			continue
+		}
+		var err error
+		switch d {
+		case Replace:
+			err = replaceSlice(p, v, raw)
+		case Remove:
+			err = removeFromSlice(p, v, raw, keyFn)
+		case Merge:
+			switch {
+			case keyFn != nil:
+				err = mergeKeyedSlice(p, v, raw, keyFn)
+			case diff.Sets[p]:
+				err = unionSlice(p, v, raw)
+			default:
+				err = errorf(p, "%s requires a keyed list or a set", Merge)
+			}
+		case Append:
+			err = appendSlice(p, v, raw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDirective(k string) bool {
+	for _, d := range directives {
+		if k == d {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultDirective(p string, t reflect.Type) string {
+	if _, ok := diff.Keys[t.Elem()]; ok || diff.Sets[p] {
+		return Merge
+	}
+	return Replace
+}
+
+func decodeSlice(p string, t reflect.Type, raw []byte) (reflect.Value, error) {
+	s := reflect.New(t)
+	if err := json.Unmarshal(raw, s.Interface()); err != nil {
+		return reflect.Value{}, errorf(p, "%v", err)
+	}
+	return s.Elem(), nil
+}
+
+func replaceSlice(p string, v reflect.Value, raw []byte) error {
+	s, err := decodeSlice(p, v.Type(), raw)
+	if err != nil {
+		return err
+	}
+	v.Set(s)
+	return nil
+}
+
+func appendSlice(p string, v reflect.Value, raw []byte) error {
+	s, err := decodeSlice(p, v.Type(), raw)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.AppendSlice(v, s))
+	return nil
+}
+
+func unionSlice(p string, v reflect.Value, raw []byte) error {
+	s, err := decodeSlice(p, v.Type(), raw)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < s.Len(); i++ {
+		if indexOf(v, s.Index(i).Interface()) < 0 {
+			v.Set(reflect.Append(v, s.Index(i)))
+		}
+	}
+	return nil
+}
+
+func indexOf(s reflect.Value, e interface{}) int {
+	for i := 0; i < s.Len(); i++ {
+		if reflect.DeepEqual(s.Index(i).Interface(), e) {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeFromSlice(p string, v reflect.Value, raw []byte, keyFn diff.KeyFunc) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return errorf(p, "%v", err)
+	}
+	elemType := v.Type().Elem()
+	for _, e := range elems {
+		if keyFn == nil {
+			n := reflect.New(elemType)
+			if err := json.Unmarshal(e, n.Interface()); err != nil {
+				return errorf(p, "%v", err)
+			}
+			filter(v, func(x reflect.Value) bool {
+				return !reflect.DeepEqual(x.Interface(), n.Elem().Interface())
+			})
+			continue
+		}
+		key, err := elemKey(p, elemType, e, keyFn)
+		if err != nil {
+			return err
+		}
+		filter(v, func(x reflect.Value) bool {
+			_, k := keyFn(x.Interface())
+			return k != key
+		})
+	}
+	return nil
+}
+
+// elemKey returns the key of the overlay element raw of a keyed list, given
+// either as the key itself or as an object carrying the key. A key is
+// decoded into the identifying field of an element, so that it is
+// normalized by keyFn as the elements of the list are. The key of a list
+// field, such as the names of a seccomp rule, may be given as an array or
+// as a string with the names separated by commas.
+func elemKey(p string, elemType reflect.Type, raw []byte, keyFn diff.KeyFunc) (string, error) {
+	n := reflect.New(elemType)
+	if isObject(raw) {
+		if err := json.Unmarshal(raw, n.Interface()); err != nil {
+			return "", errorf(p, "%v", err)
+		}
+		_, key := keyFn(n.Elem().Interface())
+		return key, nil
+	}
+	field, _ := keyFn(reflect.Zero(elemType).Interface())
+	obj, _ := json.Marshal(map[string]json.RawMessage{field: raw})
+	err := json.Unmarshal(obj, n.Interface())
+	if err != nil {
+		var key string
+		if json.Unmarshal(raw, &key) == nil {
+			obj, _ = json.Marshal(map[string][]string{field: strings.Split(key, ",")})
+			n = reflect.New(elemType)
+			err = json.Unmarshal(obj, n.Interface())
+		}
+	}
+	if err != nil {
+		return "", errorf(p, "element must be a key or an object: %v", err)
+	}
+	_, key := keyFn(n.Elem().Interface())
+	return key, nil
+}
+
+// filter keeps the elements of the slice v for which keep returns true.
+func filter(v reflect.Value, keep func(reflect.Value) bool) {
+	out := reflect.MakeSlice(v.Type(), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if keep(v.Index(i)) {
+			out = reflect.Append(out, v.Index(i))
+		}
+	}
+	v.Set(out)
+}
+
+func mergeKeyedSlice(p string, v reflect.Value, raw []byte, keyFn diff.KeyFunc) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return errorf(p, "%v", err)
+	}
+	elemType := v.Type().Elem()
+	for _, e := range elems {
+		key, err := elemKey(p, elemType, e, keyFn)
+		if err != nil {
+			return err
+		}
+		field, _ := keyFn(reflect.Zero(elemType).Interface())
+		ep := fmt.Sprintf("%s[%s=%q]", p, field, key)
+		if !isObject(e) {
+			return errorf(ep, "element to merge must be an object")
+		}
+		found := false
+		for i := 0; i < v.Len(); i++ {
+			if _, k := keyFn(v.Index(i).Interface()); k == key {
+				if err := merge(ep, v.Index(i), e); err != nil {
+					return err
+				}
+				found = true
+			}
+		}
+		if !found {
+			n := reflect.New(elemType).Elem()
+			if err := merge(ep, n, e); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, n))
+		}
+	}
+	return nil
+}
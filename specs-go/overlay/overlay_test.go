@@ -0,0 +1,159 @@
+package overlay
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func base() *specs.Spec {
+	return &specs.Spec{
+		Version:  "1.0.0",
+		Hostname: "box",
+		Process: &specs.Process{
+			Args: []string{"sh"},
+			Capabilities: &specs.LinuxCapabilities{
+				Bounding:  []string{"CAP_CHOWN", "CAP_SYS_ADMIN"},
+				Effective: []string{"CAP_SYS_ADMIN"},
+			},
+		},
+		Mounts: []specs.Mount{
+			{Destination: "/proc", Type: "proc", Source: "proc"},
+			{Destination: "/tmp", Type: "tmpfs", Source: "tmpfs", Options: []string{"nosuid"}},
+		},
+		Linux: &specs.Linux{
+			Namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace}, {Type: specs.NetworkNamespace}},
+			Seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActErrno,
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"read", "write"}, Action: specs.ActAllow},
+					{Names: []string{"kill"}, Action: specs.ActAllow},
+				},
+			},
+		},
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		doc   string
+		check func(t *testing.T, s *specs.Spec)
+	}{
+		{
+			name: "keyed merge",
+			doc:  `{"mounts": [{"destination": "/tmp/", "options": ["size=1m"]}, {"destination": "/data", "type": "bind"}]}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				want := []specs.Mount{
+					{Destination: "/proc", Type: "proc", Source: "proc"},
+					{Destination: "/tmp/", Type: "tmpfs", Source: "tmpfs", Options: []string{"size=1m"}},
+					{Destination: "/data", Type: "bind"},
+				}
+				if !reflect.DeepEqual(s.Mounts, want) {
+					t.Errorf("mounts %+v, want %+v", s.Mounts, want)
+				}
+			},
+		},
+		{
+			name: "set union and wildcard",
+			doc:  `{"process": {"capabilities": {"*": {"$remove": ["CAP_SYS_ADMIN"]}, "bounding": ["CAP_KILL", "CAP_CHOWN"]}}}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				want := &specs.LinuxCapabilities{
+					Bounding:  []string{"CAP_CHOWN", "CAP_KILL"},
+					Effective: []string{},
+				}
+				if !reflect.DeepEqual(s.Process.Capabilities, want) {
+					t.Errorf("capabilities %+v, want %+v", s.Process.Capabilities, want)
+				}
+			},
+		},
+		{
+			name: "directives",
+			doc:  `{"linux": {"namespaces": {"$remove": ["network"], "$append": [{"type": "user"}]}}, "process": {"args": ["bash"]}}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				want := []specs.LinuxNamespace{{Type: specs.PIDNamespace}, {Type: specs.UserNamespace}}
+				if !reflect.DeepEqual(s.Linux.Namespaces, want) {
+					t.Errorf("namespaces %+v, want %+v", s.Linux.Namespaces, want)
+				}
+				if !reflect.DeepEqual(s.Process.Args, []string{"bash"}) {
+					t.Errorf("args %v, want [bash]", s.Process.Args)
+				}
+			},
+		},
+		{
+			name: "syscall keys in any order",
+			doc: `{"linux": {"seccomp": {"syscalls": {
+				"$remove": ["write,read"],
+				"$merge": [{"names": ["kill"], "action": "SCMP_ACT_ERRNO"}]
+			}}}}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				want := []specs.LinuxSyscall{{Names: []string{"kill"}, Action: specs.ActErrno}}
+				if !reflect.DeepEqual(s.Linux.Seccomp.Syscalls, want) {
+					t.Errorf("syscalls %+v, want %+v", s.Linux.Seccomp.Syscalls, want)
+				}
+			},
+		},
+		{
+			name: "syscall key as array",
+			doc:  `{"linux": {"seccomp": {"syscalls": {"$remove": [["write", "read"], {"names": ["kill"]}]}}}}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				if len(s.Linux.Seccomp.Syscalls) != 0 {
+					t.Errorf("syscalls %+v, want none", s.Linux.Seccomp.Syscalls)
+				}
+			},
+		},
+		{
+			name: "null",
+			doc:  `{"hostname": null, "linux": {"seccomp": null}}`,
+			check: func(t *testing.T, s *specs.Spec) {
+				if s.Hostname != "" || s.Linux.Seccomp != nil {
+					t.Errorf("hostname %q and seccomp %+v are not reset", s.Hostname, s.Linux.Seccomp)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := base()
+			out, err := Apply(spec, []byte(tc.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec, base()) {
+				t.Error("Apply modified its input")
+			}
+			tc.check(t, out)
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	for _, doc := range []string{
+		`{"bogus": 1}`,
+		`{"process": {"args": {"$merge": ["x"]}}}`,
+		`{"mounts": {"$bogus": []}}`,
+		`{"mounts": {"*": []}}`,
+		`{"mounts": ["/tmp"]}`,
+		`{"hostname": 1}`,
+	} {
+		if _, err := Apply(base(), []byte(doc)); err == nil {
+			t.Errorf("Apply(%s) succeeded", doc)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	out, err := MergePatch(base(), []byte(`{"mounts": [{"destination": "/data"}], "hostname": null, "process": {"cwd": "/root"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []specs.Mount{{Destination: "/data"}}; !reflect.DeepEqual(out.Mounts, want) {
+		t.Errorf("mounts %+v, want %+v", out.Mounts, want)
+	}
+	if out.Hostname != "" || out.Process.Cwd != "/root" || len(out.Process.Args) != 1 {
+		t.Errorf("unexpected result %+v", out)
+	}
+	if _, err := MergePatch(base(), []byte(`{"process": {"args": "sh"}}`)); err == nil {
+		t.Error("MergePatch produced an invalid configuration")
+	}
+}
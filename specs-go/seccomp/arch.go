@@ -0,0 +1,157 @@
+package seccomp
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+//go:generate go run mksyscalls.go -linux $LINUX_SRC
+
+// x32SyscallBit is set in the number of the system calls of the x32 ABI,
+// which shares its audit architecture with x86_64.
+const x32SyscallBit = 0x40000000
+
+// AUDIT_ARCH_* flags of <linux/audit.h>.
+const (
+	auditArch64Bit = 0x80000000
+	auditArchLE    = 0x40000000
+	auditArchN32   = 0x20000000
+)
+
+// archInfo describes how an architecture presents system calls to seccomp.
+type archInfo struct {
+	// audit is the AUDIT_ARCH_* value found in seccomp_data.arch.
+	audit uint32
+	// is64 is set when system call arguments are 64 bits wide.
+	is64 bool
+	// bigEndian is set when seccomp_data is big-endian.
+	bigEndian bool
+	// table is the key of the system call table in syscallTables.
+	table specs.Arch
+}
+
+var arches = map[specs.Arch]archInfo{
+	specs.ArchX86:         {audit: 3 | auditArchLE, table: specs.ArchX86},
+	specs.ArchX86_64:      {audit: 62 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchX86_64},
+	specs.ArchX32:         {audit: 62 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchX32},
+	specs.ArchARM:         {audit: 40 | auditArchLE, table: specs.ArchARM},
+	specs.ArchAARCH64:     {audit: 183 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchAARCH64},
+	specs.ArchMIPS:        {audit: 8, bigEndian: true, table: specs.ArchMIPS},
+	specs.ArchMIPS64:      {audit: 8 | auditArch64Bit, is64: true, bigEndian: true, table: specs.ArchMIPS64},
+	specs.ArchMIPS64N32:   {audit: 8 | auditArch64Bit | auditArchN32, is64: true, bigEndian: true, table: specs.ArchMIPS64N32},
+	specs.ArchMIPSEL:      {audit: 8 | auditArchLE, table: specs.ArchMIPS},
+	specs.ArchMIPSEL64:    {audit: 8 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchMIPS64},
+	specs.ArchMIPSEL64N32: {audit: 8 | auditArch64Bit | auditArchLE | auditArchN32, is64: true, table: specs.ArchMIPS64N32},
+	specs.ArchPPC:         {audit: 20, bigEndian: true, table: specs.ArchPPC},
+	specs.ArchPPC64:       {audit: 21 | auditArch64Bit, is64: true, bigEndian: true, table: specs.ArchPPC64},
+	specs.ArchPPC64LE:     {audit: 21 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchPPC64},
+	specs.ArchS390:        {audit: 22, bigEndian: true, table: specs.ArchS390},
+	specs.ArchS390X:       {audit: 22 | auditArch64Bit, is64: true, bigEndian: true, table: specs.ArchS390X},
+	specs.ArchPARISC:      {audit: 15, bigEndian: true, table: specs.ArchPARISC},
+	specs.ArchPARISC64:    {audit: 15 | auditArch64Bit, is64: true, bigEndian: true, table: specs.ArchPARISC64},
+	specs.ArchRISCV64:     {audit: 243 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchRISCV64},
+	specs.ArchLOONGARCH64: {audit: 258 | auditArch64Bit | auditArchLE, is64: true, table: specs.ArchLOONGARCH64},
+	specs.ArchM68K:        {audit: 4, bigEndian: true, table: specs.ArchM68K},
+	// SuperH has no system call table, and its filters are rejected by
+	// lookupArch rather than compiled without any system call.
+	specs.ArchSH:   {audit: 42 | auditArchLE},
+	specs.ArchSHEB: {audit: 42, bigEndian: true},
+}
+
+// goArches maps architectures to their GOARCH names, which Docker also uses
+// in the arches of filters.
+var goArches = map[specs.Arch]string{
+	specs.ArchX86:         "386",
+	specs.ArchX86_64:      "amd64",
+	specs.ArchX32:         "x32",
+	specs.ArchARM:         "arm",
+	specs.ArchAARCH64:     "arm64",
+	specs.ArchMIPS:        "mips",
+	specs.ArchMIPSEL:      "mipsle",
+	specs.ArchMIPS64:      "mips64",
+	specs.ArchMIPSEL64:    "mips64le",
+	specs.ArchPPC:         "ppc",
+	specs.ArchPPC64:       "ppc64",
+	specs.ArchPPC64LE:     "ppc64le",
+	specs.ArchS390:        "s390",
+	specs.ArchS390X:       "s390x",
+	specs.ArchRISCV64:     "riscv64",
+	specs.ArchLOONGARCH64: "loong64",
+}
+
+// NativeArch returns the architecture of the running program, as given by
+// runtime.GOARCH.
+func NativeArch() (specs.Arch, error) {
+	for arch, goArch := range goArches {
+		if goArch == runtime.GOARCH {
+			return arch, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported architecture %s", runtime.GOARCH)
+}
+
+func lookupArch(arch specs.Arch) (archInfo, map[string]uint32, error) {
+	info, ok := arches[arch]
+	if !ok {
+		return archInfo{}, nil, fmt.Errorf("unknown architecture %q", arch)
+	}
+	if info.table == "" {
+		return archInfo{}, nil, fmt.Errorf("unsupported architecture %s: no system call table", arch)
+	}
+	return info, syscallTables[info.table], nil
+}
+
+// AuditArch returns the AUDIT_ARCH_* value identifying arch in
+// seccomp_data.arch.
+func AuditArch(arch specs.Arch) (uint32, error) {
+	info, ok := arches[arch]
+	if !ok {
+		return 0, fmt.Errorf("unknown architecture %q", arch)
+	}
+	return info.audit, nil
+}
+
+// SyscallNumber returns the number of the system call name on arch. For
+// x32, the number includes the x32 system call bit.
+func SyscallNumber(arch specs.Arch, name string) (uint32, error) {
+	_, table, err := lookupArch(arch)
+	if err != nil {
+		return 0, err
+	}
+	nr, ok := table[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown system call %q on %s", name, arch)
+	}
+	return nr, nil
+}
+
+// SyscallName returns the name of the system call number nr on arch.
+func SyscallName(arch specs.Arch, nr uint32) (string, error) {
+	_, table, err := lookupArch(arch)
+	if err != nil {
+		return "", err
+	}
+	for name, n := range table {
+		if n == nr {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown system call %d on %s", nr, arch)
+}
+
+// Syscalls returns the names of the system calls of arch, sorted by name.
+func Syscalls(arch specs.Arch) ([]string, error) {
+	_, table, err := lookupArch(arch)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
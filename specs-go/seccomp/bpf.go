@@ -0,0 +1,255 @@
+package seccomp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Opcodes of classic BPF, from <linux/filter.h>.
+const (
+	bpfLD  = 0x00
+	bpfALU = 0x04
+	bpfJMP = 0x05
+	bpfRET = 0x06
+
+	bpfW   = 0x00
+	bpfABS = 0x20
+	bpfK   = 0x00
+
+	bpfAND = 0x50
+
+	bpfJA   = 0x00
+	bpfJEQ  = 0x10
+	bpfJGT  = 0x20
+	bpfJGE  = 0x30
+	bpfJSET = 0x40
+
+	opLoad = bpfLD | bpfW | bpfABS
+	opAnd  = bpfALU | bpfAND | bpfK
+	opJA   = bpfJMP | bpfJA
+	opJEQ  = bpfJMP | bpfJEQ | bpfK
+	opJGT  = bpfJMP | bpfJGT | bpfK
+	opJGE  = bpfJMP | bpfJGE | bpfK
+	opJSET = bpfJMP | bpfJSET | bpfK
+	opRet  = bpfRET | bpfK
+)
+
+// MaxInstructions is the maximum length of a program accepted by the
+// kernel (BPF_MAXINSNS).
+const MaxInstructions = 4096
+
+// Offsets of the fields of struct seccomp_data.
+const (
+	offsetNr   = 0
+	offsetArch = 4
+	offsetArgs = 16
+)
+
+// Instruction is a classic BPF instruction, laid out as struct sock_filter.
+type Instruction struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+// disasm disassembles ins, located at address pc.
+func (ins Instruction) disasm(pc int) string {
+	target := func(off uint32) string { return fmt.Sprintf("%04d", pc+1+int(off)) }
+	switch ins.Code {
+	case opLoad:
+		return fmt.Sprintf("ld  $data[%d]", ins.K)
+	case opAnd:
+		return fmt.Sprintf("and 0x%08x", ins.K)
+	case opJA:
+		return "jmp " + target(ins.K)
+	case opJEQ, opJGT, opJGE, opJSET:
+		name := map[uint16]string{opJEQ: "jeq", opJGT: "jgt", opJGE: "jge", opJSET: "jset"}[ins.Code]
+		return fmt.Sprintf("%-4s0x%08x true:%s false:%s", name, ins.K, target(uint32(ins.Jt)), target(uint32(ins.Jf)))
+	case opRet:
+		return "ret " + describeReturn(ins.K)
+	}
+	return fmt.Sprintf("unknown opcode 0x%02x", ins.Code)
+}
+
+// Program is a seccomp filter, as passed to seccomp(2) in struct
+// sock_fprog.
+type Program []Instruction
+
+// String returns the disassembly of p.
+func (p Program) String() string {
+	var b strings.Builder
+	_ = p.Dump(&b)
+	return b.String()
+}
+
+// Dump writes the disassembly of p to w, one instruction per line.
+func (p Program) Dump(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, " line  OP   JT   JF   K\n=================================\n"); err != nil {
+		return err
+	}
+	for pc, ins := range p {
+		if _, err := fmt.Fprintf(w, " %04d: 0x%02x 0x%02x 0x%02x 0x%08x   %s\n", pc, ins.Code, ins.Jt, ins.Jf, ins.K, ins.disasm(pc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytes returns the array of struct sock_filter of p, encoded with order,
+// which must be the byte order of the machine loading the program.
+func (p Program) Bytes(order binary.ByteOrder) []byte {
+	b := make([]byte, 8*len(p))
+	for i, ins := range p {
+		order.PutUint16(b[8*i:], ins.Code)
+		b[8*i+2] = ins.Jt
+		b[8*i+3] = ins.Jf
+		order.PutUint32(b[8*i+4:], ins.K)
+	}
+	return b
+}
+
+// label identifies a location of a program being assembled. The zero label
+// is the instruction following the jump.
+type label int
+
+const next label = 0
+
+type asmInsn struct {
+	code   uint16
+	k      uint32
+	jt, jf label
+	// far is set when a conditional jump needs trampolines to reach its
+	// targets.
+	far bool
+}
+
+// assembler builds programs made of forward jumps to labels. Conditional
+// jumps whose targets are out of reach of their 8-bit offsets are
+// rewritten to jump over unconditional jumps.
+type assembler struct {
+	insns []asmInsn
+	// labels holds the index in insns of each label, -1 until placed.
+	labels []int
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: []int{-1}}
+}
+
+func (a *assembler) newLabel() label {
+	a.labels = append(a.labels, -1)
+	return label(len(a.labels) - 1)
+}
+
+func (a *assembler) place(l label) {
+	a.labels[l] = len(a.insns)
+}
+
+func (a *assembler) emit(code uint16, k uint32) {
+	a.insns = append(a.insns, asmInsn{code: code, k: k})
+}
+
+func (a *assembler) jump(code uint16, k uint32, jt, jf label) {
+	a.insns = append(a.insns, asmInsn{code: code, k: k, jt: jt, jf: jf})
+}
+
+func (a *assembler) jumpTo(l label) {
+	a.insns = append(a.insns, asmInsn{code: opJA, jt: l})
+}
+
+func isCond(code uint16) bool {
+	return code&0x07 == bpfJMP && code != opJA
+}
+
+// size returns the number of instructions of the assembled form of ins.
+func (ins *asmInsn) size() int {
+	if !ins.far {
+		return 1
+	}
+	n := 1
+	if ins.jt != next {
+		n++
+	}
+	if ins.jf != next {
+		n++
+	}
+	return n
+}
+
+// assemble resolves the labels and returns the program.
+func (a *assembler) assemble() (Program, error) {
+	for i, at := range a.labels[1:] {
+		if at < 0 {
+			return nil, fmt.Errorf("internal error: label %d is not placed", i+1)
+		}
+	}
+	addr := make([]int, len(a.insns)+1)
+	for {
+		for i := range a.insns {
+			addr[i+1] = addr[i] + a.insns[i].size()
+		}
+		changed := false
+		for i := range a.insns {
+			ins := &a.insns[i]
+			if !isCond(ins.code) || ins.far {
+				continue
+			}
+			for _, l := range []label{ins.jt, ins.jf} {
+				if l != next && addr[a.labels[l]]-addr[i]-1 > 255 {
+					ins.far = true
+					changed = true
+					break
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	target := func(l label, from int) uint32 {
+		if l == next {
+			return 0
+		}
+		return uint32(addr[a.labels[l]] - from - 1)
+	}
+	prog := make(Program, 0, addr[len(a.insns)])
+	for i, ins := range a.insns {
+		pc := addr[i]
+		switch {
+		case ins.code == opJA:
+			prog = append(prog, Instruction{Code: opJA, K: target(ins.jt, pc)})
+		case isCond(ins.code) && ins.far:
+			// jcond over the trampolines: [jcond] [ja jt] [ja jf]
+			tramps := uint8(ins.size() - 1)
+			jt, jf := tramps, tramps
+			if ins.jt != next {
+				jt = 0
+			}
+			if ins.jf != next {
+				jf = 0
+				if ins.jt != next {
+					jf = 1
+				}
+			}
+			prog = append(prog, Instruction{Code: ins.code, Jt: jt, Jf: jf, K: ins.k})
+			if ins.jt != next {
+				prog = append(prog, Instruction{Code: opJA, K: target(ins.jt, len(prog))})
+			}
+			if ins.jf != next {
+				prog = append(prog, Instruction{Code: opJA, K: target(ins.jf, len(prog))})
+			}
+		case isCond(ins.code):
+			prog = append(prog, Instruction{Code: ins.code, Jt: uint8(target(ins.jt, pc)), Jf: uint8(target(ins.jf, pc)), K: ins.k})
+		default:
+			prog = append(prog, Instruction{Code: ins.code, K: ins.k})
+		}
+	}
+	if len(prog) > MaxInstructions {
+		return nil, fmt.Errorf("program has %d instructions, more than the maximum of %d", len(prog), MaxInstructions)
+	}
+	return prog, nil
+}
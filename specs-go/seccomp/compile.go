@@ -0,0 +1,507 @@
+// Package seccomp compiles specs.LinuxSeccomp into classic BPF programs
+// for seccomp(2), without depending on libseccomp, and evaluates the
+// outcome of system calls under a profile.
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Return values of seccomp filters, from <linux/seccomp.h>.
+const (
+	RetKillProcess uint32 = 0x80000000
+	RetKillThread  uint32 = 0x00000000
+	RetTrap        uint32 = 0x00030000
+	RetErrno       uint32 = 0x00050000
+	RetUserNotif   uint32 = 0x7fc00000
+	RetTrace       uint32 = 0x7ff00000
+	RetLog         uint32 = 0x7ffc0000
+	RetAllow       uint32 = 0x7fff0000
+
+	// RetActionFull masks the action of a return value.
+	RetActionFull uint32 = 0xffff0000
+	// RetData masks the data of a return value.
+	RetData uint32 = 0x0000ffff
+)
+
+// BadArchReturn is returned by compiled programs for system calls of an
+// architecture which is not part of the filter. As with libseccomp, the
+// calling thread is killed.
+const BadArchReturn = RetKillThread
+
+// DefaultErrno is the errno used by SCMP_ACT_ERRNO and SCMP_ACT_TRACE when
+// no errnoRet is given (EPERM).
+const DefaultErrno = 1
+
+var actionReturns = map[specs.LinuxSeccompAction]uint32{
+	specs.ActKill:        RetKillThread,
+	specs.ActKillThread:  RetKillThread,
+	specs.ActKillProcess: RetKillProcess,
+	specs.ActTrap:        RetTrap,
+	specs.ActErrno:       RetErrno,
+	specs.ActTrace:       RetTrace,
+	specs.ActAllow:       RetAllow,
+	specs.ActLog:         RetLog,
+	specs.ActNotify:      RetUserNotif,
+}
+
+// ReturnValue returns the value returned by a filter for action. errnoRet
+// is the errno of SCMP_ACT_ERRNO and the message of SCMP_ACT_TRACE; it
+// defaults to DefaultErrno and is ignored by other actions.
+func ReturnValue(action specs.LinuxSeccompAction, errnoRet *uint) (uint32, error) {
+	ret, ok := actionReturns[action]
+	if !ok {
+		return 0, fmt.Errorf("unknown action %q", action)
+	}
+	if ret == RetErrno || ret == RetTrace {
+		errno := uint(DefaultErrno)
+		if errnoRet != nil {
+			errno = *errnoRet
+		}
+		if errno > uint(RetData) {
+			return 0, fmt.Errorf("errnoRet %d of action %s is out of range", errno, action)
+		}
+		ret |= uint32(errno)
+	}
+	return ret, nil
+}
+
+// Action returns the action and errno of the return value ret. The errno is
+// only meaningful for SCMP_ACT_ERRNO and SCMP_ACT_TRACE.
+func Action(ret uint32) (specs.LinuxSeccompAction, uint) {
+	data := uint(ret & RetData)
+	switch ret & RetActionFull {
+	case RetKillProcess:
+		return specs.ActKillProcess, 0
+	case RetKillThread:
+		return specs.ActKillThread, 0
+	case RetTrap:
+		return specs.ActTrap, 0
+	case RetErrno:
+		return specs.ActErrno, data
+	case RetUserNotif:
+		return specs.ActNotify, 0
+	case RetTrace:
+		return specs.ActTrace, data
+	case RetLog:
+		return specs.ActLog, 0
+	}
+	return specs.ActAllow, 0
+}
+
+// Precedes reports whether the action of return value a takes precedence
+// over the action of b, as when the kernel evaluates several filters:
+// SCMP_ACT_KILL_PROCESS, SCMP_ACT_KILL_THREAD, SCMP_ACT_TRAP, SCMP_ACT_ERRNO,
+// SCMP_ACT_NOTIFY, SCMP_ACT_TRACE, SCMP_ACT_LOG and finally SCMP_ACT_ALLOW.
+func Precedes(a, b uint32) bool {
+	return int32(a&RetActionFull) < int32(b&RetActionFull)
+}
+
+func describeReturn(ret uint32) string {
+	action, data := Action(ret)
+	switch action {
+	case specs.ActErrno, specs.ActTrace:
+		return fmt.Sprintf("%s(%d)", action, data)
+	}
+	return string(action)
+}
+
+// cond is a single argument comparison.
+type cond struct {
+	index uint
+	op    specs.LinuxSeccompOperator
+	value uint64
+	// valueTwo is the datum compared with the masked argument of
+	// SCMP_CMP_MASKED_EQ.
+	valueTwo uint64
+}
+
+// rule is a set of conditions which must all hold for ret to be returned.
+type rule struct {
+	ret   uint32
+	conds []cond
+	// entry is the index of the syscalls entry the rule comes from.
+	entry int
+}
+
+var operators = map[specs.LinuxSeccompOperator]bool{
+	specs.OpNotEqual: true, specs.OpLessThan: true, specs.OpLessEqual: true,
+	specs.OpEqualTo: true, specs.OpGreaterEqual: true, specs.OpGreaterThan: true,
+	specs.OpMaskedEqual: true,
+}
+
+// rules converts a syscalls entry to rules. As runc does, the conditions
+// of an entry are combined with a logical AND, unless several of them apply
+// to the same argument: each condition then makes a rule of its own, and
+// they are combined with a logical OR.
+func rules(sc specs.LinuxSyscall, entry int) ([]rule, error) {
+	ret, err := ReturnValue(sc.Action, sc.ErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+	conds := make([]cond, len(sc.Args))
+	seen := make(map[uint]bool)
+	split := false
+	for i, arg := range sc.Args {
+		if arg.Index > 5 {
+			return nil, fmt.Errorf("argument index %d is out of range", arg.Index)
+		}
+		if !operators[arg.Op] {
+			return nil, fmt.Errorf("unknown operator %q", arg.Op)
+		}
+		if seen[arg.Index] {
+			split = true
+		}
+		seen[arg.Index] = true
+		conds[i] = cond{index: arg.Index, op: arg.Op, value: arg.Value, valueTwo: arg.ValueTwo}
+	}
+	if !split {
+		return []rule{{ret: ret, conds: conds, entry: entry}}, nil
+	}
+	rs := make([]rule, len(conds))
+	for i, c := range conds {
+		rs[i] = rule{ret: ret, conds: []cond{c}, entry: entry}
+	}
+	return rs, nil
+}
+
+// filter is the decision procedure of a seccomp profile for a single
+// architecture.
+type filter struct {
+	arch specs.Arch
+	info archInfo
+	// def is the return value for system calls without a matching rule.
+	def uint32
+	// syscalls maps system call numbers to their rules, ordered by
+	// decreasing precedence. The first matching rule decides the result.
+	syscalls map[uint32][]rule
+}
+
+// newFilter builds the decision procedure of s for arch. Names unknown on
+// arch are ignored, as runc does.
+//
+// When several rules match a system call, the one whose action has the
+// highest precedence (see Precedes) wins, and the first one in the order of
+// s.Syscalls among rules of the same action. The result therefore does not
+// depend on the order of the entries, except for the errno of competing
+// SCMP_ACT_ERRNO rules.
+func newFilter(s *specs.LinuxSeccomp, arch specs.Arch) (*filter, error) {
+	info, table, err := lookupArch(arch)
+	if err != nil {
+		return nil, err
+	}
+	def, err := ReturnValue(s.DefaultAction, s.DefaultErrnoRet)
+	if err != nil {
+		return nil, fmt.Errorf("defaultAction: %w", err)
+	}
+	f := &filter{arch: arch, info: info, def: def, syscalls: make(map[uint32][]rule)}
+	for i, sc := range s.Syscalls {
+		rs, err := rules(sc, i)
+		if err != nil {
+			return nil, fmt.Errorf("syscalls[%d]: %w", i, err)
+		}
+		for _, name := range sc.Names {
+			nr, ok := table[name]
+			if !ok {
+				continue
+			}
+			f.syscalls[nr] = append(f.syscalls[nr], rs...)
+		}
+	}
+	for nr, rs := range f.syscalls {
+		sort.SliceStable(rs, func(i, j int) bool { return Precedes(rs[i].ret, rs[j].ret) })
+		// Rules following an unconditional rule can never match.
+		for i, r := range rs {
+			if len(r.conds) == 0 {
+				rs = rs[:i+1]
+				break
+			}
+		}
+		f.syscalls[nr] = rs
+	}
+	return f, nil
+}
+
+// filterArches returns the architectures of the filter compiled for the
+// native architecture arch: arch itself followed by s.Architectures.
+func filterArches(s *specs.LinuxSeccomp, arch specs.Arch) []specs.Arch {
+	list := []specs.Arch{arch}
+	for _, a := range s.Architectures {
+		dup := false
+		for _, b := range list {
+			dup = dup || a == b
+		}
+		if !dup {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// Compile compiles s into a program for a kernel running on arch. The
+// program accepts the system calls of arch and of s.Architectures, and
+// returns BadArchReturn for any other architecture. s.Flags and the
+// listener fields do not affect the program, and must be applied when it
+// is loaded.
+//
+// On 32-bit architectures, arguments and the values they are compared to
+// are truncated to 32 bits. SCMP_ARCH_SH and SCMP_ARCH_SHEB are not
+// supported.
+func Compile(s *specs.LinuxSeccomp, arch specs.Arch) (Program, error) {
+	if s == nil {
+		return nil, fmt.Errorf("seccomp configuration is nil")
+	}
+	type group struct {
+		audit   uint32
+		filters []*filter
+	}
+	var groups []*group
+	for _, a := range filterArches(s, arch) {
+		f, err := newFilter(s, a)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a, err)
+		}
+		var g *group
+		for _, h := range groups {
+			if h.audit == f.info.audit {
+				g = h
+			}
+		}
+		if g == nil {
+			g = &group{audit: f.info.audit}
+			groups = append(groups, g)
+		}
+		g.filters = append(g.filters, f)
+	}
+
+	asm := newAssembler()
+	badArch := asm.newLabel()
+	labels := make([]label, len(groups))
+	asm.emit(opLoad, offsetArch)
+	for i, g := range groups {
+		labels[i] = asm.newLabel()
+		asm.jump(opJEQ, g.audit, labels[i], next)
+	}
+	asm.emit(opRet, BadArchReturn)
+	for i, g := range groups {
+		asm.place(labels[i])
+		asm.emit(opLoad, offsetNr)
+		if g.audit != arches[specs.ArchX86_64].audit {
+			compileFilter(asm, g.filters[0])
+			continue
+		}
+		// x86_64 and x32 share their audit architecture, and are told
+		// apart by the x32 system call bit.
+		var native, x32 *filter
+		for _, f := range g.filters {
+			if f.arch == specs.ArchX32 {
+				x32 = f
+			} else {
+				native = f
+			}
+		}
+		switch {
+		case native != nil && x32 != nil:
+			l := asm.newLabel()
+			asm.jump(opJGE, x32SyscallBit, l, next)
+			compileFilter(asm, native)
+			asm.place(l)
+			compileFilter(asm, x32)
+		case native != nil:
+			asm.jump(opJGE, x32SyscallBit, badArch, next)
+			compileFilter(asm, native)
+		default:
+			asm.jump(opJGE, x32SyscallBit, next, badArch)
+			compileFilter(asm, x32)
+		}
+	}
+	asm.place(badArch)
+	asm.emit(opRet, BadArchReturn)
+	return asm.assemble()
+}
+
+// compileFilter emits the decision procedure of f. The system call number
+// must be loaded in the accumulator, and the procedure always returns.
+func compileFilter(asm *assembler, f *filter) {
+	nrs := make([]uint32, 0, len(f.syscalls))
+	for nr, rs := range f.syscalls {
+		// Leave out the system calls which always get the default action.
+		if len(rs) == 1 && len(rs[0].conds) == 0 && rs[0].ret == f.def {
+			continue
+		}
+		nrs = append(nrs, nr)
+	}
+	sort.Slice(nrs, func(i, j int) bool { return nrs[i] < nrs[j] })
+
+	// System calls with the same rules share their code.
+	bodies := make(map[string]label)
+	var order []string
+	targets := make(map[uint32]label)
+	rulesOf := make(map[string][]rule)
+	for _, nr := range nrs {
+		key := rulesKey(f.syscalls[nr])
+		l, ok := bodies[key]
+		if !ok {
+			l = asm.newLabel()
+			bodies[key] = l
+			order = append(order, key)
+			rulesOf[key] = f.syscalls[nr]
+		}
+		targets[nr] = l
+	}
+
+	def := asm.newLabel()
+	search(asm, nrs, targets, def, true)
+	asm.place(def)
+	asm.emit(opRet, f.def)
+	for _, key := range order {
+		asm.place(bodies[key])
+		compileRules(asm, f, rulesOf[key])
+	}
+}
+
+// search emits a binary search of the system call number among nrs, jumping
+// to its target, or to def if it is not found. last is set when def
+// immediately follows the search.
+func search(asm *assembler, nrs []uint32, targets map[uint32]label, def label, last bool) {
+	if len(nrs) <= 4 {
+		for _, nr := range nrs {
+			asm.jump(opJEQ, nr, targets[nr], next)
+		}
+		if !last {
+			asm.jumpTo(def)
+		}
+		return
+	}
+	mid := len(nrs) / 2
+	upper := asm.newLabel()
+	asm.jump(opJGE, nrs[mid], upper, next)
+	search(asm, nrs[:mid], targets, def, false)
+	asm.place(upper)
+	search(asm, nrs[mid:], targets, def, last)
+}
+
+func compileRules(asm *assembler, f *filter, rs []rule) {
+	for _, r := range rs {
+		fail := asm.newLabel()
+		for _, c := range r.conds {
+			compileCond(asm, f.info, c, fail)
+		}
+		asm.emit(opRet, r.ret)
+		asm.place(fail)
+		if len(r.conds) == 0 {
+			return
+		}
+	}
+	asm.emit(opRet, f.def)
+}
+
+// argOffsets returns the offsets of the low and high words of argument
+// index in seccomp_data.
+func argOffsets(info archInfo, index uint) (lo, hi uint32) {
+	base := uint32(offsetArgs + 8*index)
+	if info.bigEndian {
+		return base + 4, base
+	}
+	return base, base + 4
+}
+
+// compileCond emits the comparison c, falling through when it holds and
+// jumping to fail otherwise. 64-bit comparisons are made on the high word
+// first, then on the low word.
+func compileCond(asm *assembler, info archInfo, c cond, fail label) {
+	lo, hi := argOffsets(info, c.index)
+	pass := asm.newLabel()
+	defer asm.place(pass)
+
+	vlo, vhi := uint32(c.value), uint32(c.value>>32)
+	if !info.is64 {
+		compileCond32(asm, c, lo, vlo, fail)
+		return
+	}
+	switch c.op {
+	case specs.OpEqualTo:
+		asm.emit(opLoad, hi)
+		asm.jump(opJEQ, vhi, next, fail)
+		asm.emit(opLoad, lo)
+		asm.jump(opJEQ, vlo, next, fail)
+	case specs.OpNotEqual:
+		asm.emit(opLoad, hi)
+		asm.jump(opJEQ, vhi, next, pass)
+		asm.emit(opLoad, lo)
+		asm.jump(opJEQ, vlo, fail, next)
+	case specs.OpMaskedEqual:
+		dlo, dhi := uint32(c.valueTwo), uint32(c.valueTwo>>32)
+		switch {
+		case vhi == 0 && dhi != 0:
+			// The masked high word is always zero.
+			asm.jumpTo(fail)
+			return
+		case vhi != 0:
+			asm.emit(opLoad, hi)
+			asm.emit(opAnd, vhi)
+			asm.jump(opJEQ, dhi, next, fail)
+		}
+		asm.emit(opLoad, lo)
+		asm.emit(opAnd, vlo)
+		asm.jump(opJEQ, dlo, next, fail)
+	case specs.OpGreaterThan, specs.OpGreaterEqual:
+		asm.emit(opLoad, hi)
+		asm.jump(opJGT, vhi, pass, next)
+		asm.jump(opJEQ, vhi, next, fail)
+		asm.emit(opLoad, lo)
+		op := uint16(opJGT)
+		if c.op == specs.OpGreaterEqual {
+			op = opJGE
+		}
+		asm.jump(op, vlo, next, fail)
+	case specs.OpLessThan, specs.OpLessEqual:
+		asm.emit(opLoad, hi)
+		asm.jump(opJGE, vhi, next, pass)
+		asm.jump(opJGT, vhi, fail, next)
+		asm.emit(opLoad, lo)
+		op := uint16(opJGE)
+		if c.op == specs.OpLessEqual {
+			op = opJGT
+		}
+		asm.jump(op, vlo, fail, next)
+	}
+}
+
+func compileCond32(asm *assembler, c cond, off, v uint32, fail label) {
+	asm.emit(opLoad, off)
+	switch c.op {
+	case specs.OpEqualTo:
+		asm.jump(opJEQ, v, next, fail)
+	case specs.OpNotEqual:
+		asm.jump(opJEQ, v, fail, next)
+	case specs.OpMaskedEqual:
+		asm.emit(opAnd, v)
+		asm.jump(opJEQ, uint32(c.valueTwo), next, fail)
+	case specs.OpGreaterThan:
+		asm.jump(opJGT, v, next, fail)
+	case specs.OpGreaterEqual:
+		asm.jump(opJGE, v, next, fail)
+	case specs.OpLessThan:
+		asm.jump(opJGE, v, fail, next)
+	case specs.OpLessEqual:
+		asm.jump(opJGT, v, fail, next)
+	}
+}
+
+// rulesKey returns a string identifying rs.
+func rulesKey(rs []rule) string {
+	var b strings.Builder
+	for _, r := range rs {
+		fmt.Fprintf(&b, "%08x", r.ret)
+		for _, c := range r.conds {
+			fmt.Fprintf(&b, ",%d %s %d %d", c.index, c.op, c.value, c.valueTwo)
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
@@ -0,0 +1,305 @@
+package seccomp
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func uintPtr(u uint) *uint { return &u }
+
+func TestReturnValue(t *testing.T) {
+	for _, tc := range []struct {
+		action specs.LinuxSeccompAction
+		errno  *uint
+		want   uint32
+		err    bool
+	}{
+		{action: specs.ActAllow, want: RetAllow},
+		{action: specs.ActAllow, errno: uintPtr(5), want: RetAllow},
+		{action: specs.ActKill, want: RetKillThread},
+		{action: specs.ActKillProcess, want: RetKillProcess},
+		{action: specs.ActErrno, want: RetErrno | DefaultErrno},
+		{action: specs.ActErrno, errno: uintPtr(38), want: RetErrno | 38},
+		{action: specs.ActErrno, errno: uintPtr(0), want: RetErrno},
+		{action: specs.ActTrace, errno: uintPtr(7), want: RetTrace | 7},
+		{action: specs.ActNotify, want: RetUserNotif},
+		{action: specs.ActErrno, errno: uintPtr(0x10000), err: true},
+		{action: "SCMP_ACT_BOGUS", err: true},
+	} {
+		got, err := ReturnValue(tc.action, tc.errno)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ReturnValue(%s) = %#x, want an error", tc.action, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ReturnValue(%s) = %#x, %v, want %#x", tc.action, got, err, tc.want)
+		}
+		if action, _ := Action(got); tc.action != specs.ActKill && action != tc.action {
+			t.Errorf("Action(%#x) = %s, want %s", got, action, tc.action)
+		}
+	}
+}
+
+func TestPrecedes(t *testing.T) {
+	order := []uint32{RetKillProcess, RetKillThread, RetTrap, RetErrno | 1, RetUserNotif, RetTrace, RetLog, RetAllow}
+	for i, a := range order {
+		for j, b := range order {
+			if got := Precedes(a, b); got != (i < j) {
+				t.Errorf("Precedes(%#x, %#x) = %v", a, b, got)
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		s    *specs.LinuxSeccomp
+		arch specs.Arch
+	}{
+		{name: "nil", arch: specs.ArchX86_64},
+		{name: "unknown architecture", s: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow}, arch: "SCMP_ARCH_BOGUS"},
+		{name: "unsupported architecture", s: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow}, arch: specs.ArchSH},
+		{
+			name: "unsupported additional architecture",
+			s:    &specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Architectures: []specs.Arch{specs.ArchSHEB}},
+			arch: specs.ArchX86_64,
+		},
+		{name: "unknown default action", s: &specs.LinuxSeccomp{DefaultAction: "SCMP_ACT_BOGUS"}, arch: specs.ArchX86_64},
+		{
+			name: "unknown operator",
+			s: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"read"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{{Index: 0, Op: "SCMP_CMP_BOGUS"}}},
+			}},
+			arch: specs.ArchX86_64,
+		},
+		{
+			name: "argument index",
+			s: &specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"read"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{{Index: 6, Op: specs.OpEqualTo}}},
+			}},
+			arch: specs.ArchX86_64,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if p, err := Compile(tc.s, tc.arch); err == nil {
+				t.Errorf("Compile succeeded with %d instructions", len(p))
+			}
+		})
+	}
+}
+
+// run executes p on a call of nr with args, as issued on arch.
+func run(t *testing.T, p Program, arch specs.Arch, nr uint32, args [6]uint64) uint32 {
+	t.Helper()
+	audit, err := AuditArch(arch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := p.Run(Data{Nr: nr, Arch: audit, Args: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestCompile(t *testing.T) {
+	s := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read", "write", "bogus"}, Action: specs.ActAllow},
+			{Names: []string{"personality"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Value: 0xffffffff, Op: specs.OpEqualTo},
+			}},
+			{Names: []string{"clone"}, Action: specs.ActErrno, ErrnoRet: uintPtr(38), Args: []specs.LinuxSeccompArg{
+				{Index: 0, Value: 0x10000000, ValueTwo: 0x10000000, Op: specs.OpMaskedEqual},
+			}},
+			{Names: []string{"clone"}, Action: specs.ActAllow},
+			{Names: []string{"kill"}, Action: specs.ActLog, Args: []specs.LinuxSeccompArg{
+				{Index: 1, Value: 0x100000000, Op: specs.OpGreaterEqual},
+			}},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		arch    specs.Arch
+		syscall string
+		args    [6]uint64
+		want    uint32
+	}{
+		{name: "allowed", arch: specs.ArchX86_64, syscall: "read", want: RetAllow},
+		{name: "default", arch: specs.ArchX86_64, syscall: "open", want: RetErrno | DefaultErrno},
+		{name: "equal", arch: specs.ArchX86_64, syscall: "personality", args: [6]uint64{0xffffffff}, want: RetAllow},
+		{name: "not equal", arch: specs.ArchX86_64, syscall: "personality", args: [6]uint64{0x1ffffffff}, want: RetErrno | DefaultErrno},
+		{name: "masked", arch: specs.ArchX86_64, syscall: "clone", args: [6]uint64{0x10000011}, want: RetErrno | 38},
+		{name: "masked miss", arch: specs.ArchX86_64, syscall: "clone", args: [6]uint64{0x11}, want: RetAllow},
+		{name: "upper word", arch: specs.ArchX86_64, syscall: "kill", args: [6]uint64{0, 0x100000000}, want: RetLog},
+		{name: "lower word", arch: specs.ArchX86_64, syscall: "kill", args: [6]uint64{0, 0xffffffff}, want: RetErrno | DefaultErrno},
+		{name: "truncated", arch: specs.ArchX86, syscall: "kill", args: [6]uint64{0, 1}, want: RetLog},
+		{name: "truncated equal", arch: specs.ArchX86, syscall: "personality", args: [6]uint64{0x1ffffffff}, want: RetAllow},
+		{name: "big-endian", arch: specs.ArchS390X, syscall: "kill", args: [6]uint64{0, 0x100000000}, want: RetLog},
+		{name: "big-endian lower word", arch: specs.ArchS390X, syscall: "kill", args: [6]uint64{0, 0xffffffff}, want: RetErrno | DefaultErrno},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Compile(s, tc.arch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nr, err := SyscallNumber(tc.arch, tc.syscall)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := run(t, p, tc.arch, nr, tc.args); got != tc.want {
+				t.Errorf("got %s, want %s\n%v", describeReturn(got), describeReturn(tc.want), p)
+			}
+		})
+	}
+}
+
+func TestSyscallNumber(t *testing.T) {
+	for _, tc := range []struct {
+		arch specs.Arch
+		name string
+		nr   uint32
+	}{
+		{specs.ArchX86_64, "read", 0},
+		{specs.ArchX32, "read", x32SyscallBit},
+		{specs.ArchMIPSEL, "read", 4003},
+		{specs.ArchPPC64LE, "spu_run", 278},
+		{specs.ArchARM, "breakpoint", 0xf0001},
+		{specs.ArchARM, "cacheflush", 0xf0002},
+		{specs.ArchARM, "set_tls", 0xf0005},
+		{specs.ArchARM, "get_tls", 0xf0006},
+	} {
+		nr, err := SyscallNumber(tc.arch, tc.name)
+		if err != nil || nr != tc.nr {
+			t.Errorf("SyscallNumber(%s, %q) = %#x, %v, want %#x", tc.arch, tc.name, nr, err, tc.nr)
+		}
+		if name, err := SyscallName(tc.arch, tc.nr); err != nil || name != tc.name {
+			t.Errorf("SyscallName(%s, %#x) = %q, %v, want %q", tc.arch, tc.nr, name, err, tc.name)
+		}
+	}
+	for _, arch := range []specs.Arch{specs.ArchSH, specs.ArchSHEB} {
+		if _, err := Syscalls(arch); err == nil {
+			t.Errorf("%s: system calls without a table", arch)
+		}
+	}
+}
+
+func TestCompileArchitectures(t *testing.T) {
+	read := func(arch specs.Arch) uint32 {
+		nr, err := SyscallNumber(arch, "read")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nr
+	}
+	for _, tc := range []struct {
+		name   string
+		arches []specs.Arch
+		call   specs.Arch
+		want   uint32
+	}{
+		{name: "native", call: specs.ArchX86_64, want: RetAllow},
+		{name: "x32 rejected", call: specs.ArchX32, want: BadArchReturn},
+		{name: "x86 rejected", call: specs.ArchX86, want: BadArchReturn},
+		{name: "x32", arches: []specs.Arch{specs.ArchX32}, call: specs.ArchX32, want: RetAllow},
+		{name: "x86", arches: []specs.Arch{specs.ArchX86, specs.ArchX32}, call: specs.ArchX86, want: RetAllow},
+		{name: "other", arches: []specs.Arch{specs.ArchX86}, call: specs.ArchAARCH64, want: BadArchReturn},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &specs.LinuxSeccomp{
+				DefaultAction: specs.ActErrno,
+				Architectures: tc.arches,
+				Syscalls:      []specs.LinuxSyscall{{Names: []string{"read"}, Action: specs.ActAllow}},
+			}
+			p, err := Compile(s, specs.ArchX86_64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nr := read(tc.call)
+			if tc.call == specs.ArchX32 {
+				nr |= x32SyscallBit
+			}
+			if got := run(t, p, tc.call, nr, [6]uint64{}); got != tc.want {
+				t.Errorf("got %s, want %s", describeReturn(got), describeReturn(tc.want))
+			}
+		})
+	}
+}
+
+// TestCompileRandom checks that compiled programs agree with EvaluateNumber
+// on random profiles, including ones large enough to need long jumps.
+func TestCompileRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	actions := []specs.LinuxSeccompAction{specs.ActAllow, specs.ActErrno, specs.ActKillProcess, specs.ActLog, specs.ActTrap, specs.ActTrace}
+	ops := []specs.LinuxSeccompOperator{specs.OpNotEqual, specs.OpLessThan, specs.OpLessEqual, specs.OpEqualTo, specs.OpGreaterEqual, specs.OpGreaterThan, specs.OpMaskedEqual}
+	value := func() uint64 {
+		edges := []uint64{0, 1, 2, 5, 0x7fffffff, 0xffffffff, 0x100000000, 0x100000001, 0xffffffff00000000, ^uint64(0)}
+		if r.Intn(3) == 0 {
+			return r.Uint64()
+		}
+		return edges[r.Intn(len(edges))]
+	}
+	for _, arch := range []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchS390X, specs.ArchMIPS, specs.ArchAARCH64, specs.ArchPPC64LE} {
+		names, err := Syscalls(arch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 30; i++ {
+			s := &specs.LinuxSeccomp{DefaultAction: actions[r.Intn(len(actions))]}
+			for j := 0; j < 1+r.Intn(120); j++ {
+				sc := specs.LinuxSyscall{Action: actions[r.Intn(len(actions))], ErrnoRet: uintPtr(uint(r.Intn(100)))}
+				for k := 0; k < 1+r.Intn(4); k++ {
+					sc.Names = append(sc.Names, names[r.Intn(20)])
+				}
+				for k := 0; k < r.Intn(4); k++ {
+					sc.Args = append(sc.Args, specs.LinuxSeccompArg{Index: uint(r.Intn(3)), Value: value(), ValueTwo: value(), Op: ops[r.Intn(len(ops))]})
+				}
+				s.Syscalls = append(s.Syscalls, sc)
+			}
+			p, err := Compile(s, arch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 300; j++ {
+				nr, err := SyscallNumber(arch, names[r.Intn(22)])
+				if err != nil {
+					t.Fatal(err)
+				}
+				var args [6]uint64
+				for k := range args {
+					args[k] = value()
+				}
+				want, err := EvaluateNumber(s, arch, nr, args[:]...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := run(t, p, arch, nr, args); got != want.Ret {
+					t.Fatalf("%s: nr %d args %#x: got %s, want %s", arch, nr, args, describeReturn(got), describeReturn(want.Ret))
+				}
+			}
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	p := Program{{Code: opJEQ, Jt: 1, Jf: 2, K: 0x01020304}}
+	for _, tc := range []struct {
+		order binary.ByteOrder
+		want  []byte
+	}{
+		{binary.LittleEndian, []byte{0x15, 0x00, 1, 2, 0x04, 0x03, 0x02, 0x01}},
+		{binary.BigEndian, []byte{0x00, 0x15, 1, 2, 0x01, 0x02, 0x03, 0x04}},
+	} {
+		if got := p.Bytes(tc.order); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got % x, want % x", tc.order, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package seccomp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// DockerProfile is a seccomp profile in the format of Docker and Moby.
+type DockerProfile struct {
+	DefaultAction   specs.LinuxSeccompAction `json:"defaultAction"`
+	DefaultErrnoRet *uint                    `json:"defaultErrnoRet,omitempty"`
+	// Architectures and ArchMap are mutually exclusive.
+	Architectures    []specs.Arch             `json:"architectures,omitempty"`
+	ArchMap          []DockerArch             `json:"archMap,omitempty"`
+	Flags            []specs.LinuxSeccompFlag `json:"flags,omitempty"`
+	ListenerPath     string                   `json:"listenerPath,omitempty"`
+	ListenerMetadata string                   `json:"listenerMetadata,omitempty"`
+	Syscalls         []DockerSyscall          `json:"syscalls"`
+}
+
+// DockerArch lists the architectures of a profile for a native
+// architecture.
+type DockerArch struct {
+	Arch      specs.Arch   `json:"architecture"`
+	SubArches []specs.Arch `json:"subArchitectures"`
+}
+
+// DockerSyscall is a rule of a Docker profile, which only applies when its
+// Includes conditions hold and its Excludes conditions do not.
+type DockerSyscall struct {
+	Names []string `json:"names,omitempty"`
+	// Name is the deprecated form of Names, and is mutually exclusive with
+	// it.
+	Name     string                   `json:"name,omitempty"`
+	Action   specs.LinuxSeccompAction `json:"action"`
+	ErrnoRet *uint                    `json:"errnoRet,omitempty"`
+	Args     []specs.LinuxSeccompArg  `json:"args,omitempty"`
+	Comment  string                   `json:"comment,omitempty"`
+	Includes *DockerFilter            `json:"includes,omitempty"`
+	Excludes *DockerFilter            `json:"excludes,omitempty"`
+}
+
+// DockerFilter holds the conditions of a rule of a Docker profile. An
+// include filter holds when all of its conditions hold, an exclude filter
+// when any of them does.
+type DockerFilter struct {
+	// Caps are capabilities of the bounding set, such as "CAP_SYS_ADMIN".
+	Caps []string `json:"caps,omitempty"`
+	// Arches are native architectures, named as GOARCH, such as "amd64".
+	Arches []string `json:"arches,omitempty"`
+	// MinKernel is the minimum version of the kernel.
+	MinKernel *KernelVersion `json:"minKernel,omitempty"`
+}
+
+// KernelVersion is the version of a Linux kernel, such as 5.10.
+type KernelVersion struct {
+	Major uint64
+	Minor uint64
+}
+
+// ParseKernelVersion parses the major and minor numbers of a kernel
+// release, such as "5.10" or "5.10.0-28-amd64".
+func ParseKernelVersion(s string) (KernelVersion, error) {
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) < 2 {
+		return KernelVersion{}, fmt.Errorf("invalid kernel version %q", s)
+	}
+	// The minor number may be followed by a suffix, as in "6.9-rc1".
+	minor := parts[1]
+	if i := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minor = minor[:i]
+	}
+	var v KernelVersion
+	var err1, err2 error
+	v.Major, err1 = strconv.ParseUint(parts[0], 10, 64)
+	v.Minor, err2 = strconv.ParseUint(minor, 10, 64)
+	if err1 != nil || err2 != nil {
+		return KernelVersion{}, fmt.Errorf("invalid kernel version %q", s)
+	}
+	return v, nil
+}
+
+// String returns v in the "major.minor" form.
+func (v KernelVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Less reports whether v is older than w.
+func (v KernelVersion) Less(w KernelVersion) bool {
+	if v.Major != w.Major {
+		return v.Major < w.Major
+	}
+	return v.Minor < w.Minor
+}
+
+// MarshalJSON encodes v as a "major.minor" string.
+func (v KernelVersion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON decodes v from a "major.minor" string.
+func (v *KernelVersion) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	kv, err := ParseKernelVersion(s)
+	if err != nil {
+		return err
+	}
+	*v = kv
+	return nil
+}
+
+// Target describes the container and host a Docker profile is resolved
+// for.
+type Target struct {
+	// Arch is the native architecture.
+	Arch specs.Arch
+	// Capabilities is the bounding set of the container.
+	Capabilities []string
+	// Kernel is the version of the host kernel. It is only needed when the
+	// profile has minKernel conditions.
+	Kernel *KernelVersion
+}
+
+// ParseDocker decodes a Docker profile.
+func ParseDocker(data []byte) (*DockerProfile, error) {
+	var p DockerProfile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Resolve returns the seccomp configuration of p for t, as done by Docker:
+// the architectures are taken from the archMap entry of t.Arch, and the
+// rules whose conditions do not hold for t are left out. Arches of filters
+// match either the GOARCH name or the name of t.Arch.
+func (p *DockerProfile) Resolve(t Target) (*specs.LinuxSeccomp, error) {
+	if len(p.Architectures) != 0 && len(p.ArchMap) != 0 {
+		return nil, errors.New("both architectures and archMap are set")
+	}
+	s := &specs.LinuxSeccomp{
+		DefaultAction:    p.DefaultAction,
+		DefaultErrnoRet:  p.DefaultErrnoRet,
+		Architectures:    append([]specs.Arch(nil), p.Architectures...),
+		Flags:            append([]specs.LinuxSeccompFlag(nil), p.Flags...),
+		ListenerPath:     p.ListenerPath,
+		ListenerMetadata: p.ListenerMetadata,
+	}
+	for _, a := range p.ArchMap {
+		if a.Arch == t.Arch {
+			s.Architectures = append(s.Architectures, a.Arch)
+			s.Architectures = append(s.Architectures, a.SubArches...)
+		}
+	}
+	for i, sc := range p.Syscalls {
+		names := append([]string(nil), sc.Names...)
+		if sc.Name != "" {
+			if len(sc.Names) != 0 {
+				return nil, fmt.Errorf("syscalls[%d]: both name and names are set", i)
+			}
+			names = []string{sc.Name}
+		}
+		if sc.Excludes != nil {
+			ok, err := sc.Excludes.any(t)
+			if err != nil {
+				return nil, fmt.Errorf("syscalls[%d].excludes: %w", i, err)
+			}
+			if ok {
+				continue
+			}
+		}
+		if sc.Includes != nil {
+			ok, err := sc.Includes.all(t)
+			if err != nil {
+				return nil, fmt.Errorf("syscalls[%d].includes: %w", i, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		s.Syscalls = append(s.Syscalls, specs.LinuxSyscall{
+			Names:    names,
+			Action:   sc.Action,
+			ErrnoRet: sc.ErrnoRet,
+			Args:     append([]specs.LinuxSeccompArg(nil), sc.Args...),
+		})
+	}
+	return s, nil
+}
+
+// all reports whether all the conditions of f hold for t.
+func (f *DockerFilter) all(t Target) (bool, error) {
+	if len(f.Arches) > 0 && !t.hasArch(f.Arches) {
+		return false, nil
+	}
+	for _, c := range f.Caps {
+		if !contains(t.Capabilities, c) {
+			return false, nil
+		}
+	}
+	if f.MinKernel != nil {
+		return t.kernelAtLeast(*f.MinKernel)
+	}
+	return true, nil
+}
+
+// any reports whether any of the conditions of f holds for t.
+func (f *DockerFilter) any(t Target) (bool, error) {
+	if len(f.Arches) > 0 && t.hasArch(f.Arches) {
+		return true, nil
+	}
+	for _, c := range f.Caps {
+		if contains(t.Capabilities, c) {
+			return true, nil
+		}
+	}
+	if f.MinKernel != nil {
+		return t.kernelAtLeast(*f.MinKernel)
+	}
+	return false, nil
+}
+
+func (t Target) hasArch(arches []string) bool {
+	goArch, ok := goArches[t.Arch]
+	for _, a := range arches {
+		if (ok && a == goArch) || a == string(t.Arch) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Target) kernelAtLeast(v KernelVersion) (bool, error) {
+	if t.Kernel == nil {
+		return false, fmt.Errorf("minKernel %s needs the kernel version", v)
+	}
+	return !t.Kernel.Less(v), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportDocker returns s as a Docker profile. The rules of the profile are
+// unconditional, and the architectures are kept in Architectures.
+func ExportDocker(s *specs.LinuxSeccomp) *DockerProfile {
+	p := &DockerProfile{
+		DefaultAction:    s.DefaultAction,
+		DefaultErrnoRet:  s.DefaultErrnoRet,
+		Architectures:    append([]specs.Arch(nil), s.Architectures...),
+		Flags:            append([]specs.LinuxSeccompFlag(nil), s.Flags...),
+		ListenerPath:     s.ListenerPath,
+		ListenerMetadata: s.ListenerMetadata,
+		Syscalls:         make([]DockerSyscall, 0, len(s.Syscalls)),
+	}
+	for _, sc := range s.Syscalls {
+		p.Syscalls = append(p.Syscalls, DockerSyscall{
+			Names:    append([]string(nil), sc.Names...),
+			Action:   sc.Action,
+			ErrnoRet: sc.ErrnoRet,
+			Args:     append([]specs.LinuxSeccompArg(nil), sc.Args...),
+		})
+	}
+	return p
+}
@@ -0,0 +1,204 @@
+package seccomp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const dockerProfile = `{
+	"defaultAction": "SCMP_ACT_ERRNO",
+	"defaultErrnoRet": 1,
+	"archMap": [
+		{"architecture": "SCMP_ARCH_X86_64", "subArchitectures": ["SCMP_ARCH_X86", "SCMP_ARCH_X32"]},
+		{"architecture": "SCMP_ARCH_AARCH64", "subArchitectures": ["SCMP_ARCH_ARM"]}
+	],
+	"syscalls": [
+		{"names": ["read", "write"], "action": "SCMP_ACT_ALLOW"},
+		{"name": "open", "action": "SCMP_ACT_ALLOW"},
+		{"names": ["mount"], "action": "SCMP_ACT_ALLOW", "includes": {"caps": ["CAP_SYS_ADMIN"]}},
+		{"names": ["clone"], "action": "SCMP_ACT_ALLOW", "excludes": {"caps": ["CAP_SYS_ADMIN"]},
+			"args": [{"index": 0, "value": 2114060288, "op": "SCMP_CMP_MASKED_EQ"}]},
+		{"names": ["arch_prctl"], "action": "SCMP_ACT_ALLOW", "includes": {"arches": ["amd64", "x32"]}},
+		{"names": ["sync_file_range2"], "action": "SCMP_ACT_ALLOW", "includes": {"arches": ["SCMP_ARCH_AARCH64"]}},
+		{"names": ["clone3"], "action": "SCMP_ACT_ALLOW", "includes": {"minKernel": "5.3"}}
+	]
+}`
+
+func TestResolve(t *testing.T) {
+	p, err := ParseDocker([]byte(dockerProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := func(s string) *KernelVersion {
+		kv, err := ParseKernelVersion(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &kv
+	}
+	for _, tc := range []struct {
+		name   string
+		target Target
+		arches []specs.Arch
+		names  [][]string
+	}{
+		{
+			name:   "amd64",
+			target: Target{Arch: specs.ArchX86_64, Kernel: v("5.2")},
+			arches: []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchX32},
+			names:  [][]string{{"read", "write"}, {"open"}, {"clone"}, {"arch_prctl"}},
+		},
+		{
+			name:   "privileged",
+			target: Target{Arch: specs.ArchX86_64, Capabilities: []string{"CAP_SYS_ADMIN"}, Kernel: v("6.1.0-13-amd64")},
+			arches: []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchX32},
+			names:  [][]string{{"read", "write"}, {"open"}, {"mount"}, {"arch_prctl"}, {"clone3"}},
+		},
+		{
+			name:   "arm64",
+			target: Target{Arch: specs.ArchAARCH64, Kernel: v("5.3")},
+			arches: []specs.Arch{specs.ArchAARCH64, specs.ArchARM},
+			names:  [][]string{{"read", "write"}, {"open"}, {"clone"}, {"sync_file_range2"}, {"clone3"}},
+		},
+		{
+			name:   "unmapped",
+			target: Target{Arch: specs.ArchS390X, Kernel: v("4.19")},
+			names:  [][]string{{"read", "write"}, {"open"}, {"clone"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := p.Resolve(tc.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.Architectures, tc.arches) {
+				t.Errorf("architectures %v, want %v", s.Architectures, tc.arches)
+			}
+			var names [][]string
+			for _, sc := range s.Syscalls {
+				names = append(names, sc.Names)
+			}
+			if !reflect.DeepEqual(names, tc.names) {
+				t.Errorf("names %v, want %v", names, tc.names)
+			}
+			if s.DefaultAction != specs.ActErrno || s.DefaultErrnoRet == nil || *s.DefaultErrnoRet != 1 {
+				t.Errorf("default action %s(%v)", s.DefaultAction, s.DefaultErrnoRet)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		profile string
+		target  Target
+	}{
+		{
+			name:    "architectures and archMap",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "architectures": ["SCMP_ARCH_X86"], "archMap": [{"architecture": "SCMP_ARCH_X86_64"}]}`,
+		},
+		{
+			name:    "name and names",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"name": "read", "names": ["write"], "action": "SCMP_ACT_ALLOW"}]}`,
+		},
+		{
+			name:    "no kernel version",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["read"], "action": "SCMP_ACT_ALLOW", "includes": {"minKernel": "5.3"}}]}`,
+		},
+		{
+			name:    "no kernel version in excludes",
+			profile: `{"defaultAction": "SCMP_ACT_ALLOW", "syscalls": [{"names": ["read"], "action": "SCMP_ACT_ALLOW", "excludes": {"minKernel": "5.3"}}]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseDocker([]byte(tc.profile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s, err := p.Resolve(tc.target); err == nil {
+				t.Errorf("Resolve succeeded with %+v", s)
+			}
+		})
+	}
+	if _, err := ParseDocker([]byte(`{"syscalls": [{"names": ["read"], "includes": {"minKernel": "five"}}]}`)); err == nil {
+		t.Error("ParseDocker accepted an invalid kernel version")
+	}
+}
+
+func TestKernelVersion(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want KernelVersion
+		err  bool
+	}{
+		{in: "5.10", want: KernelVersion{5, 10}},
+		{in: "5.10.0-28-amd64", want: KernelVersion{5, 10}},
+		{in: "6.9-rc1", want: KernelVersion{6, 9}},
+		{in: "4.19.0", want: KernelVersion{4, 19}},
+		{in: "5", err: true},
+		{in: "a.b", err: true},
+		{in: "5.x", err: true},
+		{in: "", err: true},
+	} {
+		got, err := ParseKernelVersion(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseKernelVersion(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseKernelVersion(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		v, w KernelVersion
+		less bool
+	}{
+		{KernelVersion{5, 3}, KernelVersion{5, 10}, true},
+		{KernelVersion{4, 20}, KernelVersion{5, 0}, true},
+		{KernelVersion{5, 10}, KernelVersion{5, 10}, false},
+		{KernelVersion{6, 0}, KernelVersion{5, 19}, false},
+	} {
+		if got := tc.v.Less(tc.w); got != tc.less {
+			t.Errorf("%v.Less(%v) = %v", tc.v, tc.w, got)
+		}
+	}
+
+	data, err := json.Marshal(KernelVersion{5, 10})
+	if err != nil || string(data) != `"5.10"` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
+
+func TestExportDocker(t *testing.T) {
+	s := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Architectures: []specs.Arch{specs.ArchX86},
+		Flags:         []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog},
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read"}, Action: specs.ActAllow},
+			{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: []specs.LinuxSeccompArg{{Index: 1, Value: 9, Op: specs.OpEqualTo}}},
+		},
+	}
+	data, err := json.Marshal(ExportDocker(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseDocker(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Resolve(Target{Arch: specs.ArchX86_64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("round trip gave %+v, want %+v", got, s)
+	}
+}
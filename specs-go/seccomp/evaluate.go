@@ -0,0 +1,173 @@
+package seccomp
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Result is the outcome of a system call under a seccomp profile.
+type Result struct {
+	// Action is the action taken.
+	Action specs.LinuxSeccompAction
+	// Errno is the errno returned by SCMP_ACT_ERRNO, or the message of
+	// SCMP_ACT_TRACE.
+	Errno uint
+	// Ret is the value returned by the filter.
+	Ret uint32
+	// Entry is the index in Syscalls of the entry which decided the
+	// action, or -1 for the default action.
+	Entry int
+}
+
+// Evaluate returns the outcome of calling the system call name on arch with
+// args, which holds up to six arguments, under s. Missing arguments are
+// zero.
+//
+// Rules are evaluated as by the program built by Compile for arch: when
+// several entries match, the action with the highest precedence wins (see
+// Precedes), and the first matching entry among those of the same action.
+// On 32-bit architectures, arguments and the values they are compared to
+// are truncated to 32 bits.
+func Evaluate(s *specs.LinuxSeccomp, arch specs.Arch, name string, args ...uint64) (Result, error) {
+	nr, err := SyscallNumber(arch, name)
+	if err != nil {
+		return Result{}, err
+	}
+	return EvaluateNumber(s, arch, nr, args...)
+}
+
+// EvaluateNumber is like Evaluate, but takes the number of the system call.
+func EvaluateNumber(s *specs.LinuxSeccomp, arch specs.Arch, nr uint32, args ...uint64) (Result, error) {
+	if s == nil {
+		return Result{}, fmt.Errorf("seccomp configuration is nil")
+	}
+	if len(args) > 6 {
+		return Result{}, fmt.Errorf("%d arguments given, at most 6 are supported", len(args))
+	}
+	f, err := newFilter(s, arch)
+	if err != nil {
+		return Result{}, err
+	}
+	var a [6]uint64
+	copy(a[:], args)
+	for _, r := range f.syscalls[nr] {
+		if r.matches(f.info, a) {
+			return result(r.ret, r.entry), nil
+		}
+	}
+	return result(f.def, -1), nil
+}
+
+func result(ret uint32, entry int) Result {
+	action, errno := Action(ret)
+	return Result{Action: action, Errno: errno, Ret: ret, Entry: entry}
+}
+
+func (r rule) matches(info archInfo, args [6]uint64) bool {
+	for _, c := range r.conds {
+		if !c.matches(info, args[c.index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c cond) matches(info archInfo, arg uint64) bool {
+	v, v2 := c.value, c.valueTwo
+	if !info.is64 {
+		arg, v, v2 = uint64(uint32(arg)), uint64(uint32(v)), uint64(uint32(v2))
+	}
+	switch c.op {
+	case specs.OpNotEqual:
+		return arg != v
+	case specs.OpLessThan:
+		return arg < v
+	case specs.OpLessEqual:
+		return arg <= v
+	case specs.OpEqualTo:
+		return arg == v
+	case specs.OpGreaterEqual:
+		return arg >= v
+	case specs.OpGreaterThan:
+		return arg > v
+	case specs.OpMaskedEqual:
+		return arg&v == v2
+	}
+	return false
+}
+
+// Data is the input of a seccomp filter, struct seccomp_data.
+type Data struct {
+	Nr                 uint32
+	Arch               uint32
+	InstructionPointer uint64
+	Args               [6]uint64
+}
+
+// Run executes p on d, laid out with the byte order of the architecture
+// identified by d.Arch, and returns the value returned by p. Only the
+// instructions emitted by Compile are supported.
+func (p Program) Run(d Data) (uint32, error) {
+	bigEndian := d.Arch&auditArchLE == 0
+	load := func(off uint32) (uint32, error) {
+		switch {
+		case off == offsetNr:
+			return d.Nr, nil
+		case off == offsetArch:
+			return d.Arch, nil
+		case off == 8 || off == 12:
+			hi := off == 8 == bigEndian
+			if hi {
+				return uint32(d.InstructionPointer >> 32), nil
+			}
+			return uint32(d.InstructionPointer), nil
+		case off >= offsetArgs && off < offsetArgs+48 && off%4 == 0:
+			arg := d.Args[(off-offsetArgs)/8]
+			if (off%8 == 0) == bigEndian {
+				return uint32(arg >> 32), nil
+			}
+			return uint32(arg), nil
+		}
+		return 0, fmt.Errorf("invalid load offset %d", off)
+	}
+
+	var acc uint32
+	for pc := 0; pc < len(p); pc++ {
+		ins := p[pc]
+		var taken bool
+		switch ins.Code {
+		case opLoad:
+			v, err := load(ins.K)
+			if err != nil {
+				return 0, fmt.Errorf("%04d: %w", pc, err)
+			}
+			acc = v
+			continue
+		case opAnd:
+			acc &= ins.K
+			continue
+		case opJA:
+			pc += int(ins.K)
+			continue
+		case opRet:
+			return ins.K, nil
+		case opJEQ:
+			taken = acc == ins.K
+		case opJGT:
+			taken = acc > ins.K
+		case opJGE:
+			taken = acc >= ins.K
+		case opJSET:
+			taken = acc&ins.K != 0
+		default:
+			return 0, fmt.Errorf("%04d: unsupported opcode 0x%02x", pc, ins.Code)
+		}
+		if taken {
+			pc += int(ins.Jt)
+		} else {
+			pc += int(ins.Jf)
+		}
+	}
+	return 0, fmt.Errorf("program does not return")
+}
@@ -0,0 +1,197 @@
+package seccomp
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestEvaluate(t *testing.T) {
+	s := &specs.LinuxSeccomp{
+		DefaultAction:   specs.ActErrno,
+		DefaultErrnoRet: uintPtr(38),
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read", "write"}, Action: specs.ActAllow},
+			{Names: []string{"write"}, Action: specs.ActErrno, ErrnoRet: uintPtr(5), Args: []specs.LinuxSeccompArg{
+				{Index: 0, Value: 2, Op: specs.OpEqualTo},
+			}},
+			{Names: []string{"write"}, Action: specs.ActErrno, ErrnoRet: uintPtr(6)},
+			{Names: []string{"kill"}, Action: specs.ActLog, Args: []specs.LinuxSeccompArg{
+				{Index: 1, Value: 9, Op: specs.OpEqualTo},
+				{Index: 1, Value: 15, Op: specs.OpEqualTo},
+			}},
+			{Names: []string{"kill"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Value: 100, Op: specs.OpGreaterThan},
+				{Index: 1, Value: 0x1, ValueTwo: 0x1, Op: specs.OpMaskedEqual},
+			}},
+			{Names: []string{"personality"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Value: 0x100000008, Op: specs.OpEqualTo},
+			}},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		arch    specs.Arch
+		syscall string
+		args    []uint64
+		want    Result
+	}{
+		{
+			name:    "default",
+			arch:    specs.ArchX86_64,
+			syscall: "open",
+			want:    Result{Action: specs.ActErrno, Errno: 38, Ret: RetErrno | 38, Entry: -1},
+		},
+		{
+			name:    "allowed",
+			arch:    specs.ArchX86_64,
+			syscall: "read",
+			want:    Result{Action: specs.ActAllow, Ret: RetAllow, Entry: 0},
+		},
+		{
+			name:    "precedence over order",
+			arch:    specs.ArchX86_64,
+			syscall: "write",
+			args:    []uint64{1},
+			want:    Result{Action: specs.ActErrno, Errno: 6, Ret: RetErrno | 6, Entry: 2},
+		},
+		{
+			name:    "first entry of an action",
+			arch:    specs.ArchX86_64,
+			syscall: "write",
+			args:    []uint64{2},
+			want:    Result{Action: specs.ActErrno, Errno: 5, Ret: RetErrno | 5, Entry: 1},
+		},
+		{
+			name:    "conditions on one argument are alternatives",
+			arch:    specs.ArchX86_64,
+			syscall: "kill",
+			args:    []uint64{1, 15},
+			want:    Result{Action: specs.ActLog, Ret: RetLog, Entry: 3},
+		},
+		{
+			name:    "conditions on several arguments are combined",
+			arch:    specs.ArchX86_64,
+			syscall: "kill",
+			args:    []uint64{101, 3},
+			want:    Result{Action: specs.ActAllow, Ret: RetAllow, Entry: 4},
+		},
+		{
+			name:    "one condition fails",
+			arch:    specs.ArchX86_64,
+			syscall: "kill",
+			args:    []uint64{100, 3},
+			want:    Result{Action: specs.ActErrno, Errno: 38, Ret: RetErrno | 38, Entry: -1},
+		},
+		{
+			name:    "missing arguments are zero",
+			arch:    specs.ArchX86_64,
+			syscall: "kill",
+			want:    Result{Action: specs.ActErrno, Errno: 38, Ret: RetErrno | 38, Entry: -1},
+		},
+		{
+			name:    "64-bit comparison",
+			arch:    specs.ArchX86_64,
+			syscall: "personality",
+			args:    []uint64{8},
+			want:    Result{Action: specs.ActErrno, Errno: 38, Ret: RetErrno | 38, Entry: -1},
+		},
+		{
+			name:    "32-bit truncation",
+			arch:    specs.ArchX86,
+			syscall: "personality",
+			args:    []uint64{8},
+			want:    Result{Action: specs.ActAllow, Ret: RetAllow, Entry: 5},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Evaluate(s, tc.arch, tc.syscall, tc.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	s := &specs.LinuxSeccomp{DefaultAction: specs.ActAllow}
+	for _, tc := range []struct {
+		name    string
+		s       *specs.LinuxSeccomp
+		arch    specs.Arch
+		syscall string
+		args    []uint64
+	}{
+		{name: "nil", arch: specs.ArchX86_64, syscall: "read"},
+		{name: "unknown system call", s: s, arch: specs.ArchX86_64, syscall: "bogus"},
+		{name: "unknown architecture", s: s, arch: "SCMP_ARCH_BOGUS", syscall: "read"},
+		{name: "too many arguments", s: s, arch: specs.ArchX86_64, syscall: "read", args: make([]uint64, 7)},
+		{name: "invalid profile", s: &specs.LinuxSeccomp{DefaultAction: "SCMP_ACT_BOGUS"}, arch: specs.ArchX86_64, syscall: "read"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if r, err := Evaluate(tc.s, tc.arch, tc.syscall, tc.args...); err == nil {
+				t.Errorf("Evaluate succeeded with %+v", r)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    Program
+		d    Data
+		want uint32
+		err  bool
+	}{
+		{
+			name: "instruction pointer",
+			p:    Program{{Code: opLoad, K: 8}, {Code: opJEQ, Jt: 0, Jf: 1, K: 0x1234}, {Code: opRet, K: RetAllow}, {Code: opRet, K: RetTrap}},
+			d:    Data{Arch: arches[specs.ArchX86_64].audit, InstructionPointer: 0x1234},
+			want: RetAllow,
+		},
+		{
+			name: "little-endian argument",
+			p:    Program{{Code: opLoad, K: offsetArgs + 4}, {Code: opJEQ, Jt: 0, Jf: 1, K: 1}, {Code: opRet, K: RetAllow}, {Code: opRet, K: RetTrap}},
+			d:    Data{Arch: arches[specs.ArchX86_64].audit, Args: [6]uint64{0x100000000}},
+			want: RetAllow,
+		},
+		{
+			name: "big-endian argument",
+			p:    Program{{Code: opLoad, K: offsetArgs}, {Code: opJEQ, Jt: 0, Jf: 1, K: 1}, {Code: opRet, K: RetAllow}, {Code: opRet, K: RetTrap}},
+			d:    Data{Arch: arches[specs.ArchS390X].audit, Args: [6]uint64{0x100000000}},
+			want: RetAllow,
+		},
+		{
+			name: "invalid offset",
+			p:    Program{{Code: opLoad, K: 3}, {Code: opRet, K: RetAllow}},
+			err:  true,
+		},
+		{
+			name: "unsupported opcode",
+			p:    Program{{Code: 0x07}, {Code: opRet, K: RetAllow}},
+			err:  true,
+		},
+		{
+			name: "no return",
+			p:    Program{{Code: opLoad, K: offsetNr}},
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.p.Run(tc.d)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Run succeeded with %#x", got)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("Run = %#x, %v, want %#x", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// Package listener implements both sides of the seccomp listener protocol
+// of the runtime specification. A runtime loading a filter with
+// SCMP_ACT_NOTIFY rules connects to linux.seccomp.listenerPath and sends
+// the container process state along with the seccomp notify file
+// descriptor; a seccomp agent receives them and answers the notifications
+// of the filter.
+//
+// The package is only available on Linux.
+package listener
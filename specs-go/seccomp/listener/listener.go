@@ -0,0 +1,224 @@
+//go:build linux
+
+package listener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// maxFds is the maximum number of file descriptors received with a state.
+const maxFds = 16
+
+// Process is a container process whose state was sent by a runtime.
+type Process struct {
+	// State is the state of the process.
+	State specs.ContainerProcessState
+	// Files maps the names of State.Fds to the file descriptors received.
+	Files map[string]*os.File
+}
+
+// SeccompFile returns the seccomp notify file descriptor of p.
+func (p *Process) SeccompFile() (*os.File, error) {
+	f := p.Files[specs.SeccompFdName]
+	if f == nil {
+		return nil, fmt.Errorf("no %s file descriptor received", specs.SeccompFdName)
+	}
+	return f, nil
+}
+
+// Close closes the file descriptors of p.
+func (p *Process) Close() error {
+	var errs []error
+	for _, f := range p.Files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Receive reads the state sent by a runtime on conn, up to the end of the
+// stream, and the file descriptors sent along with it.
+func Receive(conn *net.UnixConn) (*Process, error) {
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(maxFds*4))
+	n, oobn, flags, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	fds, err := parseRights(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	p, err := receiveState(conn, buf[:n], flags, fds)
+	if err != nil {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func receiveState(conn *net.UnixConn, data []byte, flags int, fds []int) (*Process, error) {
+	if flags&syscall.MSG_CTRUNC != 0 {
+		return nil, fmt.Errorf("more than %d file descriptors received", maxFds)
+	}
+	rest, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, rest...)
+
+	p := &Process{Files: make(map[string]*os.File, len(fds))}
+	if err := json.Unmarshal(data, &p.State); err != nil {
+		return nil, fmt.Errorf("invalid container process state: %w", err)
+	}
+	if len(p.State.Fds) != len(fds) {
+		return nil, fmt.Errorf("%d file descriptors received for %d names", len(fds), len(p.State.Fds))
+	}
+	for _, name := range p.State.Fds {
+		if _, ok := p.Files[name]; ok {
+			return nil, fmt.Errorf("duplicate file descriptor name %q", name)
+		}
+		p.Files[name] = nil
+	}
+	for i, name := range p.State.Fds {
+		if name == specs.SeccompFdName {
+			// Let the poller of the runtime wait for notifications.
+			if err := syscall.SetNonblock(fds[i], true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, name := range p.State.Fds {
+		p.Files[name] = os.NewFile(uintptr(fds[i]), name)
+	}
+	return p, nil
+}
+
+func parseRights(oob []byte) ([]int, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for _, m := range msgs {
+		if m.Header.Level != syscall.SOL_SOCKET || m.Header.Type != syscall.SCM_RIGHTS {
+			continue
+		}
+		rights, err := syscall.ParseUnixRights(&m)
+		if err != nil {
+			return nil, err
+		}
+		fds = append(fds, rights...)
+	}
+	return fds, nil
+}
+
+// SendConn sends state on conn, along with files, which hold the file
+// descriptors named by state.Fds in the same order.
+func SendConn(conn *net.UnixConn, state *specs.ContainerProcessState, files []*os.File) error {
+	if len(files) != len(state.Fds) {
+		return fmt.Errorf("%d files given for %d names", len(files), len(state.Fds))
+	}
+	if len(files) > maxFds {
+		return fmt.Errorf("more than %d file descriptors given", maxFds)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	fds := make([]int, len(files))
+	for i, f := range files {
+		fds[i] = int(f.Fd())
+	}
+	n, _, err := conn.WriteMsgUnix(data, syscall.UnixRights(fds...), nil)
+	if err != nil {
+		return err
+	}
+	if n < len(data) {
+		_, err = conn.Write(data[n:])
+	}
+	return err
+}
+
+// Send connects to the listener at path and sends state, along with files,
+// which hold the file descriptors named by state.Fds in the same order, as
+// a runtime does after loading a seccomp filter.
+func Send(path string, state *specs.ContainerProcessState, files []*os.File) error {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return SendConn(conn, state, files)
+}
+
+// Listener accepts container processes on a unix socket.
+type Listener struct {
+	l *net.UnixListener
+}
+
+// Listen listens on the unix socket path, which is typically the
+// listenerPath of a seccomp configuration.
+func Listen(path string) (*Listener, error) {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{l: l}, nil
+}
+
+// Addr returns the address of l.
+func (l *Listener) Addr() net.Addr {
+	return l.l.Addr()
+}
+
+// Close stops listening, and removes the socket.
+func (l *Listener) Close() error {
+	return l.l.Close()
+}
+
+// Accept waits for a runtime to connect, and receives the state of a
+// container process.
+func (l *Listener) Accept() (*Process, error) {
+	conn, err := l.l.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return Receive(conn)
+}
+
+// Serve accepts container processes and answers the notifications of their
+// seccomp filters with h, until l is closed. Connections whose state cannot
+// be received are dropped.
+func (l *Listener) Serve(h Handler) error {
+	for {
+		conn, err := l.l.AcceptUnix()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			p, err := Receive(conn)
+			conn.Close()
+			if err != nil {
+				return
+			}
+			defer p.Close()
+			_ = p.Notify(h)
+		}()
+	}
+}
@@ -0,0 +1,213 @@
+//go:build linux
+
+package listener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// socketpair returns both ends of a connected unix stream socket.
+func socketpair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = c.(*net.UnixConn)
+		t.Cleanup(func() { c.Close() })
+	}
+	return conns[0], conns[1]
+}
+
+// pipe returns a pipe whose ends are closed at the end of the test.
+func pipe(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	return r, w
+}
+
+func TestSendReceive(t *testing.T) {
+	r1, w1 := pipe(t)
+	r2, w2 := pipe(t)
+	a, b := socketpair(t)
+	state := &specs.ContainerProcessState{
+		Version:  specs.Version,
+		Fds:      []string{"first", specs.SeccompFdName},
+		Pid:      42,
+		Metadata: strings.Repeat("x", 100000),
+		State:    specs.State{ID: "c", Status: specs.StateCreating},
+	}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- SendConn(a, state, []*os.File{w1, w2})
+		a.Close()
+	}()
+	p, err := Receive(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	if p.State.Pid != 42 || p.State.Metadata != state.Metadata || p.State.State.ID != "c" {
+		t.Errorf("state %+v", p.State)
+	}
+	if len(p.Files) != 2 {
+		t.Fatalf("received files %v, want first and %s", p.Files, specs.SeccompFdName)
+	}
+	seccompFile, err := p.SeccompFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each name must map to the file descriptor sent in the same position.
+	for _, tc := range []struct {
+		f    *os.File
+		r    *os.File
+		data string
+	}{
+		{p.Files["first"], r1, "one"},
+		{seccompFile, r2, "two"},
+	} {
+		if _, err := tc.f.Write([]byte(tc.data)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(tc.data))
+		if _, err := tc.r.Read(buf); err != nil || string(buf) != tc.data {
+			t.Errorf("read %q, %v from the pipe of %s, want %q", buf, err, tc.f.Name(), tc.data)
+		}
+	}
+}
+
+func TestReceiveErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		data  string
+		files int
+	}{
+		{name: "invalid state", data: `{"pid": "x"}`, files: 1},
+		{name: "more files than names", data: `{"fds": ["a"]}`, files: 2},
+		{name: "more names than files", data: `{"fds": ["a", "b"]}`, files: 1},
+		{name: "duplicate names", data: `{"fds": ["a", "a"]}`, files: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, w := pipe(t)
+			fds := make([]int, tc.files)
+			for i := range fds {
+				fds[i] = int(w.Fd())
+			}
+			a, b := socketpair(t)
+			if _, _, err := a.WriteMsgUnix([]byte(tc.data), syscall.UnixRights(fds...), nil); err != nil {
+				t.Fatal(err)
+			}
+			a.Close()
+			if p, err := Receive(b); err == nil {
+				p.Close()
+				t.Errorf("Receive succeeded with %+v", p.State)
+			}
+		})
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	_, w := pipe(t)
+	a, _ := socketpair(t)
+	if err := SendConn(a, &specs.ContainerProcessState{Fds: []string{"a", "b"}}, []*os.File{w}); err == nil {
+		t.Error("SendConn accepted fewer files than names")
+	}
+	names := make([]string, maxFds+1)
+	files := make([]*os.File, maxFds+1)
+	for i := range names {
+		names[i], files[i] = string(rune('a'+i)), w
+	}
+	if err := SendConn(a, &specs.ContainerProcessState{Fds: names}, files); err == nil {
+		t.Errorf("SendConn accepted %d files", len(files))
+	}
+	if err := Send(filepath.Join(t.TempDir(), "missing.sock"), &specs.ContainerProcessState{}, nil); err == nil {
+		t.Error("Send succeeded without a listener")
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	var metadata atomic.Value
+	served := make(chan error, 1)
+	go func() {
+		served <- l.Serve(func(r *Request) Response {
+			calls.Add(1)
+			metadata.Store(r.Process.State.Metadata)
+			switch r.Data.Args[2] {
+			case 0o700:
+				return Response{Errno: syscall.EROFS}
+			case 0o701:
+				return Response{Continue: true}
+			}
+			return Response{}
+		})
+	}()
+
+	s := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActAllow,
+		Syscalls:      []specs.LinuxSyscall{{Names: []string{"mkdirat"}, Action: specs.ActNotify}},
+	}
+	dir := t.TempDir()
+	err = Loopback(path, s, specs.ContainerProcessState{Metadata: "agent test"}, func() error {
+		if err := syscall.Mkdirat(-100, filepath.Join(dir, "denied"), 0o700); !errors.Is(err, syscall.EROFS) {
+			t.Errorf("denied mkdirat returned %v, want EROFS", err)
+		}
+		if err := syscall.Mkdirat(-100, filepath.Join(dir, "faked"), 0o755); err != nil {
+			t.Errorf("faked mkdirat returned %v", err)
+		}
+		return syscall.Mkdirat(-100, filepath.Join(dir, "continued"), 0o701)
+	})
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "loading seccomp filter") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "faked")); err == nil {
+		t.Error("the faked directory was created")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "continued")); err != nil {
+		t.Errorf("the continued directory was not created: %v", err)
+	}
+	if n, m := calls.Load(), metadata.Load(); n != 3 || m != "agent test" {
+		t.Errorf("%d notifications with metadata %v, want 3 with %q", n, m, "agent test")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
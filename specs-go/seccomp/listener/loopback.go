@@ -0,0 +1,87 @@
+//go:build linux
+
+package listener
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/seccomp"
+)
+
+const (
+	prSetNoNewPrivs = 38
+	// seccompSetModeFilter and seccompFilterFlagNewListener are
+	// SECCOMP_SET_MODE_FILTER and SECCOMP_FILTER_FLAG_NEW_LISTENER.
+	seccompSetModeFilter         = 1
+	seccompFilterFlagNewListener = 8
+)
+
+// Loopback plays the part of a runtime within the current process, to test
+// seccomp agents: it loads the filter of s on a new thread, sends state to
+// the listener at path along with the seccomp notify file descriptor, then
+// runs fn on the filtered thread, whose system calls are notified to the
+// agent as those of a container process would be. The filter is released
+// when Loopback returns.
+//
+// The filter must allow the system calls made by the Go runtime on the
+// thread. The Fds of state are set by Loopback, as is its ociVersion and
+// pid when they are empty. Loopback requires Linux 5.0 or newer, and no
+// privileges.
+func Loopback(path string, s *specs.LinuxSeccomp, state specs.ContainerProcessState, fn func() error) error {
+	arch, err := seccomp.NativeArch()
+	if err != nil {
+		return err
+	}
+	prog, err := seccomp.Compile(s, arch)
+	if err != nil {
+		return err
+	}
+	nr, err := seccomp.SyscallNumber(arch, "seccomp")
+	if err != nil {
+		return err
+	}
+	if state.Version == "" {
+		state.Version = specs.Version
+	}
+	if state.Pid == 0 {
+		state.Pid = os.Getpid()
+	}
+	state.Fds = []string{specs.SeccompFdName}
+
+	done := make(chan error, 1)
+	go func() {
+		// The thread is never unlocked, so that it exits along with the
+		// goroutine and its filter.
+		runtime.LockOSThread()
+		done <- loopback(path, prog, uintptr(nr), &state, fn)
+	}()
+	return <-done
+}
+
+func loopback(path string, prog seccomp.Program, nr uintptr, state *specs.ContainerProcessState, fn func() error) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("setting no_new_privs: %w", errno)
+	}
+	filter := prog.Bytes(binary.NativeEndian)
+	fprog := struct {
+		len    uint16
+		filter *byte
+	}{len: uint16(len(prog)), filter: &filter[0]}
+	fd, _, errno := syscall.RawSyscall(nr, seccompSetModeFilter, seccompFilterFlagNewListener, uintptr(unsafe.Pointer(&fprog)))
+	if errno != 0 {
+		return fmt.Errorf("loading seccomp filter: %w", errno)
+	}
+	f := os.NewFile(fd, specs.SeccompFdName)
+	err := Send(path, state, []*os.File{f})
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return fn()
+}
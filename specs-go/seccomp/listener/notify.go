@@ -0,0 +1,232 @@
+//go:build linux
+
+package listener
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/opencontainers/runtime-spec/specs-go/seccomp"
+)
+
+// Sizes of struct seccomp_notif and struct seccomp_notif_resp.
+const (
+	notifSize     = 80
+	notifRespSize = 24
+)
+
+// flagContinue is SECCOMP_USER_NOTIF_FLAG_CONTINUE.
+const flagContinue = 1
+
+// ioctl requests of the seccomp notify file descriptor.
+var (
+	ioctlNotifRecv    = ioctlNumber(iocRead|iocWrite, 0, notifSize)
+	ioctlNotifSend    = ioctlNumber(iocRead|iocWrite, 1, notifRespSize)
+	ioctlNotifIDValid = ioctlNumber(iocWrite, 2, 8)
+)
+
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+// ioctlNumber encodes an ioctl request of the seccomp type '!', as done by
+// the _IOC macro of the running architecture.
+func ioctlNumber(dir, nr, size uintptr) uintptr {
+	dirShift := uintptr(30)
+	if strings.HasPrefix(runtime.GOARCH, "mips") || strings.HasPrefix(runtime.GOARCH, "ppc") {
+		// Write and read are 4 and 2, and sizes have 13 bits.
+		dirShift = 29
+		if dir&iocWrite != 0 {
+			dir = dir&^iocWrite | 4
+		}
+	}
+	return dir<<dirShift | size<<16 | '!'<<8 | nr
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Request is a system call notified by a seccomp filter.
+type Request struct {
+	// ID identifies the request.
+	ID uint64
+	// Pid is the thread making the system call, in the pid namespace of
+	// the agent.
+	Pid uint32
+	// Data is the system call.
+	Data seccomp.Data
+	// Process is the container process the filter was received with.
+	Process *Process
+
+	f *os.File
+}
+
+// Valid reports whether the system call of r is still waiting for a
+// response. It must be checked after reading the memory of the calling
+// thread, which may have been replaced by another process since the
+// request was received.
+func (r *Request) Valid() bool {
+	id := r.ID
+	valid := false
+	_ = withFd(r.f, func(fd uintptr) error {
+		valid = ioctl(fd, ioctlNotifIDValid, unsafe.Pointer(&id)) == nil
+		return nil
+	})
+	return valid
+}
+
+// Response is the answer to a Request.
+type Response struct {
+	// Val is the value returned by the system call, when Errno is 0.
+	Val int64
+	// Errno is the error returned by the system call.
+	Errno syscall.Errno
+	// Continue lets the kernel run the system call. It must only be used
+	// for system calls which are safe whatever their arguments, as those
+	// can be changed by the calling process after they are checked.
+	Continue bool
+}
+
+// Handler answers requests. Handlers run concurrently.
+type Handler func(*Request) Response
+
+// Notify answers the notifications of the seccomp filter of p with h, until
+// the filter is no longer in use, or the seccomp file descriptor of p is
+// closed. The file descriptor must be non-blocking when it is wrapped in an
+// os.File, as done by Receive, so that it can be polled. Responses to
+// requests whose system call was interrupted are ignored.
+func (p *Process) Notify(h Handler) error {
+	f, err := p.SeccompFile()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		req, err := recv(f)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		req.Process = p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The request is void once the system call is interrupted.
+			_ = send(f, req.ID, h(req))
+		}()
+	}
+}
+
+func withFd(f *os.File, fn func(fd uintptr) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) { fnErr = fn(fd) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// recv waits for a notification. The seccomp notify file descriptor is
+// polled before receiving, since SECCOMP_IOCTL_NOTIF_RECV blocks whatever
+// its flags.
+func recv(f *os.File) (*Request, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var buf [notifSize]byte
+	var recvErr error
+	err = rc.Read(func(fd uintptr) bool {
+		for {
+			pfd := struct {
+				fd      int32
+				events  int16
+				revents int16
+			}{fd: int32(fd), events: pollIn}
+			var ts syscall.Timespec
+			n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(unsafe.Pointer(&ts)), 0, 0, 0)
+			switch {
+			case errno == syscall.EINTR:
+				continue
+			case errno != 0:
+				recvErr = errno
+				return true
+			case n == 0:
+				return false
+			case pfd.revents&pollIn == 0:
+				recvErr = io.EOF
+				return true
+			}
+			buf = [notifSize]byte{}
+			err := ioctl(fd, ioctlNotifRecv, unsafe.Pointer(&buf[0]))
+			if err == syscall.EINTR || err == syscall.ENOENT {
+				// The system call was interrupted before it was received.
+				continue
+			}
+			recvErr = err
+			return true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if recvErr != nil {
+		return nil, recvErr
+	}
+	order := binary.NativeEndian
+	req := &Request{
+		ID:  order.Uint64(buf[0:]),
+		Pid: order.Uint32(buf[8:]),
+		Data: seccomp.Data{
+			Nr:                 order.Uint32(buf[16:]),
+			Arch:               order.Uint32(buf[20:]),
+			InstructionPointer: order.Uint64(buf[24:]),
+		},
+		f: f,
+	}
+	for i := range req.Data.Args {
+		req.Data.Args[i] = order.Uint64(buf[32+8*i:])
+	}
+	return req, nil
+}
+
+// pollIn is POLLIN.
+const pollIn = 0x1
+
+func send(f *os.File, id uint64, resp Response) error {
+	var buf [notifRespSize]byte
+	order := binary.NativeEndian
+	order.PutUint64(buf[0:], id)
+	if resp.Continue {
+		order.PutUint32(buf[20:], flagContinue)
+	} else if resp.Errno != 0 {
+		order.PutUint32(buf[16:], uint32(-int32(resp.Errno)))
+	} else {
+		order.PutUint64(buf[8:], uint64(resp.Val))
+	}
+	return withFd(f, func(fd uintptr) error {
+		if err := ioctl(fd, ioctlNotifSend, unsafe.Pointer(&buf[0])); err != nil {
+			return fmt.Errorf("sending response to request %d: %w", id, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,188 @@
+//go:build ignore
+// +build ignore
+
+// Mksyscalls generates zsyscalls.go from the system call tables of a Linux
+// source tree:
+//
+//	go run mksyscalls.go -linux /path/to/linux
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// table describes how the system call table of an architecture is derived
+// from a syscall.tbl file of the kernel.
+type table struct {
+	// arch is the name of the specs.Arch constant.
+	arch string
+	// file is the path of the table, relative to the source tree.
+	file string
+	// abis are the ABIs of the entries included in the table.
+	abis []string
+	// base is added to the numbers of the table.
+	base uint32
+	// private is the path of a header defining the private system calls of
+	// the architecture as __ARM_NR_name (__ARM_NR_BASE+n).
+	private string
+}
+
+var tables = []table{
+	{arch: "ArchX86", file: "arch/x86/entry/syscalls/syscall_32.tbl", abis: []string{"i386"}},
+	{arch: "ArchX86_64", file: "arch/x86/entry/syscalls/syscall_64.tbl", abis: []string{"common", "64"}},
+	{arch: "ArchX32", file: "arch/x86/entry/syscalls/syscall_64.tbl", abis: []string{"common", "x32"}, base: 0x40000000},
+	{
+		arch:    "ArchARM",
+		file:    "arch/arm/tools/syscall.tbl",
+		abis:    []string{"common", "eabi"},
+		private: "arch/arm/include/uapi/asm/unistd.h",
+	},
+	{arch: "ArchAARCH64", file: "scripts/syscall.tbl", abis: []string{"common", "64", "renameat", "rlimit", "memfd_secret"}},
+	{arch: "ArchMIPS", file: "arch/mips/kernel/syscalls/syscall_o32.tbl", abis: []string{"o32"}, base: 4000},
+	{arch: "ArchMIPS64", file: "arch/mips/kernel/syscalls/syscall_n64.tbl", abis: []string{"n64"}, base: 5000},
+	{arch: "ArchMIPS64N32", file: "arch/mips/kernel/syscalls/syscall_n32.tbl", abis: []string{"n32"}, base: 6000},
+	{arch: "ArchPPC", file: "arch/powerpc/kernel/syscalls/syscall.tbl", abis: []string{"common", "32", "nospu"}},
+	{arch: "ArchPPC64", file: "arch/powerpc/kernel/syscalls/syscall.tbl", abis: []string{"common", "64", "nospu"}},
+	{arch: "ArchS390", file: "arch/s390/kernel/syscalls/syscall.tbl", abis: []string{"common", "32"}},
+	{arch: "ArchS390X", file: "arch/s390/kernel/syscalls/syscall.tbl", abis: []string{"common", "64"}},
+	{arch: "ArchPARISC", file: "arch/parisc/kernel/syscalls/syscall.tbl", abis: []string{"common", "32"}},
+	{arch: "ArchPARISC64", file: "arch/parisc/kernel/syscalls/syscall.tbl", abis: []string{"common", "64"}},
+	{arch: "ArchRISCV64", file: "scripts/syscall.tbl", abis: []string{"common", "64", "riscv", "rlimit", "memfd_secret"}},
+	{arch: "ArchLOONGARCH64", file: "scripts/syscall.tbl", abis: []string{"common", "64", "memfd_secret"}},
+	{arch: "ArchM68K", file: "arch/m68k/kernel/syscalls/syscall.tbl", abis: []string{"common"}},
+}
+
+type entry struct {
+	name string
+	nr   uint32
+}
+
+// readTable returns the entries of the ABIs abis of the syscall.tbl file
+// path, whose lines are "number abi name [entry point...]".
+func readTable(path string, abis []string, base uint32) ([]entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []entry
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: invalid entry", path, line)
+		}
+		nr, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		for _, abi := range abis {
+			if fields[1] == abi {
+				entries = append(entries, entry{name: fields[2], nr: base + uint32(nr)})
+				break
+			}
+		}
+	}
+	return entries, s.Err()
+}
+
+var privateRe = regexp.MustCompile(`^#define\s+__ARM_NR_(\w+)\s+\(__ARM_NR_BASE\+(\d+)\)`)
+
+// armNRBase is __ARM_NR_BASE for EABI.
+const armNRBase = 0x0f0000
+
+// readPrivate returns the private system calls defined by the header path.
+func readPrivate(path string) ([]entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	for _, line := range strings.Split(string(data), "\n") {
+		m := privateRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.ParseUint(m[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		entries = append(entries, entry{name: m[1], nr: armNRBase + uint32(n)})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no private system calls", path)
+	}
+	return entries, nil
+}
+
+func generate(linux string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`// Code generated by mksyscalls.go from the Linux system call tables. DO NOT EDIT.
+
+package seccomp
+
+import "github.com/opencontainers/runtime-spec/specs-go"
+
+// syscallTables maps the name of every system call to its number, for each
+// architecture. Architectures which only differ by endianness share a
+// table, see arches.
+var syscallTables = map[specs.Arch]map[string]uint32{
+`)
+	for _, t := range tables {
+		entries, err := readTable(filepath.Join(linux, t.file), t.abis, t.base)
+		if err != nil {
+			return nil, err
+		}
+		if t.private != "" {
+			private, err := readPrivate(filepath.Join(linux, t.private))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, private...)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].nr < entries[j].nr })
+		fmt.Fprintf(&buf, "specs.%s: {\n", t.arch)
+		seen := make(map[string]bool)
+		for _, e := range entries {
+			if seen[e.name] {
+				continue
+			}
+			seen[e.name] = true
+			fmt.Fprintf(&buf, "%q: %d,\n", e.name, e.nr)
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	linux := flag.String("linux", "", "path of the Linux source tree")
+	output := flag.String("o", "zsyscalls.go", "output file")
+	flag.Parse()
+	if *linux == "" {
+		fmt.Fprintln(os.Stderr, "mksyscalls: -linux is required")
+		os.Exit(2)
+	}
+	src, err := generate(*linux)
+	if err == nil {
+		err = os.WriteFile(*output, src, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mksyscalls:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,288 @@
+package seccomp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Finding reports names of an entry of Syscalls removed by Normalize.
+type Finding struct {
+	// Entry is the index of the entry in the original Syscalls.
+	Entry int `json:"entry"`
+	// Names are the system calls of the entry concerned.
+	Names []string `json:"names"`
+	// Unreachable is set when the rules can never match, as opposed to
+	// rules which match but have the default action.
+	Unreachable bool `json:"unreachable"`
+	// Msg describes the finding.
+	Msg string `json:"msg"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("syscalls[%d] (%s): %s", f.Entry, strings.Join(f.Names, ", "), f.Msg)
+}
+
+// entryRules is an entry of Syscalls in normal form.
+type entryRules struct {
+	index  int
+	action specs.LinuxSeccompAction
+	ret    uint32
+	args   []specs.LinuxSeccompArg
+	// key identifies the action, errno and arguments of the entry.
+	key string
+}
+
+// Normalize returns a profile equivalent to s, for every architecture, with
+// entries which are easier to review and compile to smaller programs:
+//
+//   - names shadowed by a previous rule of the same system call, which
+//     either is unconditional or has the same arguments, are removed;
+//   - names of conditional rules directly followed by an unconditional
+//     rule with the same result are removed, and so are names whose last
+//     rules have the default action;
+//   - entries with the same action, errno and arguments are merged;
+//   - entries are sorted by decreasing precedence of their actions (see
+//     Precedes), then by errno and arguments, and names and arguments are
+//     sorted within entries.
+//
+// Entries of the same action with different errnos are kept in their
+// original order wherever they apply to the same system call, since the
+// first matching one wins. The removed names are reported as findings. s
+// is not modified.
+func Normalize(s *specs.LinuxSeccomp) (*specs.LinuxSeccomp, []Finding, error) {
+	if s == nil {
+		return nil, nil, fmt.Errorf("seccomp configuration is nil")
+	}
+	def, err := ReturnValue(s.DefaultAction, s.DefaultErrnoRet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("defaultAction: %w", err)
+	}
+
+	byName := make(map[string][]*entryRules)
+	var names []string
+	for i, sc := range s.Syscalls {
+		if _, err := rules(sc, i); err != nil {
+			return nil, nil, fmt.Errorf("syscalls[%d]: %w", i, err)
+		}
+		e := newEntryRules(sc, i)
+		for _, name := range sc.Names {
+			list := byName[name]
+			if len(list) > 0 && list[len(list)-1] == e {
+				continue
+			}
+			if list == nil {
+				names = append(names, name)
+			}
+			byName[name] = append(list, e)
+		}
+	}
+	sort.Strings(names)
+
+	findings := &findingSet{}
+	kept := make(map[string][]*entryRules, len(names))
+	for _, name := range names {
+		list := byName[name]
+		sort.SliceStable(list, func(i, j int) bool { return Precedes(list[i].ret, list[j].ret) })
+		var k []*entryRules
+	rules:
+		for _, e := range list {
+			for _, p := range k {
+				switch {
+				case len(p.args) == 0:
+					findings.add(e.index, name, true, fmt.Sprintf("shadowed by the unconditional rule of syscalls[%d]", p.index))
+					continue rules
+				case p.key[strings.IndexByte(p.key, '|'):] == e.key[strings.IndexByte(e.key, '|'):]:
+					findings.add(e.index, name, true, fmt.Sprintf("shadowed by syscalls[%d], which has the same arguments", p.index))
+					continue rules
+				}
+			}
+			k = append(k, e)
+		}
+		// Conditional rules directly followed by an unconditional rule
+		// with the same result make no difference.
+		if n := len(k); n > 0 && len(k[n-1].args) == 0 {
+			last := k[n-1]
+			i := n - 1
+			for i > 0 && k[i-1].ret == last.ret {
+				i--
+			}
+			for _, e := range k[i : n-1] {
+				findings.add(e.index, name, false, fmt.Sprintf("covered by the unconditional rule of syscalls[%d]", last.index))
+			}
+			k = append(k[:i], last)
+		}
+		for len(k) > 0 && k[len(k)-1].ret == def {
+			findings.add(k[len(k)-1].index, name, false, "has the default action")
+			k = k[:len(k)-1]
+		}
+		if len(k) > 0 {
+			kept[name] = k
+		}
+	}
+
+	out := *s
+	out.Architectures = append([]specs.Arch(nil), s.Architectures...)
+	out.Flags = append([]specs.LinuxSeccompFlag(nil), s.Flags...)
+	out.Syscalls = nil
+	for _, g := range orderGroups(names, kept) {
+		sc := specs.LinuxSyscall{
+			Names:  g.names,
+			Action: g.entry.action,
+			Args:   g.entry.args,
+		}
+		if _, errno := Action(g.entry.ret); errno != DefaultErrno && (g.entry.ret&RetActionFull == RetErrno || g.entry.ret&RetActionFull == RetTrace) {
+			sc.ErrnoRet = &errno
+		}
+		out.Syscalls = append(out.Syscalls, sc)
+	}
+	return &out, findings.list, nil
+}
+
+func newEntryRules(sc specs.LinuxSyscall, index int) *entryRules {
+	ret, _ := ReturnValue(sc.Action, sc.ErrnoRet)
+	e := &entryRules{index: index, action: sc.Action, ret: ret}
+	if len(sc.Args) > 0 {
+		e.args = append([]specs.LinuxSeccompArg(nil), sc.Args...)
+		sort.Slice(e.args, func(i, j int) bool {
+			a, b := e.args[i], e.args[j]
+			switch {
+			case a.Index != b.Index:
+				return a.Index < b.Index
+			case a.Op != b.Op:
+				return a.Op < b.Op
+			case a.Value != b.Value:
+				return a.Value < b.Value
+			}
+			return a.ValueTwo < b.ValueTwo
+		})
+	}
+	var key strings.Builder
+	fmt.Fprintf(&key, "%s %08x|", e.action, ret)
+	for _, a := range e.args {
+		fmt.Fprintf(&key, "%d %s %d %d;", a.Index, a.Op, a.Value, a.ValueTwo)
+	}
+	e.key = key.String()
+	return e
+}
+
+// less orders entries by decreasing precedence, errno and arguments, with
+// unconditional entries last.
+func (e *entryRules) less(f *entryRules) bool {
+	switch {
+	case Precedes(e.ret, f.ret):
+		return true
+	case Precedes(f.ret, e.ret):
+		return false
+	case e.ret != f.ret:
+		return e.ret < f.ret
+	case (len(e.args) == 0) != (len(f.args) == 0):
+		return len(f.args) == 0
+	}
+	return e.key < f.key
+}
+
+// findingSet gathers the names of findings about the same entry.
+type findingSet struct {
+	list []Finding
+}
+
+func (s *findingSet) add(entry int, name string, unreachable bool, msg string) {
+	for i := range s.list {
+		f := &s.list[i]
+		if f.Entry == entry && f.Msg == msg {
+			f.Names = append(f.Names, name)
+			return
+		}
+	}
+	s.list = append(s.list, Finding{Entry: entry, Names: []string{name}, Unreachable: unreachable, Msg: msg})
+	sort.SliceStable(s.list, func(i, j int) bool { return s.list[i].Entry < s.list[j].Entry })
+}
+
+// ruleGroup is an entry of the normalized profile.
+type ruleGroup struct {
+	id    string
+	entry *entryRules
+	names []string
+}
+
+func (g *ruleGroup) less(h *ruleGroup) bool {
+	if g.entry.key != h.entry.key {
+		return g.entry.less(h.entry)
+	}
+	return strings.Join(g.names, ",") < strings.Join(h.names, ",")
+}
+
+// orderGroups merges the rules kept for each name into entries, and sorts
+// them. Entries of the same action with different return values must keep
+// the order they have for each name, so the sort is topological. When the
+// orders of two names conflict, the entries involved are split by name.
+func orderGroups(names []string, kept map[string][]*entryRules) []*ruleGroup {
+	split := make(map[string]bool)
+	for {
+		groups := make(map[string]*ruleGroup)
+		id := func(e *entryRules, name string) string {
+			if split[e.key] {
+				return e.key + "\x00" + name
+			}
+			return e.key
+		}
+		for _, name := range names {
+			for _, e := range kept[name] {
+				gid := id(e, name)
+				g := groups[gid]
+				if g == nil {
+					g = &ruleGroup{id: gid, entry: e}
+					groups[gid] = g
+				}
+				g.names = append(g.names, name)
+			}
+		}
+		after := make(map[string]map[string]bool)
+		preds := make(map[string]int)
+		for _, name := range names {
+			k := kept[name]
+			for i, e := range k {
+				for _, f := range k[i+1:] {
+					if e.ret&RetActionFull != f.ret&RetActionFull || e.ret == f.ret {
+						continue
+					}
+					from, to := id(e, name), id(f, name)
+					if after[from] == nil {
+						after[from] = make(map[string]bool)
+					}
+					if !after[from][to] {
+						after[from][to] = true
+						preds[to]++
+					}
+				}
+			}
+		}
+
+		var order []*ruleGroup
+		for len(groups) > 0 {
+			var next *ruleGroup
+			for _, g := range groups {
+				if preds[g.id] == 0 && (next == nil || g.less(next)) {
+					next = g
+				}
+			}
+			if next == nil {
+				break
+			}
+			order = append(order, next)
+			delete(groups, next.id)
+			for to := range after[next.id] {
+				preds[to]--
+			}
+		}
+		if len(groups) == 0 {
+			return order
+		}
+		for _, g := range groups {
+			split[g.entry.key] = true
+		}
+	}
+}
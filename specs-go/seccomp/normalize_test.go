@@ -0,0 +1,192 @@
+package seccomp
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestNormalize(t *testing.T) {
+	arg := func(index uint, value uint64) []specs.LinuxSeccompArg {
+		return []specs.LinuxSeccompArg{{Index: index, Value: value, Op: specs.OpEqualTo}}
+	}
+	for _, tc := range []struct {
+		name     string
+		in       []specs.LinuxSyscall
+		want     []specs.LinuxSyscall
+		findings []Finding
+	}{
+		{
+			name: "merge and sort",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"write", "read"}, Action: specs.ActAllow},
+				{Names: []string{"kill"}, Action: specs.ActLog},
+				{Names: []string{"open"}, Action: specs.ActAllow},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActLog},
+				{Names: []string{"open", "read", "write"}, Action: specs.ActAllow},
+			},
+		},
+		{
+			name: "shadowed by an unconditional rule",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"read"}, Action: specs.ActAllow},
+				{Names: []string{"read", "write"}, Action: specs.ActAllow, Args: arg(0, 1)},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"write"}, Action: specs.ActAllow, Args: arg(0, 1)},
+				{Names: []string{"read"}, Action: specs.ActAllow},
+			},
+			findings: []Finding{
+				{Entry: 1, Names: []string{"read"}, Unreachable: true, Msg: "shadowed by the unconditional rule of syscalls[0]"},
+			},
+		},
+		{
+			name: "shadowed by the same arguments",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(7), Args: arg(1, 9)},
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(5), Args: arg(1, 9)},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(7), Args: arg(1, 9)},
+			},
+			findings: []Finding{
+				{Entry: 1, Names: []string{"kill"}, Unreachable: true, Msg: "shadowed by syscalls[0], which has the same arguments"},
+			},
+		},
+		{
+			name: "covered by an unconditional rule",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActLog, Args: arg(1, 9)},
+				{Names: []string{"kill"}, Action: specs.ActLog},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActLog},
+			},
+			findings: []Finding{
+				{Entry: 0, Names: []string{"kill"}, Msg: "covered by the unconditional rule of syscalls[1]"},
+			},
+		},
+		{
+			name: "default action",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"read"}, Action: specs.ActTrap, Args: arg(0, 0)},
+				{Names: []string{"read", "open"}, Action: specs.ActErrno},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"read"}, Action: specs.ActTrap, Args: arg(0, 0)},
+			},
+			findings: []Finding{
+				{Entry: 1, Names: []string{"open", "read"}, Msg: "has the default action"},
+			},
+		},
+		{
+			name: "errnos keep their order",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: arg(0, 1)},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: arg(0, 1)},
+			},
+		},
+		{
+			name: "conflicting errno orders are split",
+			in: []specs.LinuxSyscall{
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+				{Names: []string{"kill", "tkill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: arg(0, 1)},
+				{Names: []string{"tkill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+			},
+			want: []specs.LinuxSyscall{
+				{Names: []string{"tkill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: arg(0, 1)},
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+				{Names: []string{"kill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(3), Args: arg(0, 1)},
+				{Names: []string{"tkill"}, Action: specs.ActErrno, ErrnoRet: uintPtr(9), Args: arg(1, 9)},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &specs.LinuxSeccomp{DefaultAction: specs.ActErrno, Syscalls: tc.in}
+			out, findings, err := Normalize(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out.Syscalls, tc.want) {
+				t.Errorf("syscalls\n%+v\nwant\n%+v", out.Syscalls, tc.want)
+			}
+			if !reflect.DeepEqual(findings, tc.findings) {
+				t.Errorf("findings\n%+v\nwant\n%+v", findings, tc.findings)
+			}
+			if !reflect.DeepEqual(s.Syscalls, tc.in) {
+				t.Error("Normalize modified its input")
+			}
+		})
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	for _, s := range []*specs.LinuxSeccomp{
+		nil,
+		{DefaultAction: "SCMP_ACT_BOGUS"},
+		{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{{Names: []string{"read"}, Action: "SCMP_ACT_BOGUS"}}},
+	} {
+		if _, _, err := Normalize(s); err == nil {
+			t.Errorf("Normalize(%+v) succeeded", s)
+		}
+	}
+}
+
+// TestNormalizeRandom checks that normalized profiles evaluate system
+// calls as the original ones, and are stable.
+func TestNormalizeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	arch := specs.ArchX86_64
+	names := []string{"read", "write", "open", "close", "kill"}
+	actions := []specs.LinuxSeccompAction{specs.ActAllow, specs.ActErrno, specs.ActLog, specs.ActTrap}
+	ops := []specs.LinuxSeccompOperator{specs.OpEqualTo, specs.OpNotEqual, specs.OpGreaterThan, specs.OpMaskedEqual}
+	for i := 0; i < 300; i++ {
+		s := &specs.LinuxSeccomp{DefaultAction: actions[r.Intn(len(actions))]}
+		for j := 0; j < 1+r.Intn(8); j++ {
+			sc := specs.LinuxSyscall{Action: actions[r.Intn(len(actions))], ErrnoRet: uintPtr(uint(1 + r.Intn(3)))}
+			for k := 0; k < 1+r.Intn(3); k++ {
+				sc.Names = append(sc.Names, names[r.Intn(len(names))])
+			}
+			for k := 0; k < r.Intn(3); k++ {
+				sc.Args = append(sc.Args, specs.LinuxSeccompArg{Index: uint(r.Intn(2)), Value: uint64(r.Intn(3)), ValueTwo: uint64(r.Intn(3)), Op: ops[r.Intn(len(ops))]})
+			}
+			s.Syscalls = append(s.Syscalls, sc)
+		}
+		out, _, err := Normalize(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			for a0 := uint64(0); a0 < 3; a0++ {
+				for a1 := uint64(0); a1 < 3; a1++ {
+					want, err := Evaluate(s, arch, name, a0, a1)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := Evaluate(out, arch, name, a0, a1)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got.Ret != want.Ret {
+						t.Fatalf("%s(%d, %d): got %s, want %s\n%+v\n%+v", name, a0, a1, describeReturn(got.Ret), describeReturn(want.Ret), s.Syscalls, out.Syscalls)
+					}
+				}
+			}
+		}
+		again, findings, err := Normalize(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, out) || len(findings) != 0 {
+			t.Fatalf("normalizing twice gave %+v with findings %v, want %+v", again.Syscalls, findings, out.Syscalls)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package seccomp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Call is a system call observed in a trace.
+type Call struct {
+	Name string `json:"name"`
+	// Arch is the architecture of the call, if known.
+	Arch specs.Arch `json:"arch,omitempty"`
+	// Args are the arguments of the call. Unknown arguments are nil.
+	Args []*uint64 `json:"args,omitempty"`
+}
+
+// ParseCalls reads a JSON list of calls.
+func ParseCalls(r io.Reader) ([]Call, error) {
+	var calls []Call
+	if err := json.NewDecoder(r).Decode(&calls); err != nil {
+		return nil, err
+	}
+	for i, c := range calls {
+		if c.Name == "" {
+			return nil, fmt.Errorf("call %d has no name", i)
+		}
+	}
+	return calls, nil
+}
+
+var (
+	// straceCall matches a call printed by strace, with an optional pid
+	// and timestamp.
+	straceCall = regexp.MustCompile(`^(?:\[pid\s+\d+\]\s*|\d+\s+)?(?:[\d:.]+\s+)?([a-z_][a-z0-9_]*)\((.*)$`)
+	// straceInt matches decimal arguments, optionally decorated with the
+	// path of a file descriptor as printed by strace -y.
+	straceInt = regexp.MustCompile(`^(-?\d+)(?:<.*>)?$`)
+)
+
+// ParseStrace reads the output of strace for a process running on arch.
+// Decimal arguments and NULL are known; others, including hexadecimal
+// values which strace uses for addresses, are unknown. Calls which are
+// interrupted are reported once, from their unfinished line, with unknown
+// arguments.
+func ParseStrace(r io.Reader, arch specs.Arch) ([]Call, error) {
+	var calls []Call
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		m := straceCall.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+		if m == nil {
+			continue
+		}
+		c := Call{Name: m[1], Arch: arch}
+		if !strings.HasSuffix(m[2], "<unfinished ...>") {
+			for _, arg := range splitStraceArgs(m[2]) {
+				c.Args = append(c.Args, parseStraceArg(arg))
+			}
+		}
+		calls = append(calls, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// splitStraceArgs splits the arguments of a call, which are followed by the
+// closing parenthesis and the return value.
+func splitStraceArgs(s string) []string {
+	var args []string
+	depth, start := 0, 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			if arg := strings.TrimSpace(s[start:i]); arg != "" || len(args) > 0 {
+				args = append(args, arg)
+			}
+			return args
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return args
+}
+
+func parseStraceArg(arg string) *uint64 {
+	if arg == "NULL" {
+		v := uint64(0)
+		return &v
+	}
+	m := straceInt.FindStringSubmatch(arg)
+	if m == nil {
+		return nil
+	}
+	if strings.HasPrefix(m[1], "-") {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil
+		}
+		v := uint64(n)
+		return &v
+	}
+	v, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// ParseAuditLog reads the SECCOMP records (type 1326) of an audit log, as
+// written by auditd or printed by the kernel, and returns the calls logged
+// by SCMP_ACT_LOG. Audit records do not carry arguments. The fields of the
+// records must not be interpreted, as done by ausearch -i.
+func ParseAuditLog(r io.Reader) ([]Call, error) {
+	var calls []Call
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if !strings.Contains(line, "type=SECCOMP") && !strings.Contains(line, "type=1326") {
+			continue
+		}
+		fields := make(map[string]string)
+		for _, f := range strings.Fields(line) {
+			if k, v, ok := strings.Cut(f, "="); ok {
+				fields[k] = v
+			}
+		}
+		code, err := strconv.ParseUint(strings.TrimPrefix(fields["code"], "0x"), 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid code %q", n, fields["code"])
+		}
+		if uint32(code)&RetActionFull != RetLog {
+			continue
+		}
+		audit, err := strconv.ParseUint(fields["arch"], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid arch %q", n, fields["arch"])
+		}
+		nr, err := strconv.ParseUint(fields["syscall"], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid syscall %q", n, fields["syscall"])
+		}
+		arch, err := archOfAudit(uint32(audit), uint32(nr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		name, err := SyscallName(arch, uint32(nr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		calls = append(calls, Call{Name: name, Arch: arch})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// archOfAudit returns the architecture of the system call nr of the audit
+// architecture audit.
+func archOfAudit(audit, nr uint32) (specs.Arch, error) {
+	if audit == arches[specs.ArchX86_64].audit {
+		if nr&x32SyscallBit != 0 {
+			return specs.ArchX32, nil
+		}
+		return specs.ArchX86_64, nil
+	}
+	for arch, info := range arches {
+		if info.audit == audit {
+			return arch, nil
+		}
+	}
+	return "", fmt.Errorf("unknown audit architecture 0x%08x", audit)
+}
+
+// TraceOption customizes the profile produced by FromTrace.
+type TraceOption func(*traceOptions)
+
+type traceOptions struct {
+	errnoRet *uint
+	pinArgs  bool
+}
+
+// WithErrno sets the errno returned for the system calls which are not
+// allowed. It defaults to EPERM.
+func WithErrno(errno uint) TraceOption {
+	return func(o *traceOptions) {
+		o.errnoRet = &errno
+	}
+}
+
+// WithPinnedArgs restricts the system calls to the values of the arguments
+// which are known and constant across all their calls in the trace.
+func WithPinnedArgs() TraceOption {
+	return func(o *traceOptions) {
+		o.pinArgs = true
+	}
+}
+
+// FromTrace returns a profile which allows the calls of a trace, and only
+// those: the default action is SCMP_ACT_ERRNO, and the architectures are
+// those of the calls. With WithPinnedArgs, the system calls with constant
+// arguments are restricted to them with SCMP_CMP_EQ conditions.
+func FromTrace(calls []Call, opts ...TraceOption) (*specs.LinuxSeccomp, error) {
+	var o traceOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &specs.LinuxSeccomp{DefaultAction: specs.ActErrno, DefaultErrnoRet: o.errnoRet}
+
+	seenArch := make(map[specs.Arch]bool)
+	// pinned holds the constant arguments of each name, nil once unknown
+	// or varying.
+	pinned := make(map[string][]*uint64)
+	var names []string
+	for i, c := range calls {
+		if c.Name == "" {
+			return nil, fmt.Errorf("call %d has no name", i)
+		}
+		if c.Arch != "" && !seenArch[c.Arch] {
+			if _, ok := arches[c.Arch]; !ok {
+				return nil, fmt.Errorf("call %d: unknown architecture %q", i, c.Arch)
+			}
+			seenArch[c.Arch] = true
+			s.Architectures = append(s.Architectures, c.Arch)
+		}
+		args := c.Args
+		if len(args) > 6 {
+			args = args[:6]
+		}
+		p, ok := pinned[c.Name]
+		if !ok {
+			names = append(names, c.Name)
+			pinned[c.Name] = append([]*uint64(nil), args...)
+			continue
+		}
+		for j := range p {
+			if p[j] != nil && (j >= len(args) || args[j] == nil || *args[j] != *p[j]) {
+				p[j] = nil
+			}
+		}
+	}
+	sort.Slice(s.Architectures, func(i, j int) bool { return s.Architectures[i] < s.Architectures[j] })
+	sort.Strings(names)
+
+	allow := specs.LinuxSyscall{Action: specs.ActAllow}
+	var conditional []specs.LinuxSyscall
+	byArgs := make(map[string]int)
+	for _, name := range names {
+		var args []specs.LinuxSeccompArg
+		if o.pinArgs {
+			for j, v := range pinned[name] {
+				if v != nil {
+					args = append(args, specs.LinuxSeccompArg{Index: uint(j), Value: *v, Op: specs.OpEqualTo})
+				}
+			}
+		}
+		if len(args) == 0 {
+			allow.Names = append(allow.Names, name)
+			continue
+		}
+		key := fmt.Sprint(args)
+		if i, ok := byArgs[key]; ok {
+			conditional[i].Names = append(conditional[i].Names, name)
+			continue
+		}
+		byArgs[key] = len(conditional)
+		conditional = append(conditional, specs.LinuxSyscall{Names: []string{name}, Action: specs.ActAllow, Args: args})
+	}
+	if len(allow.Names) > 0 {
+		s.Syscalls = append(s.Syscalls, allow)
+	}
+	s.Syscalls = append(s.Syscalls, conditional...)
+	return s, nil
+}
@@ -0,0 +1,195 @@
+package seccomp
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func u64(v uint64) *uint64 { return &v }
+
+func TestParseStrace(t *testing.T) {
+	const trace = `execve("/bin/true", ["true"], 0x7ffd6a8b0e70 /* 20 vars */) = 0
+[pid  4242] openat(AT_FDCWD, "/etc/ld.so.cache", O_RDONLY|O_CLOEXEC) = 3
+4243  12:00:00.000001 read(3</etc/ld.so.cache>, "a,b)\"c", 832) = 832
+close(3) = 0
+getpid() = 4242
+wait4(-1,  <unfinished ...>
+<... wait4 resumed>NULL, 0, NULL) = 4243
++++ exited with 0 +++
+`
+	calls, err := ParseStrace(strings.NewReader(trace), specs.ArchX86_64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Call{
+		{Name: "execve", Arch: specs.ArchX86_64, Args: []*uint64{nil, nil, nil}},
+		{Name: "openat", Arch: specs.ArchX86_64, Args: []*uint64{nil, nil, nil}},
+		{Name: "read", Arch: specs.ArchX86_64, Args: []*uint64{u64(3), nil, u64(832)}},
+		{Name: "close", Arch: specs.ArchX86_64, Args: []*uint64{u64(3)}},
+		{Name: "getpid", Arch: specs.ArchX86_64},
+		{Name: "wait4", Arch: specs.ArchX86_64},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %s, want %s", formatCalls(calls), formatCalls(want))
+	}
+}
+
+func formatCalls(calls []Call) string {
+	var b strings.Builder
+	for _, c := range calls {
+		args := make([]string, len(c.Args))
+		for i, a := range c.Args {
+			args[i] = "?"
+			if a != nil {
+				args[i] = strconv.FormatUint(*a, 10)
+			}
+		}
+		fmt.Fprintf(&b, "\n%s(%s)", c.Name, strings.Join(args, ", "))
+	}
+	return b.String()
+}
+
+func TestParseStraceArgs(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []*uint64
+	}{
+		{in: `) = 0`},
+		{in: `1, -1, NULL) = 0`, want: []*uint64{u64(1), u64(^uint64(0)), u64(0)}},
+		{in: `0x7f00, 18446744073709551616) = 0`, want: []*uint64{nil, nil}},
+		{in: `[{fd=3, events=POLLIN}], 1, 5) = 1`, want: []*uint64{nil, u64(1), u64(5)}},
+		{in: `f(1, 2), 3) = 0`, want: []*uint64{nil, u64(3)}},
+	} {
+		var got []*uint64
+		for _, arg := range splitStraceArgs(tc.in) {
+			got = append(got, parseStraceArg(arg))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %s, want %s", tc.in, formatCalls([]Call{{Args: got}}), formatCalls([]Call{{Args: tc.want}}))
+		}
+	}
+}
+
+func TestParseAuditLog(t *testing.T) {
+	const log = `type=SYSCALL msg=audit(1700000000.000:1): arch=c000003e syscall=0 success=yes
+type=SECCOMP msg=audit(1700000000.000:2): auid=1000 uid=0 pid=42 comm="sh" exe="/bin/sh" sig=0 arch=c000003e syscall=39 compat=0 ip=0x7f code=0x7ffc0000
+type=SECCOMP msg=audit(1700000000.000:3): pid=42 arch=c000003e syscall=0 code=0x50001
+audit: type=1326 audit(1700000000.000:4): pid=42 arch=c000003e syscall=1073741824 code=0x7ffc0000
+type=SECCOMP msg=audit(1700000000.000:5): pid=42 arch=40000003 syscall=20 code=0x7ffc0000
+`
+	calls, err := ParseAuditLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Call{
+		{Name: "getpid", Arch: specs.ArchX86_64},
+		{Name: "read", Arch: specs.ArchX32},
+		{Name: "getpid", Arch: specs.ArchX86},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %+v, want %+v", calls, want)
+	}
+
+	for _, line := range []string{
+		`type=SECCOMP arch=c000003e syscall=39 code=bogus`,
+		`type=SECCOMP arch=bogus syscall=39 code=0x7ffc0000`,
+		`type=SECCOMP arch=c000003e syscall=-1 code=0x7ffc0000`,
+		`type=SECCOMP arch=00000001 syscall=39 code=0x7ffc0000`,
+		`type=SECCOMP arch=c000003e syscall=100000 code=0x7ffc0000`,
+	} {
+		if calls, err := ParseAuditLog(strings.NewReader(line)); err == nil {
+			t.Errorf("ParseAuditLog(%q) = %+v, want an error", line, calls)
+		}
+	}
+}
+
+func TestParseCalls(t *testing.T) {
+	calls, err := ParseCalls(strings.NewReader(`[{"name": "read", "arch": "SCMP_ARCH_X86_64", "args": [3, null]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Call{{Name: "read", Arch: specs.ArchX86_64, Args: []*uint64{u64(3), nil}}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %+v, want %+v", calls, want)
+	}
+	if _, err := ParseCalls(strings.NewReader(`[{"args": [1]}]`)); err == nil {
+		t.Error("ParseCalls accepted a call without name")
+	}
+}
+
+func TestFromTrace(t *testing.T) {
+	calls := []Call{
+		{Name: "read", Arch: specs.ArchX86_64, Args: []*uint64{u64(3), nil, u64(832)}},
+		{Name: "read", Arch: specs.ArchX86_64, Args: []*uint64{u64(3), nil, u64(64)}},
+		{Name: "close", Arch: specs.ArchX86_64, Args: []*uint64{u64(3)}},
+		{Name: "dup", Arch: specs.ArchX86, Args: []*uint64{u64(3)}},
+		{Name: "getpid"},
+		{Name: "exit_group", Args: []*uint64{nil}},
+	}
+	for _, tc := range []struct {
+		name string
+		opts []TraceOption
+		want *specs.LinuxSeccomp
+	}{
+		{
+			name: "names",
+			want: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActErrno,
+				Architectures: []specs.Arch{specs.ArchX86, specs.ArchX86_64},
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"close", "dup", "exit_group", "getpid", "read"}, Action: specs.ActAllow},
+				},
+			},
+		},
+		{
+			name: "pinned arguments",
+			opts: []TraceOption{WithPinnedArgs(), WithErrno(38)},
+			want: &specs.LinuxSeccomp{
+				DefaultAction:   specs.ActErrno,
+				DefaultErrnoRet: uintPtr(38),
+				Architectures:   []specs.Arch{specs.ArchX86, specs.ArchX86_64},
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"exit_group", "getpid"}, Action: specs.ActAllow},
+					{Names: []string{"close", "dup", "read"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{{Index: 0, Value: 3, Op: specs.OpEqualTo}}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromTrace(calls, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+			for _, c := range calls {
+				arch := c.Arch
+				if arch == "" {
+					arch = specs.ArchX86_64
+				}
+				var args []uint64
+				for _, a := range c.Args {
+					if a != nil {
+						args = append(args, *a)
+					} else {
+						args = append(args, 0)
+					}
+				}
+				if r, err := Evaluate(got, arch, c.Name, args...); err != nil || r.Action != specs.ActAllow {
+					t.Errorf("%s on %s: %+v, %v", c.Name, arch, r, err)
+				}
+			}
+		})
+	}
+
+	for _, bad := range [][]Call{{{Arch: specs.ArchX86_64}}, {{Name: "read", Arch: "SCMP_ARCH_BOGUS"}}} {
+		if _, err := FromTrace(bad); err == nil {
+			t.Errorf("FromTrace(%+v) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+	"github.com/opencontainers/runtime-spec/specs-go/version"
+)
+
+// genericMountOptions are the option strings of config.md which are not
+// filesystem-specific. Options outside this list are passed to mount(2) as
+// data, and are therefore never listed in features.Features.MountOptions.
+var genericMountOptions = map[string]bool{
+	"async": true, "atime": true, "bind": true, "defaults": true, "dev": true,
+	"diratime": true, "dirsync": true, "exec": true, "iversion": true,
+	"lazytime": true, "loud": true, "mand": true, "noatime": true,
+	"nodev": true, "nodiratime": true, "noexec": true, "noiversion": true,
+	"nolazytime": true, "nomand": true, "norelatime": true,
+	"nostrictatime": true, "nosuid": true, "nosymfollow": true,
+	"private": true, "ratime": true, "rbind": true, "rdev": true,
+	"rdiratime": true, "relatime": true, "remount": true, "rexec": true,
+	"rnoatime": true, "rnodiratime": true, "rnoexec": true,
+	"rnorelatime": true, "rnostrictatime": true, "rnosuid": true,
+	"rnosymfollow": true, "ro": true, "rprivate": true, "rrelatime": true,
+	"rro": true, "rrw": true, "rshared": true, "rslave": true,
+	"rstrictatime": true, "rsuid": true, "rsymfollow": true,
+	"runbindable": true, "rw": true, "shared": true, "silent": true,
+	"slave": true, "strictatime": true, "suid": true, "symfollow": true,
+	"sync": true, "tmpcopyup": true, "unbindable": true, "idmap": true,
+	"ridmap": true,
+}
+
+// CheckFeatures reports every field of spec which the runtime described by
+// feat cannot honour. Lists and flags of feat which are nil are treated as
+// "unknown" and never cause an error, as defined by features.md.
+//
+// Unsupported capabilities are reported at the Should level, because
+// runtimes only log a warning for them; everything else is reported at the
+// Must level.
+func CheckFeatures(spec *specs.Spec, feat *features.Features) []error {
+	if spec == nil || feat == nil {
+		return []error{&Error{Path: "$", Level: Must, Msg: "configuration or features is nil"}}
+	}
+	v := &validator{spec: spec}
+	v.checkFeatureVersion(feat)
+	v.checkFeatureHooks(feat.Hooks)
+	v.checkFeatureMountOptions(feat.MountOptions)
+	v.checkFeatureMountExtensions(feat.Linux)
+	if spec.Linux != nil || spec.Process != nil {
+		v.checkFeatureLinux(feat.Linux)
+	}
+	return v.errs
+}
+
+func (v *validator) unsupported(level Level, path string, format string, args ...interface{}) {
+	v.report(level, path, "%s is not supported by the runtime", fmt.Sprintf(format, args...))
+}
+
+func (v *validator) checkFeatureVersion(feat *features.Features) {
+	if v.spec.Version == "" || (feat.OCIVersionMin == "" && feat.OCIVersionMax == "") {
+		return
+	}
+	ver, err := version.Parse(v.spec.Version)
+	if err != nil {
+		v.must("ociVersion", "%v", err)
+		return
+	}
+	r, err := version.FeaturesRange(feat)
+	if err != nil {
+		v.must("ociVersion", "invalid features version range: %v", err)
+		return
+	}
+	if !r.Contains(ver) {
+		v.unsupported(Must, "ociVersion", "version %s (recognized range %s)", ver, r)
+	}
+}
+
+func (v *validator) checkFeatureHooks(known []string) {
+	h := v.spec.Hooks
+	if h == nil || known == nil {
+		return
+	}
+	for _, phase := range hookPhases(h) {
+		if len(phase.hooks) > 0 && !contains(known, phase.name) {
+			v.unsupported(Must, "hooks."+phase.name, "hook %q", phase.name)
+		}
+	}
+}
+
+func (v *validator) checkFeatureMountOptions(known []string) {
+	if known == nil {
+		return
+	}
+	for i, m := range v.spec.Mounts {
+		for j, o := range m.Options {
+			if genericMountOptions[o] && !contains(known, o) {
+				v.unsupported(Must, fmt.Sprintf("mounts[%d].options[%d]", i, j), "mount option %q", o)
+			}
+		}
+	}
+}
+
+// checkFeatureMountExtensions checks the mounts of the configuration, which
+// need no linux or process section, against the mount extensions of feat.
+func (v *validator) checkFeatureMountExtensions(feat *features.Linux) {
+	if feat == nil || feat.MountExtensions == nil {
+		return
+	}
+	if idmap := feat.MountExtensions.IDMap; idmap != nil && isFalse(idmap.Enabled) {
+		for i, m := range v.spec.Mounts {
+			if len(m.UIDMappings) > 0 || len(m.GIDMappings) > 0 || contains(m.Options, "idmap") || contains(m.Options, "ridmap") {
+				v.unsupported(Must, fmt.Sprintf("mounts[%d]", i), "idmap mount")
+			}
+		}
+	}
+}
+
+func (v *validator) checkFeatureLinux(feat *features.Linux) {
+	if feat == nil {
+		return
+	}
+	linux := v.spec.Linux
+	if linux == nil {
+		linux = &specs.Linux{}
+	}
+
+	if feat.Namespaces != nil {
+		for i, ns := range linux.Namespaces {
+			if !contains(feat.Namespaces, string(ns.Type)) {
+				v.unsupported(Must, fmt.Sprintf("linux.namespaces[%d].type", i), "namespace %q", ns.Type)
+			}
+		}
+	}
+
+	if proc := v.spec.Process; proc != nil {
+		if caps := proc.Capabilities; caps != nil && feat.Capabilities != nil {
+			for _, set := range []struct {
+				name string
+				caps []string
+			}{
+				{"bounding", caps.Bounding},
+				{"effective", caps.Effective},
+				{"inheritable", caps.Inheritable},
+				{"permitted", caps.Permitted},
+				{"ambient", caps.Ambient},
+			} {
+				for i, c := range set.caps {
+					if !contains(feat.Capabilities, c) {
+						v.unsupported(Should, fmt.Sprintf("process.capabilities.%s[%d]", set.name, i), "capability %q", c)
+					}
+				}
+			}
+		}
+		if proc.ApparmorProfile != "" && feat.Apparmor != nil && isFalse(feat.Apparmor.Enabled) {
+			v.unsupported(Must, "process.apparmorProfile", "AppArmor")
+		}
+		if proc.SelinuxLabel != "" && feat.Selinux != nil && isFalse(feat.Selinux.Enabled) {
+			v.unsupported(Must, "process.selinuxLabel", "SELinux")
+		}
+	}
+	if linux.MountLabel != "" && feat.Selinux != nil && isFalse(feat.Selinux.Enabled) {
+		v.unsupported(Must, "linux.mountLabel", "SELinux")
+	}
+
+	if r := linux.Resources; r != nil && feat.Cgroup != nil {
+		cg := feat.Cgroup
+		switch {
+		case isFalse(cg.V1) && isFalse(cg.V2):
+			v.unsupported(Must, "linux.resources", "cgroup")
+		case len(r.Unified) > 0 && isFalse(cg.V2):
+			v.unsupported(Must, "linux.resources.unified", "cgroup v2")
+		}
+		if len(r.Rdma) > 0 && isFalse(cg.Rdma) {
+			v.unsupported(Must, "linux.resources.rdma", "RDMA cgroup")
+		}
+	}
+
+	if s := linux.Seccomp; s != nil && feat.Seccomp != nil {
+		v.checkFeatureSeccomp(s, feat.Seccomp)
+	}
+
+	if rdt := linux.IntelRdt; rdt != nil && feat.IntelRdt != nil {
+		if isFalse(feat.IntelRdt.Enabled) {
+			v.unsupported(Must, "linux.intelRdt", "Intel RDT")
+		} else {
+			if len(rdt.Schemata) > 0 && isFalse(feat.IntelRdt.Schemata) {
+				v.unsupported(Must, "linux.intelRdt.schemata", "Intel RDT schemata")
+			}
+			if rdt.EnableMonitoring && isFalse(feat.IntelRdt.Monitoring) {
+				v.unsupported(Must, "linux.intelRdt.enableMonitoring", "Intel RDT monitoring")
+			}
+		}
+	}
+
+	if mp := linux.MemoryPolicy; mp != nil && feat.MemoryPolicy != nil {
+		if feat.MemoryPolicy.Modes != nil && !contains(feat.MemoryPolicy.Modes, string(mp.Mode)) {
+			v.unsupported(Must, "linux.memoryPolicy.mode", "memory policy mode %q", mp.Mode)
+		}
+		if feat.MemoryPolicy.Flags != nil {
+			for i, f := range mp.Flags {
+				if !contains(feat.MemoryPolicy.Flags, string(f)) {
+					v.unsupported(Must, fmt.Sprintf("linux.memoryPolicy.flags[%d]", i), "memory policy flag %q", f)
+				}
+			}
+		}
+	}
+
+	if len(linux.NetDevices) > 0 && feat.NetDevices != nil && isFalse(feat.NetDevices.Enabled) {
+		v.unsupported(Must, "linux.netDevices", "network devices")
+	}
+}
+
+func (v *validator) checkFeatureSeccomp(s *specs.LinuxSeccomp, feat *features.Seccomp) {
+	if isFalse(feat.Enabled) {
+		v.unsupported(Must, "linux.seccomp", "seccomp")
+		return
+	}
+	if feat.Actions != nil && !contains(feat.Actions, string(s.DefaultAction)) {
+		v.unsupported(Must, "linux.seccomp.defaultAction", "action %q", s.DefaultAction)
+	}
+	if feat.Archs != nil {
+		for i, arch := range s.Architectures {
+			if !contains(feat.Archs, string(arch)) {
+				v.unsupported(Must, fmt.Sprintf("linux.seccomp.architectures[%d]", i), "architecture %q", arch)
+			}
+		}
+	}
+	flags := feat.SupportedFlags
+	if flags == nil {
+		flags = feat.KnownFlags
+	}
+	if flags != nil {
+		for i, f := range s.Flags {
+			if !contains(flags, string(f)) {
+				v.unsupported(Must, fmt.Sprintf("linux.seccomp.flags[%d]", i), "flag %q", f)
+			}
+		}
+	}
+	for i, sc := range s.Syscalls {
+		p := fmt.Sprintf("linux.seccomp.syscalls[%d]", i)
+		if feat.Actions != nil && !contains(feat.Actions, string(sc.Action)) {
+			v.unsupported(Must, p+".action", "action %q", sc.Action)
+		}
+		if feat.Operators != nil {
+			for j, arg := range sc.Args {
+				if !contains(feat.Operators, string(arg.Op)) {
+					v.unsupported(Must, fmt.Sprintf("%s.args[%d].op", p, j), "operator %q", arg.Op)
+				}
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isFalse(b *bool) bool {
+	return b != nil && !*b
+}
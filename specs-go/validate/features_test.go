@@ -0,0 +1,170 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+func TestCheckFeatures(t *testing.T) {
+	yes, no := true, false
+	for _, tc := range []struct {
+		name string
+		edit func(s *specs.Spec)
+		feat features.Features
+		want map[string]Level
+	}{
+		{
+			name: "unknown",
+			edit: func(s *specs.Spec) {
+				s.Hooks = &specs.Hooks{Poststop: []specs.Hook{{Path: "/bin/true"}}}
+				s.Linux.Seccomp = &specs.LinuxSeccomp{DefaultAction: specs.ActNotify}
+			},
+		},
+		{
+			name: "version",
+			edit: func(s *specs.Spec) { s.Version = "1.3.0" },
+			feat: features.Features{OCIVersionMin: "1.0.0", OCIVersionMax: "1.2.0"},
+			want: map[string]Level{"ociVersion": Must},
+		},
+		{
+			name: "version in range",
+			edit: func(s *specs.Spec) { s.Version = "1.1.0" },
+			feat: features.Features{OCIVersionMin: "1.0.0", OCIVersionMax: "1.2.0"},
+		},
+		{
+			name: "hooks",
+			edit: func(s *specs.Spec) {
+				s.Hooks = &specs.Hooks{
+					CreateRuntime: []specs.Hook{{Path: "/bin/true"}},
+					Poststop:      []specs.Hook{{Path: "/bin/true"}},
+				}
+			},
+			feat: features.Features{Hooks: []string{"createRuntime"}},
+			want: map[string]Level{"hooks.poststop": Must},
+		},
+		{
+			name: "mount options",
+			edit: func(s *specs.Spec) {
+				s.Mounts = []specs.Mount{{Destination: "/data", Options: []string{"rbind", "size=1m", "rro"}}}
+			},
+			feat: features.Features{MountOptions: []string{"rbind"}},
+			want: map[string]Level{"mounts[0].options[2]": Must},
+		},
+		{
+			name: "namespaces and capabilities",
+			edit: func(s *specs.Spec) {
+				s.Linux.Namespaces = []specs.LinuxNamespace{{Type: specs.PIDNamespace}, {Type: specs.TimeNamespace}}
+				s.Process.Capabilities = &specs.LinuxCapabilities{Bounding: []string{"CAP_CHOWN", "CAP_BPF"}}
+			},
+			feat: features.Features{Linux: &features.Linux{
+				Namespaces:   []string{"pid"},
+				Capabilities: []string{"CAP_CHOWN"},
+			}},
+			want: map[string]Level{
+				"linux.namespaces[1].type":         Must,
+				"process.capabilities.bounding[1]": Should,
+			},
+		},
+		{
+			name: "cgroup",
+			edit: func(s *specs.Spec) {
+				s.Linux.Resources = &specs.LinuxResources{
+					Unified: map[string]string{"memory.high": "1G"},
+					Rdma:    map[string]specs.LinuxRdma{"mlx5_1": {}},
+				}
+			},
+			feat: features.Features{Linux: &features.Linux{Cgroup: &features.Cgroup{V1: &yes, V2: &no, Rdma: &no}}},
+			want: map[string]Level{"linux.resources.unified": Must, "linux.resources.rdma": Must},
+		},
+		{
+			name: "seccomp",
+			edit: func(s *specs.Spec) {
+				s.Linux.Seccomp = &specs.LinuxSeccomp{
+					DefaultAction: specs.ActErrno,
+					Architectures: []specs.Arch{specs.ArchX86_64, specs.ArchRISCV64},
+					Flags:         []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog},
+					Syscalls: []specs.LinuxSyscall{{
+						Names:  []string{"kill"},
+						Action: specs.ActNotify,
+						Args:   []specs.LinuxSeccompArg{{Op: specs.OpMaskedEqual}},
+					}},
+				}
+			},
+			feat: features.Features{Linux: &features.Linux{Seccomp: &features.Seccomp{
+				Actions:        []string{"SCMP_ACT_ERRNO"},
+				Operators:      []string{"SCMP_CMP_EQ"},
+				Archs:          []string{"SCMP_ARCH_X86_64"},
+				KnownFlags:     []string{"SECCOMP_FILTER_FLAG_LOG"},
+				SupportedFlags: []string{},
+			}}},
+			want: map[string]Level{
+				"linux.seccomp.architectures[1]":       Must,
+				"linux.seccomp.flags[0]":               Must,
+				"linux.seccomp.syscalls[0].action":     Must,
+				"linux.seccomp.syscalls[0].args[0].op": Must,
+			},
+		},
+		{
+			name: "seccomp disabled",
+			edit: func(s *specs.Spec) {
+				s.Linux.Seccomp = &specs.LinuxSeccomp{DefaultAction: specs.ActAllow}
+			},
+			feat: features.Features{Linux: &features.Linux{Seccomp: &features.Seccomp{Enabled: &no}}},
+			want: map[string]Level{"linux.seccomp": Must},
+		},
+		{
+			name: "security modules",
+			edit: func(s *specs.Spec) {
+				s.Process.ApparmorProfile = "default"
+				s.Process.SelinuxLabel = "system_u:system_r:container_t:s0"
+				s.Linux.MountLabel = "system_u:object_r:container_file_t:s0"
+			},
+			feat: features.Features{Linux: &features.Linux{
+				Apparmor: &features.Apparmor{Enabled: &no},
+				Selinux:  &features.Selinux{Enabled: &no},
+			}},
+			want: map[string]Level{
+				"process.apparmorProfile": Must,
+				"process.selinuxLabel":    Must,
+				"linux.mountLabel":        Must,
+			},
+		},
+		{
+			name: "idmap",
+			edit: func(s *specs.Spec) {
+				s.Mounts = []specs.Mount{{Destination: "/a"}, {Destination: "/b", Options: []string{"ridmap"}}}
+			},
+			feat: features.Features{Linux: &features.Linux{
+				MountExtensions: &features.MountExtensions{IDMap: &features.IDMap{Enabled: &no}},
+			}},
+			want: map[string]Level{"mounts[1]": Must},
+		},
+		{
+			name: "idmap without linux and process",
+			edit: func(s *specs.Spec) {
+				s.Linux, s.Process = nil, nil
+				s.Mounts = []specs.Mount{{Destination: "/a", UIDMappings: []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}}}
+			},
+			feat: features.Features{Linux: &features.Linux{
+				MountExtensions: &features.MountExtensions{IDMap: &features.IDMap{Enabled: &no}},
+			}},
+			want: map[string]Level{"mounts[0]": Must},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := minimal()
+			tc.edit(s)
+			got := violations(t, CheckFeatures(s, &tc.feat))
+			want := tc.want
+			if want == nil {
+				want = map[string]Level{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package validate
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+var freeBSDSharing = map[specs.FreeBSDSharing]bool{
+	"":                        true,
+	specs.FreeBSDShareDisable: true,
+	specs.FreeBSDShareNew:     true,
+	specs.FreeBSDShareInherit: true,
+}
+
+func (v *validator) checkFreeBSD() {
+	fbsd := v.spec.FreeBSD
+	if fbsd == nil {
+		return
+	}
+	for i, d := range fbsd.Devices {
+		p := fmt.Sprintf("freebsd.devices[%d].path", i)
+		if d.Path == "" {
+			v.must(p, "path is required")
+		} else if path.IsAbs(d.Path) {
+			v.must(p, "%q must be relative to /dev", d.Path)
+		}
+	}
+	if jail := fbsd.Jail; jail != nil {
+		for _, f := range []struct {
+			name  string
+			value specs.FreeBSDSharing
+		}{
+			{"host", jail.Host},
+			{"ip4", jail.Ip4},
+			{"ip6", jail.Ip6},
+			{"vnet", jail.Vnet},
+			{"sysvmsg", jail.SysVMsg},
+			{"sysvsem", jail.SysVSem},
+			{"sysvshm", jail.SysVShm},
+		} {
+			if !freeBSDSharing[f.value] {
+				v.must("freebsd.jail."+f.name, "%q is not one of disable, new or inherit", f.value)
+			}
+		}
+	}
+}
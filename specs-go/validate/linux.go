@@ -0,0 +1,363 @@
+package validate
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+var linuxRlimits = map[string]bool{
+	"RLIMIT_AS":         true,
+	"RLIMIT_CORE":       true,
+	"RLIMIT_CPU":        true,
+	"RLIMIT_DATA":       true,
+	"RLIMIT_FSIZE":      true,
+	"RLIMIT_LOCKS":      true,
+	"RLIMIT_MEMLOCK":    true,
+	"RLIMIT_MSGQUEUE":   true,
+	"RLIMIT_NICE":       true,
+	"RLIMIT_NOFILE":     true,
+	"RLIMIT_NPROC":      true,
+	"RLIMIT_RSS":        true,
+	"RLIMIT_RTPRIO":     true,
+	"RLIMIT_RTTIME":     true,
+	"RLIMIT_SIGPENDING": true,
+	"RLIMIT_STACK":      true,
+}
+
+var linuxNamespaces = map[specs.LinuxNamespaceType]bool{
+	specs.PIDNamespace:     true,
+	specs.NetworkNamespace: true,
+	specs.MountNamespace:   true,
+	specs.IPCNamespace:     true,
+	specs.UTSNamespace:     true,
+	specs.UserNamespace:    true,
+	specs.CgroupNamespace:  true,
+	specs.TimeNamespace:    true,
+}
+
+var schedulerPolicies = map[specs.LinuxSchedulerPolicy]bool{
+	specs.SchedOther:    true,
+	specs.SchedFIFO:     true,
+	specs.SchedRR:       true,
+	specs.SchedBatch:    true,
+	specs.SchedISO:      true,
+	specs.SchedIdle:     true,
+	specs.SchedDeadline: true,
+}
+
+var schedulerFlags = map[specs.LinuxSchedulerFlag]bool{
+	specs.SchedFlagResetOnFork:  true,
+	specs.SchedFlagReclaim:      true,
+	specs.SchedFlagDLOverrun:    true,
+	specs.SchedFlagKeepPolicy:   true,
+	specs.SchedFlagKeepParams:   true,
+	specs.SchedFlagUtilClampMin: true,
+	specs.SchedFlagUtilClampMax: true,
+}
+
+var ioPriorityClasses = map[specs.IOPriorityClass]bool{
+	specs.IOPRIO_CLASS_RT:   true,
+	specs.IOPRIO_CLASS_BE:   true,
+	specs.IOPRIO_CLASS_IDLE: true,
+}
+
+var seccompActions = map[specs.LinuxSeccompAction]bool{
+	specs.ActKill:        true,
+	specs.ActKillProcess: true,
+	specs.ActKillThread:  true,
+	specs.ActTrap:        true,
+	specs.ActErrno:       true,
+	specs.ActTrace:       true,
+	specs.ActAllow:       true,
+	specs.ActLog:         true,
+	specs.ActNotify:      true,
+}
+
+var seccompOperators = map[specs.LinuxSeccompOperator]bool{
+	specs.OpNotEqual:     true,
+	specs.OpLessThan:     true,
+	specs.OpLessEqual:    true,
+	specs.OpEqualTo:      true,
+	specs.OpGreaterEqual: true,
+	specs.OpGreaterThan:  true,
+	specs.OpMaskedEqual:  true,
+}
+
+var seccompArchs = map[specs.Arch]bool{
+	specs.ArchX86:         true,
+	specs.ArchX86_64:      true,
+	specs.ArchX32:         true,
+	specs.ArchARM:         true,
+	specs.ArchAARCH64:     true,
+	specs.ArchMIPS:        true,
+	specs.ArchMIPS64:      true,
+	specs.ArchMIPS64N32:   true,
+	specs.ArchMIPSEL:      true,
+	specs.ArchMIPSEL64:    true,
+	specs.ArchMIPSEL64N32: true,
+	specs.ArchPPC:         true,
+	specs.ArchPPC64:       true,
+	specs.ArchPPC64LE:     true,
+	specs.ArchS390:        true,
+	specs.ArchS390X:       true,
+	specs.ArchPARISC:      true,
+	specs.ArchPARISC64:    true,
+	specs.ArchRISCV64:     true,
+	specs.ArchLOONGARCH64: true,
+	specs.ArchM68K:        true,
+	specs.ArchSH:          true,
+	specs.ArchSHEB:        true,
+}
+
+var seccompFlags = map[specs.LinuxSeccompFlag]bool{
+	"SECCOMP_FILTER_FLAG_TSYNC":            true,
+	specs.LinuxSeccompFlagLog:              true,
+	specs.LinuxSeccompFlagSpecAllow:        true,
+	specs.LinuxSeccompFlagWaitKillableRecv: true,
+}
+
+var memoryPolicyModes = map[specs.MemoryPolicyModeType]bool{
+	specs.MpolDefault:            true,
+	specs.MpolBind:               true,
+	specs.MpolInterleave:         true,
+	specs.MpolWeightedInterleave: true,
+	specs.MpolPreferred:          true,
+	specs.MpolPreferredMany:      true,
+	specs.MpolLocal:              true,
+}
+
+var memoryPolicyFlags = map[specs.MemoryPolicyFlagType]bool{
+	specs.MpolFNumaBalancing: true,
+	specs.MpolFRelativeNodes: true,
+	specs.MpolFStaticNodes:   true,
+}
+
+var rootfsPropagations = map[string]bool{
+	"shared":     true,
+	"slave":      true,
+	"private":    true,
+	"unbindable": true,
+}
+
+func (v *validator) checkLinux() {
+	v.checkLinuxProcess()
+
+	linux := v.spec.Linux
+	if linux == nil {
+		return
+	}
+
+	seen := make(map[specs.LinuxNamespaceType]int)
+	for i, ns := range linux.Namespaces {
+		p := fmt.Sprintf("linux.namespaces[%d]", i)
+		if !linuxNamespaces[ns.Type] {
+			v.must(p+".type", "unknown namespace type %q", ns.Type)
+		}
+		if j, ok := seen[ns.Type]; ok {
+			v.must(p+".type", "%s duplicates linux.namespaces[%d]", ns.Type, j)
+		} else {
+			seen[ns.Type] = i
+		}
+		v.checkNamespacePath(p+".path", ns.Path)
+	}
+
+	v.checkLinuxDevices()
+	if linux.Resources != nil {
+		v.checkLinuxResources(linux.Resources)
+	}
+	if linux.Seccomp != nil {
+		v.checkSeccomp(linux.Seccomp)
+	}
+
+	if linux.RootfsPropagation != "" && !rootfsPropagations[linux.RootfsPropagation] {
+		v.must("linux.rootfsPropagation", "%q is not one of shared, slave, private or unbindable", linux.RootfsPropagation)
+	}
+	for i, p := range linux.MaskedPaths {
+		if !path.IsAbs(p) {
+			v.must(fmt.Sprintf("linux.maskedPaths[%d]", i), "%q is not an absolute path", p)
+		}
+	}
+	for i, p := range linux.ReadonlyPaths {
+		if !path.IsAbs(p) {
+			v.must(fmt.Sprintf("linux.readonlyPaths[%d]", i), "%q is not an absolute path", p)
+		}
+	}
+
+	if linux.IntelRdt != nil {
+		v.checkIntelRdt(linux.IntelRdt)
+	}
+	if mp := linux.MemoryPolicy; mp != nil {
+		if !memoryPolicyModes[mp.Mode] {
+			v.must("linux.memoryPolicy.mode", "unknown memory policy mode %q", mp.Mode)
+		}
+		for i, f := range mp.Flags {
+			if !memoryPolicyFlags[f] {
+				v.must(fmt.Sprintf("linux.memoryPolicy.flags[%d]", i), "unknown memory policy flag %q", f)
+			}
+		}
+	}
+	if pers := linux.Personality; pers != nil {
+		if pers.Domain != specs.PerLinux && pers.Domain != specs.PerLinux32 {
+			v.must("linux.personality.domain", "unknown personality domain %q", pers.Domain)
+		}
+		for i, f := range pers.Flags {
+			v.must(fmt.Sprintf("linux.personality.flags[%d]", i), "unsupported personality flag %q", f)
+		}
+	}
+}
+
+func (v *validator) checkLinuxProcess() {
+	proc := v.spec.Process
+	if proc == nil {
+		return
+	}
+	if s := proc.Scheduler; s != nil {
+		if !schedulerPolicies[s.Policy] {
+			v.must("process.scheduler.policy", "unknown scheduling policy %q", s.Policy)
+		}
+		for i, f := range s.Flags {
+			if !schedulerFlags[f] {
+				v.must(fmt.Sprintf("process.scheduler.flags[%d]", i), "unknown scheduling flag %q", f)
+			}
+		}
+	}
+	if prio := proc.IOPriority; prio != nil {
+		if !ioPriorityClasses[prio.Class] {
+			v.must("process.ioPriority.class", "unknown I/O scheduling class %q", prio.Class)
+		}
+		if prio.Priority < 0 || prio.Priority > 7 {
+			v.should("process.ioPriority.priority", "priority should range from 0 to 7, got %d", prio.Priority)
+		}
+	}
+}
+
+func (v *validator) checkLinuxDevices() {
+	type devNum struct {
+		typ          string
+		major, minor int64
+	}
+	seen := make(map[devNum]int)
+	for i, d := range v.spec.Linux.Devices {
+		p := fmt.Sprintf("linux.devices[%d]", i)
+		if d.Path == "" {
+			v.must(p+".path", "path is required")
+		} else if !path.IsAbs(d.Path) {
+			v.must(p+".path", "%q is not an absolute path", d.Path)
+		}
+		switch d.Type {
+		case "c", "b", "u":
+			key := devNum{d.Type, d.Major, d.Minor}
+			if d.Type == "u" {
+				key.typ = "c"
+			}
+			if j, ok := seen[key]; ok {
+				v.should(p, "type, major and minor should not be shared with linux.devices[%d]", j)
+			} else {
+				seen[key] = i
+			}
+		case "p":
+		default:
+			v.must(p+".type", "%q is not one of c, b, u or p", d.Type)
+		}
+	}
+}
+
+func (v *validator) checkLinuxResources(r *specs.LinuxResources) {
+	for i, d := range r.Devices {
+		p := fmt.Sprintf("linux.resources.devices[%d]", i)
+		switch d.Type {
+		case "", "a", "c", "b":
+		default:
+			v.must(p+".type", "%q is not one of a, c or b", d.Type)
+		}
+		if strings.Trim(d.Access, "rwm") != "" {
+			v.must(p+".access", "%q is not a composition of r, w and m", d.Access)
+		}
+	}
+
+	if cpu := r.CPU; cpu != nil && cpu.Quota != nil && *cpu.Quota > 0 && cpu.Burst != nil {
+		if uint64(*cpu.Quota) < *cpu.Burst {
+			v.must("linux.resources.cpu.burst", "burst %d is larger than quota %d", *cpu.Burst, *cpu.Quota)
+		}
+	}
+
+	if bio := r.BlockIO; bio != nil {
+		for i, wd := range bio.WeightDevice {
+			if wd.Weight == nil && wd.LeafWeight == nil {
+				v.must(fmt.Sprintf("linux.resources.blockIO.weightDevice[%d]", i), "at least one of weight or leafWeight must be specified")
+			}
+		}
+	}
+
+	for dev, rdma := range r.Rdma {
+		if rdma.HcaHandles == nil && rdma.HcaObjects == nil {
+			v.must(fmt.Sprintf("linux.resources.rdma[%q]", dev), "at least one of hcaHandles or hcaObjects must be specified")
+		}
+	}
+}
+
+func (v *validator) checkSeccomp(s *specs.LinuxSeccomp) {
+	v.checkSeccompAction("linux.seccomp.defaultAction", "linux.seccomp.defaultErrnoRet", s.DefaultAction, s.DefaultErrnoRet)
+	for i, arch := range s.Architectures {
+		if !seccompArchs[arch] {
+			v.must(fmt.Sprintf("linux.seccomp.architectures[%d]", i), "unknown architecture %q", arch)
+		}
+	}
+	for i, f := range s.Flags {
+		if !seccompFlags[f] {
+			v.must(fmt.Sprintf("linux.seccomp.flags[%d]", i), "unknown flag %q", f)
+		}
+	}
+	if s.ListenerMetadata != "" && s.ListenerPath == "" {
+		v.must("linux.seccomp.listenerMetadata", "listenerMetadata must not be set if listenerPath is not set")
+	}
+	for i, sc := range s.Syscalls {
+		p := fmt.Sprintf("linux.seccomp.syscalls[%d]", i)
+		if len(sc.Names) == 0 {
+			v.must(p+".names", "names must contain at least one entry")
+		}
+		v.checkSeccompAction(p+".action", p+".errnoRet", sc.Action, sc.ErrnoRet)
+		for j, arg := range sc.Args {
+			if !seccompOperators[arg.Op] {
+				v.must(fmt.Sprintf("%s.args[%d].op", p, j), "unknown operator %q", arg.Op)
+			}
+		}
+	}
+}
+
+func (v *validator) checkSeccompAction(actionPath, errnoPath string, action specs.LinuxSeccompAction, errnoRet *uint) {
+	if !seccompActions[action] {
+		v.must(actionPath, "unknown action %q", action)
+		return
+	}
+	if errnoRet != nil && action != specs.ActErrno && action != specs.ActTrace {
+		v.must(errnoPath, "action %s does not support an errno", action)
+	}
+}
+
+func (v *validator) checkIntelRdt(rdt *specs.LinuxIntelRdt) {
+	if s := rdt.L3CacheSchema; s != "" {
+		if !strings.HasPrefix(s, "L3:") {
+			v.should("linux.intelRdt.l3CacheSchema", "value should start with \"L3:\"")
+		}
+		if strings.Contains(s, "\n") {
+			v.should("linux.intelRdt.l3CacheSchema", "value should not contain newlines")
+		}
+	}
+	if s := rdt.MemBwSchema; s != "" {
+		if !strings.HasPrefix(s, "MB:") {
+			v.must("linux.intelRdt.memBwSchema", "value must start with \"MB:\"")
+		}
+		if strings.Contains(s, "\n") {
+			v.must("linux.intelRdt.memBwSchema", "value must not contain newlines")
+		}
+	}
+	for i, s := range rdt.Schemata {
+		if strings.Contains(s, "\n") {
+			v.must(fmt.Sprintf("linux.intelRdt.schemata[%d]", i), "value must not contain newlines")
+		}
+	}
+}
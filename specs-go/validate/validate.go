@@ -0,0 +1,318 @@
+// Package validate checks a specs.Spec against the normative requirements of
+// the specification which cannot be expressed by the JSON schema, and
+// against the Features structure of a runtime.
+package validate
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/version"
+)
+
+// Level is the requirement level of a violated rule, as defined by RFC 2119.
+type Level string
+
+const (
+	// Must is an absolute requirement of the specification.
+	Must Level = "MUST"
+	// Should is a recommendation of the specification.
+	Should Level = "SHOULD"
+)
+
+// Error describes a single violated requirement.
+type Error struct {
+	// Path is the JSON path of the offending field, e.g. "mounts[0].destination".
+	Path string
+	// Level is the requirement level of the violated rule.
+	Level Level
+	// Msg describes the violation.
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.Path, e.Msg, e.Level)
+}
+
+// Platforms is the list of platforms recognized by Validate.
+var Platforms = []string{"linux", "windows", "solaris", "freebsd", "zos"}
+
+// reservedAnnotations are the keys of the org.opencontainers namespace
+// defined by the specification.
+var reservedAnnotations = map[string]bool{
+	"org.opencontainers.image.os":           true,
+	"org.opencontainers.image.os.version":   true,
+	"org.opencontainers.image.os.features":  true,
+	"org.opencontainers.image.architecture": true,
+	"org.opencontainers.image.variant":      true,
+	"org.opencontainers.image.author":       true,
+	"org.opencontainers.image.created":      true,
+	"org.opencontainers.image.stopSignal":   true,
+}
+
+// Validate checks spec against the requirements of config.md and of the
+// platform-specific configuration documents for platform, which is one of
+// Platforms. Every violation is returned as an *Error; a nil result means
+// that the configuration is valid.
+func Validate(spec *specs.Spec, platform string) []error {
+	if spec == nil {
+		return []error{&Error{Path: "$", Level: Must, Msg: "configuration is nil"}}
+	}
+	if !isPlatform(platform) {
+		return []error{fmt.Errorf("unsupported platform %q", platform)}
+	}
+
+	v := &validator{spec: spec, platform: platform}
+
+	v.checkVersion()
+	v.checkRoot()
+	v.checkMounts()
+	v.checkProcess()
+	v.checkHooks()
+	v.checkAnnotations()
+
+	switch platform {
+	case "linux":
+		v.checkLinux()
+	case "windows":
+		v.checkWindows()
+	case "freebsd":
+		v.checkFreeBSD()
+	case "zos":
+		v.checkZOS()
+	}
+	if spec.VM != nil {
+		v.checkVM()
+	}
+	return v.errs
+}
+
+func isPlatform(platform string) bool {
+	for _, p := range Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+type validator struct {
+	spec     *specs.Spec
+	platform string
+	errs     []error
+}
+
+func (v *validator) report(level Level, path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, &Error{Path: path, Level: level, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) must(path string, format string, args ...interface{}) {
+	v.report(Must, path, format, args...)
+}
+
+func (v *validator) should(path string, format string, args ...interface{}) {
+	v.report(Should, path, format, args...)
+}
+
+// isAbs reports whether p is an absolute path on the validated platform.
+func (v *validator) isAbs(p string) bool {
+	if v.platform == "windows" {
+		return isWindowsAbs(p)
+	}
+	return path.IsAbs(p)
+}
+
+func (v *validator) checkVersion() {
+	if v.spec.Version == "" {
+		v.must("ociVersion", "ociVersion is required")
+		return
+	}
+	if _, err := version.Parse(v.spec.Version); err != nil {
+		v.must("ociVersion", "%v", err)
+	}
+}
+
+func (v *validator) checkRoot() {
+	root := v.spec.Root
+	if v.platform == "windows" {
+		hyperV := v.spec.Windows != nil && v.spec.Windows.HyperV != nil
+		switch {
+		case hyperV && root != nil:
+			v.must("root", "root must not be set for Hyper-V containers")
+		case !hyperV && root == nil:
+			v.must("root", "root is required for Windows Server Containers")
+		}
+		if root == nil {
+			return
+		}
+		if !isVolumeGUIDPath(root.Path) {
+			v.must("root.path", "%q is not a volume GUID path", root.Path)
+		}
+		if root.Readonly {
+			v.must("root.readonly", "readonly must be omitted or false on Windows")
+		}
+		return
+	}
+
+	if root == nil {
+		v.must("root", "root is required")
+		return
+	}
+	if root.Path == "" {
+		v.must("root.path", "path is required")
+		return
+	}
+	if root.Path != "rootfs" {
+		v.should("root.path", "path should be the conventional \"rootfs\", got %q", root.Path)
+	}
+}
+
+func (v *validator) checkMounts() {
+	for i, m := range v.spec.Mounts {
+		p := fmt.Sprintf("mounts[%d]", i)
+		if m.Destination == "" {
+			v.must(p+".destination", "destination is required")
+			continue
+		}
+		if !v.isAbs(m.Destination) {
+			if v.platform == "linux" {
+				v.should(p+".destination", "%q should be an absolute path", m.Destination)
+			} else {
+				v.must(p+".destination", "%q is not an absolute path", m.Destination)
+			}
+		}
+		if v.platform == "windows" {
+			for j, o := range v.spec.Mounts[:i] {
+				if isNestedWindowsPath(o.Destination, m.Destination) || isNestedWindowsPath(m.Destination, o.Destination) {
+					v.must(p+".destination", "%q must not be nested within mounts[%d] destination %q", m.Destination, j, o.Destination)
+				}
+			}
+		}
+		if v.platform == "linux" {
+			v.checkMountIDMappings(p, m)
+		}
+	}
+}
+
+func (v *validator) checkMountIDMappings(p string, m specs.Mount) {
+	if len(m.UIDMappings) == 0 && len(m.GIDMappings) == 0 {
+		return
+	}
+	if len(m.UIDMappings) == 0 {
+		v.must(p+".uidMappings", "uidMappings must be specified along with gidMappings")
+	}
+	if len(m.GIDMappings) == 0 {
+		v.must(p+".gidMappings", "gidMappings must be specified along with uidMappings")
+	}
+	for _, o := range m.Options {
+		if o == "idmap" || o == "ridmap" {
+			return
+		}
+	}
+	v.should(p+".options", "options should contain \"idmap\" or \"ridmap\" when mappings are specified")
+}
+
+func (v *validator) checkProcess() {
+	proc := v.spec.Process
+	if proc == nil {
+		return
+	}
+
+	if proc.Cwd == "" {
+		v.must("process.cwd", "cwd is required")
+	} else if !v.isAbs(proc.Cwd) {
+		v.must("process.cwd", "%q is not an absolute path", proc.Cwd)
+	}
+
+	if v.platform == "windows" {
+		if len(proc.Args) == 0 && proc.CommandLine == "" {
+			v.must("process.commandLine", "commandLine is required if args is omitted")
+		}
+	} else if len(proc.Args) == 0 {
+		v.must("process.args", "args must contain at least one entry")
+	}
+
+	switch v.platform {
+	case "linux", "solaris", "zos":
+		v.checkRlimits()
+	}
+}
+
+func (v *validator) checkRlimits() {
+	seen := make(map[string]int)
+	for i, rl := range v.spec.Process.Rlimits {
+		p := fmt.Sprintf("process.rlimits[%d]", i)
+		if rl.Type == "" {
+			v.must(p+".type", "type is required")
+			continue
+		}
+		if j, ok := seen[rl.Type]; ok {
+			v.must(p+".type", "%s duplicates process.rlimits[%d]", rl.Type, j)
+		} else {
+			seen[rl.Type] = i
+		}
+		if v.platform == "linux" && !linuxRlimits[rl.Type] {
+			v.must(p+".type", "%q cannot be mapped to a Linux resource limit", rl.Type)
+		}
+	}
+}
+
+func (v *validator) checkHooks() {
+	h := v.spec.Hooks
+	if h == nil {
+		return
+	}
+	for _, phase := range hookPhases(h) {
+		for i, hook := range phase.hooks {
+			p := fmt.Sprintf("hooks.%s[%d]", phase.name, i)
+			if hook.Path == "" {
+				v.must(p+".path", "path is required")
+			} else if !path.IsAbs(hook.Path) {
+				v.must(p+".path", "%q is not an absolute path", hook.Path)
+			}
+			if hook.Timeout != nil && *hook.Timeout <= 0 {
+				v.must(p+".timeout", "timeout must be greater than zero, got %d", *hook.Timeout)
+			}
+		}
+	}
+}
+
+// hookPhase is a lifecycle phase of Hooks together with its JSON name.
+type hookPhase struct {
+	name  string
+	hooks []specs.Hook
+}
+
+func hookPhases(h *specs.Hooks) []hookPhase {
+	return []hookPhase{
+		{"prestart", h.Prestart}, //nolint:staticcheck // Prestart is deprecated but still valid.
+		{"createRuntime", h.CreateRuntime},
+		{"createContainer", h.CreateContainer},
+		{"startContainer", h.StartContainer},
+		{"poststart", h.Poststart},
+		{"poststop", h.Poststop},
+	}
+}
+
+func (v *validator) checkAnnotations() {
+	for key := range v.spec.Annotations {
+		p := fmt.Sprintf("annotations[%q]", key)
+		if key == "" {
+			v.must(p, "keys must not be an empty string")
+			continue
+		}
+		if strings.HasPrefix(key, "org.opencontainers.") && !reservedAnnotations[key] {
+			v.must(p, "%q is reserved by the org.opencontainers namespace", key)
+		}
+	}
+}
+
+// checkNamespacePath validates the path of a namespace entry, shared by the
+// Linux and z/OS namespace checks.
+func (v *validator) checkNamespacePath(p, nsPath string) {
+	if nsPath != "" && !path.IsAbs(nsPath) {
+		v.must(p, "%q is not an absolute path", nsPath)
+	}
+}
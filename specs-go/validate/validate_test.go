@@ -0,0 +1,322 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// minimal returns a valid Linux configuration.
+func minimal() *specs.Spec {
+	return &specs.Spec{
+		Version: specs.Version,
+		Root:    &specs.Root{Path: "rootfs"},
+		Process: &specs.Process{Cwd: "/", Args: []string{"sh"}},
+		Linux:   &specs.Linux{},
+	}
+}
+
+// violations returns the levels of the violations of errs, by path.
+func violations(t *testing.T, errs []error) map[string]Level {
+	t.Helper()
+	got := make(map[string]Level)
+	for _, err := range errs {
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("unexpected error %v", err)
+		}
+		got[e.Path] = e.Level
+	}
+	return got
+}
+
+func TestValidate(t *testing.T) {
+	uint64p := func(v uint64) *uint64 { return &v }
+	errno := uint(1)
+	for _, tc := range []struct {
+		name     string
+		platform string
+		edit     func(s *specs.Spec)
+		want     map[string]Level
+	}{
+		{
+			name: "valid",
+			edit: func(s *specs.Spec) {},
+		},
+		{
+			name: "version",
+			edit: func(s *specs.Spec) { s.Version = "1.0" },
+			want: map[string]Level{"ociVersion": Must},
+		},
+		{
+			name: "root",
+			edit: func(s *specs.Spec) { s.Root.Path = "/var/rootfs" },
+			want: map[string]Level{"root.path": Should},
+		},
+		{
+			name: "process",
+			edit: func(s *specs.Spec) {
+				s.Process.Cwd = "home"
+				s.Process.Args = nil
+				s.Process.Rlimits = []specs.POSIXRlimit{
+					{Type: "RLIMIT_NOFILE"},
+					{Type: "RLIMIT_NOFILE"},
+					{Type: "RLIMIT_BOGUS"},
+				}
+			},
+			want: map[string]Level{
+				"process.cwd":             Must,
+				"process.args":            Must,
+				"process.rlimits[1].type": Must,
+				"process.rlimits[2].type": Must,
+			},
+		},
+		{
+			name: "mounts",
+			edit: func(s *specs.Spec) {
+				s.Mounts = []specs.Mount{
+					{Destination: "tmp"},
+					{Destination: "/data", UIDMappings: []specs.LinuxIDMapping{{Size: 1}}},
+				}
+			},
+			want: map[string]Level{
+				"mounts[0].destination": Should,
+				"mounts[1].gidMappings": Must,
+				"mounts[1].options":     Should,
+			},
+		},
+		{
+			name: "hooks",
+			edit: func(s *specs.Spec) {
+				timeout := 0
+				s.Hooks = &specs.Hooks{
+					CreateRuntime: []specs.Hook{{Path: "/bin/hook"}, {Path: "hook", Timeout: &timeout}},
+				}
+			},
+			want: map[string]Level{
+				"hooks.createRuntime[1].path":    Must,
+				"hooks.createRuntime[1].timeout": Must,
+			},
+		},
+		{
+			name: "annotations",
+			edit: func(s *specs.Spec) {
+				s.Annotations = map[string]string{
+					"org.opencontainers.image.os": "linux",
+					"org.opencontainers.custom":   "x",
+				}
+			},
+			want: map[string]Level{`annotations["org.opencontainers.custom"]`: Must},
+		},
+		{
+			name: "namespaces",
+			edit: func(s *specs.Spec) {
+				s.Linux.Namespaces = []specs.LinuxNamespace{
+					{Type: specs.PIDNamespace},
+					{Type: specs.PIDNamespace, Path: "proc/1/ns/pid"},
+					{Type: "bogus"},
+				}
+			},
+			want: map[string]Level{
+				"linux.namespaces[1].type": Must,
+				"linux.namespaces[1].path": Must,
+				"linux.namespaces[2].type": Must,
+			},
+		},
+		{
+			name: "devices",
+			edit: func(s *specs.Spec) {
+				s.Linux.Devices = []specs.LinuxDevice{
+					{Path: "/dev/a", Type: "c", Major: 1, Minor: 3},
+					{Path: "/dev/b", Type: "u", Major: 1, Minor: 3},
+					{Path: "dev/c", Type: "x"},
+				}
+			},
+			want: map[string]Level{
+				"linux.devices[1]":      Should,
+				"linux.devices[2].path": Must,
+				"linux.devices[2].type": Must,
+			},
+		},
+		{
+			name: "resources",
+			edit: func(s *specs.Spec) {
+				quota, burst := int64(1000), uint64(2000)
+				s.Linux.Resources = &specs.LinuxResources{
+					Devices: []specs.LinuxDeviceCgroup{{Type: "x", Access: "rwx"}},
+					CPU:     &specs.LinuxCPU{Quota: &quota, Burst: &burst},
+					BlockIO: &specs.LinuxBlockIO{WeightDevice: []specs.LinuxWeightDevice{{}}},
+					Rdma:    map[string]specs.LinuxRdma{"mlx5_1": {}},
+				}
+			},
+			want: map[string]Level{
+				"linux.resources.devices[0].type":         Must,
+				"linux.resources.devices[0].access":       Must,
+				"linux.resources.cpu.burst":               Must,
+				"linux.resources.blockIO.weightDevice[0]": Must,
+				`linux.resources.rdma["mlx5_1"]`:          Must,
+			},
+		},
+		{
+			name: "seccomp",
+			edit: func(s *specs.Spec) {
+				s.Linux.Seccomp = &specs.LinuxSeccomp{
+					DefaultAction:    specs.ActAllow,
+					DefaultErrnoRet:  &errno,
+					Architectures:    []specs.Arch{"SCMP_ARCH_BOGUS"},
+					ListenerMetadata: "x",
+					Syscalls: []specs.LinuxSyscall{
+						{Action: specs.ActErrno, ErrnoRet: &errno, Args: []specs.LinuxSeccompArg{{Op: "SCMP_CMP_BOGUS"}}},
+					},
+				}
+			},
+			want: map[string]Level{
+				"linux.seccomp.defaultErrnoRet":        Must,
+				"linux.seccomp.architectures[0]":       Must,
+				"linux.seccomp.listenerMetadata":       Must,
+				"linux.seccomp.syscalls[0].names":      Must,
+				"linux.seccomp.syscalls[0].args[0].op": Must,
+			},
+		},
+		{
+			name: "linux",
+			edit: func(s *specs.Spec) {
+				s.Linux.RootfsPropagation = "rshared"
+				s.Linux.MaskedPaths = []string{"proc/kcore"}
+				s.Linux.IntelRdt = &specs.LinuxIntelRdt{L3CacheSchema: "0=f", MemBwSchema: "0=50"}
+			},
+			want: map[string]Level{
+				"linux.rootfsPropagation":      Must,
+				"linux.maskedPaths[0]":         Must,
+				"linux.intelRdt.l3CacheSchema": Should,
+				"linux.intelRdt.memBwSchema":   Must,
+			},
+		},
+		{
+			name:     "windows",
+			platform: "windows",
+			edit: func(s *specs.Spec) {
+				s.Root = &specs.Root{Path: `\\?\Volume{ec84d99e-3f02-11e7-ac6c-00155d7682cf}\`}
+				s.Process.Cwd = `C:\`
+				s.Linux = nil
+				s.Windows = &specs.Windows{LayerFolders: []string{`C:\layers\1`}}
+				s.Mounts = []specs.Mount{{Destination: `C:\data`}, {Destination: `c:\Data\sub`}}
+			},
+			want: map[string]Level{"mounts[1].destination": Must},
+		},
+		{
+			name:     "windows root",
+			platform: "windows",
+			edit: func(s *specs.Spec) {
+				s.Process.Cwd = `C:\`
+				s.Linux = nil
+				s.Windows = &specs.Windows{}
+			},
+			want: map[string]Level{"root.path": Must, "windows.layerFolders": Must},
+		},
+		{
+			name:     "freebsd",
+			platform: "freebsd",
+			edit: func(s *specs.Spec) {
+				s.Linux = nil
+				s.FreeBSD = &specs.FreeBSD{
+					Devices: []specs.FreeBSDDevice{{Path: "/dev/null"}},
+					Jail:    &specs.FreeBSDJail{Vnet: "bogus"},
+				}
+			},
+			want: map[string]Level{"freebsd.devices[0].path": Must, "freebsd.jail.vnet": Must},
+		},
+		{
+			name:     "zos",
+			platform: "zos",
+			edit: func(s *specs.Spec) {
+				s.Linux = nil
+				s.ZOS = &specs.ZOS{Namespaces: []specs.ZOSNamespace{{Type: specs.ZOSPIDNamespace}, {Type: specs.ZOSPIDNamespace}}}
+			},
+			want: map[string]Level{"zos.namespaces[1].type": Must},
+		},
+		{
+			name: "vm",
+			edit: func(s *specs.Spec) {
+				s.VM = &specs.VM{
+					Kernel:   specs.VMKernel{Path: "vmlinuz"},
+					HwConfig: &specs.HWConfig{IOMems: []specs.IOMems{{FirstMFN: uint64p(1)}}},
+				}
+			},
+			want: map[string]Level{"vm.kernel.path": Must, "vm.hwconfig.iomems[0].nrMFNs": Must},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := minimal()
+			tc.edit(s)
+			platform := tc.platform
+			if platform == "" {
+				platform = "linux"
+			}
+			got := violations(t, Validate(s, platform))
+			want := tc.want
+			if want == nil {
+				want = map[string]Level{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateUnsupportedPlatform(t *testing.T) {
+	errs := Validate(minimal(), "plan9")
+	if len(errs) != 1 {
+		t.Fatalf("got %v, want one error", errs)
+	}
+	var e *Error
+	if errors.As(errs[0], &e) {
+		t.Errorf("got %v, want an error which is not a violation", e)
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	for _, tc := range []struct {
+		file, platform string
+	}{
+		{"minimal.json", "linux"},
+		{"spec-example.json", "linux"},
+		{"freebsd-minimal.json", "freebsd"},
+		{"zos-minimal.json", "zos"},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "..", "schema", "test", "config", "good", tc.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s specs.Spec
+			if err := json.Unmarshal(data, &s); err != nil {
+				t.Fatal(err)
+			}
+			for path, level := range violations(t, Validate(&s, tc.platform)) {
+				if level == Must {
+					t.Errorf("%s: unexpected violation of a MUST requirement", path)
+				}
+			}
+		})
+	}
+}
+
+func TestIsVolumeGUIDPath(t *testing.T) {
+	for p, want := range map[string]bool{
+		`\\?\Volume{ec84d99e-3f02-11e7-ac6c-00155d7682cf}\`: true,
+		`\\?\Volume{ec84d99e-3f02-11e7-ac6c-00155d7682cf}`:  true,
+		`\\?\Volume{ec84d99e-3f02-11e7-ac6c}\`:              false,
+		`C:\rootfs`:                                         false,
+	} {
+		if got := isVolumeGUIDPath(p); got != want {
+			t.Errorf("isVolumeGUIDPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
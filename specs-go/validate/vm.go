@@ -0,0 +1,35 @@
+package validate
+
+import (
+	"fmt"
+	"path"
+)
+
+func (v *validator) checkVM() {
+	vm := v.spec.VM
+	if vm.Hypervisor.Path != "" && !path.IsAbs(vm.Hypervisor.Path) {
+		v.must("vm.hypervisor.path", "%q is not an absolute path", vm.Hypervisor.Path)
+	}
+	if vm.Kernel.Path == "" {
+		v.must("vm.kernel.path", "path is required")
+	} else if !path.IsAbs(vm.Kernel.Path) {
+		v.must("vm.kernel.path", "%q is not an absolute path", vm.Kernel.Path)
+	}
+	if vm.Kernel.InitRD != "" && !path.IsAbs(vm.Kernel.InitRD) {
+		v.must("vm.kernel.initrd", "%q is not an absolute path", vm.Kernel.InitRD)
+	}
+	if vm.Image.Path != "" && !path.IsAbs(vm.Image.Path) {
+		v.must("vm.image.path", "%q is not an absolute path", vm.Image.Path)
+	}
+	if hw := vm.HwConfig; hw != nil {
+		for i, m := range hw.IOMems {
+			p := fmt.Sprintf("vm.hwconfig.iomems[%d]", i)
+			if m.FirstMFN == nil {
+				v.must(p+".firstMFN", "firstMFN is required")
+			}
+			if m.NrMFNs == nil {
+				v.must(p+".nrMFNs", "nrMFNs is required")
+			}
+		}
+	}
+}
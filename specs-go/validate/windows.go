@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// volumeGUIDRegexp matches a volume GUID path such as
+// \\?\Volume{ec84d99e-3f02-11e7-ac6c-00155d7682cf}\.
+var volumeGUIDRegexp = regexp.MustCompile(`^\\\\\?\\Volume\{[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\}\\?$`)
+
+func isVolumeGUIDPath(p string) bool {
+	return volumeGUIDRegexp.MatchString(p)
+}
+
+// isWindowsAbs reports whether p is a drive-qualified or UNC absolute path.
+func isWindowsAbs(p string) bool {
+	if strings.HasPrefix(p, `\\`) {
+		return true
+	}
+	if len(p) < 3 || p[1] != ':' || (p[2] != '\\' && p[2] != '/') {
+		return false
+	}
+	c := p[0]
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// isNestedWindowsPath reports whether child is located below parent, using
+// case-insensitive comparison as Windows does.
+func isNestedWindowsPath(parent, child string) bool {
+	parent = strings.ToLower(strings.TrimRight(strings.ReplaceAll(parent, "/", `\`), `\`))
+	child = strings.ToLower(strings.ReplaceAll(child, "/", `\`))
+	return parent != "" && strings.HasPrefix(child, parent+`\`)
+}
+
+func (v *validator) checkWindows() {
+	win := v.spec.Windows
+	if win == nil {
+		v.must("windows", "windows must be set if the target platform is windows")
+		return
+	}
+	if win.HyperV == nil && len(win.LayerFolders) == 0 {
+		v.must("windows.layerFolders", "layerFolders must contain at least one entry")
+	}
+	for i, d := range win.Devices {
+		p := fmt.Sprintf("windows.devices[%d]", i)
+		if d.ID == "" {
+			v.must(p+".id", "id is required")
+		}
+		if d.IDType == "" {
+			v.must(p+".idType", "idType is required")
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+var zosNamespaces = map[specs.ZOSNamespaceType]bool{
+	specs.ZOSPIDNamespace:   true,
+	specs.ZOSMountNamespace: true,
+	specs.ZOSIPCNamespace:   true,
+	specs.ZOSUTSNamespace:   true,
+}
+
+func (v *validator) checkZOS() {
+	zos := v.spec.ZOS
+	if zos == nil {
+		return
+	}
+	seen := make(map[specs.ZOSNamespaceType]int)
+	for i, ns := range zos.Namespaces {
+		p := fmt.Sprintf("zos.namespaces[%d]", i)
+		if !zosNamespaces[ns.Type] {
+			v.must(p+".type", "unknown namespace type %q", ns.Type)
+		}
+		if j, ok := seen[ns.Type]; ok {
+			v.must(p+".type", "%s duplicates zos.namespaces[%d]", ns.Type, j)
+		} else {
+			seen[ns.Type] = i
+		}
+		v.checkNamespacePath(p+".path", ns.Path)
+	}
+}
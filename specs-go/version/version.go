@@ -0,0 +1,302 @@
+// Package version parses and compares the SemVer v2.0.0 versions used by the
+// ociVersion fields of configurations, states and Features structures.
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+// Dev is the build metadata used by development versions of the
+// specification, e.g. "1.3.0+dev".
+const Dev = "dev"
+
+// Version is a parsed SemVer v2.0.0 version.
+type Version struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+	// PreRelease is the dot separated pre-release identifiers, e.g. "rc.1".
+	PreRelease string
+	// Build is the dot separated build metadata, e.g. "dev".
+	Build string
+}
+
+// Parse parses s as a SemVer v2.0.0 version.
+func Parse(s string) (Version, error) {
+	var v Version
+	rest := s
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.Build = rest[i+1:]
+		rest = rest[:i]
+		if !validIdentifiers(v.Build, false) {
+			return Version{}, fmt.Errorf("invalid build metadata in version %q", s)
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.PreRelease = rest[i+1:]
+		rest = rest[:i]
+		if !validIdentifiers(v.PreRelease, true) {
+			return Version{}, fmt.Errorf("invalid pre-release in version %q", s)
+		}
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("version %q is not in MAJOR.MINOR.PATCH format", s)
+	}
+	nums := [3]*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := parseNumeric(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// MustParse is like Parse but panics if s cannot be parsed.
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Current returns the version of the specification supported by the
+// specs-go package.
+func Current() Version {
+	return Version{
+		Major: specs.VersionMajor,
+		Minor: specs.VersionMinor,
+		Patch: specs.VersionPatch,
+		Build: strings.TrimPrefix(specs.VersionDev, "+"),
+	}
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// IsDev reports whether v is a development version, i.e. it carries the
+// "+dev" build metadata.
+func (v Version) IsDev() bool {
+	return v.Build == Dev
+}
+
+// Compare returns -1, 0 or +1 depending on whether v precedes, equals or
+// follows w.
+//
+// Precedence follows SemVer v2.0.0, with one extension: the development
+// version "X.Y.Z+dev" follows the release "X.Y.Z" it was branched from, as
+// it may already contain changes which are not part of that release. Any
+// other build metadata is ignored.
+func (v Version) Compare(w Version) int {
+	if c := compareUint(v.Major, w.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, w.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, w.Patch); c != 0 {
+		return c
+	}
+	if c := comparePreRelease(v.PreRelease, w.PreRelease); c != 0 {
+		return c
+	}
+	switch {
+	case v.IsDev() && !w.IsDev():
+		return 1
+	case !v.IsDev() && w.IsDev():
+		return -1
+	}
+	return 0
+}
+
+// LessThan reports whether v precedes w.
+func (v Version) LessThan(w Version) bool {
+	return v.Compare(w) < 0
+}
+
+// Range is an inclusive range of versions. A nil bound is unbounded.
+type Range struct {
+	Min *Version
+	Max *Version
+}
+
+// ParseRange parses the bounds of a range. An empty string leaves the
+// corresponding bound open.
+func ParseRange(min, max string) (Range, error) {
+	var r Range
+	if min != "" {
+		v, err := Parse(min)
+		if err != nil {
+			return Range{}, err
+		}
+		r.Min = &v
+	}
+	if max != "" {
+		v, err := Parse(max)
+		if err != nil {
+			return Range{}, err
+		}
+		r.Max = &v
+	}
+	if r.Min != nil && r.Max != nil && r.Max.LessThan(*r.Min) {
+		return Range{}, fmt.Errorf("version range is empty: %s is greater than %s", r.Min, r.Max)
+	}
+	return r, nil
+}
+
+// FeaturesRange returns the range of versions recognized by the runtime
+// described by feat.
+func FeaturesRange(feat *features.Features) (Range, error) {
+	return ParseRange(feat.OCIVersionMin, feat.OCIVersionMax)
+}
+
+// Contains reports whether v lies within r.
+func (r Range) Contains(v Version) bool {
+	if r.Min != nil && v.LessThan(*r.Min) {
+		return false
+	}
+	if r.Max != nil && r.Max.LessThan(v) {
+		return false
+	}
+	return true
+}
+
+func (r Range) String() string {
+	lo, hi := "*", "*"
+	if r.Min != nil {
+		lo = r.Min.String()
+	}
+	if r.Max != nil {
+		hi = r.Max.String()
+	}
+	return "[" + lo + ", " + hi + "]"
+}
+
+// CanRead reports whether a document with version v can be decoded by this
+// package without losing information. The major version must match, and v
+// must not introduce functionality beyond Current: a later minor version, or
+// the development version of the current release when this package is a
+// release, may add fields which this package does not know about.
+func CanRead(v Version) error {
+	cur := Current()
+	if v.Major != cur.Major {
+		return fmt.Errorf("version %s is incompatible with the supported major version %d", v, cur.Major)
+	}
+	if v.Minor > cur.Minor {
+		return fmt.Errorf("version %s is newer than the supported version %s", v, cur)
+	}
+	if v.Minor == cur.Minor && v.IsDev() && !cur.IsDev() {
+		return fmt.Errorf("development version %s is newer than the supported version %s", v, cur)
+	}
+	return nil
+}
+
+// CheckReadable parses s, the ociVersion of a document, and reports whether
+// the document can be decoded by this package.
+func CheckReadable(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	return CanRead(v)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePreRelease compares pre-release identifiers. A version without
+// pre-release has higher precedence than one with.
+func comparePreRelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	fa, fb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		x, errX := strconv.ParseUint(fa[i], 10, 64)
+		y, errY := strconv.ParseUint(fb[i], 10, 64)
+		switch {
+		case errX == nil && errY == nil:
+			if c := compareUint(x, y); c != 0 {
+				return c
+			}
+		case errX == nil:
+			// Numeric identifiers have lower precedence.
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(fa[i], fb[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareUint(uint64(len(fa)), uint64(len(fb)))
+}
+
+func parseNumeric(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty numeric identifier")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("numeric identifier %q has a leading zero", s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("numeric identifier %q contains non-digits", s)
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// validIdentifiers reports whether s is a dot separated list of non-empty
+// identifiers made of [0-9A-Za-z-]. Numeric pre-release identifiers must
+// not have leading zeros.
+func validIdentifiers(s string, preRelease bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range id {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if preRelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package version
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Version
+		err  bool
+	}{
+		{in: "1.0.0", want: Version{Major: 1}},
+		{in: "1.2.3-rc.1+dev", want: Version{Major: 1, Minor: 2, Patch: 3, PreRelease: "rc.1", Build: "dev"}},
+		{in: "1.0.2-dev", want: Version{Major: 1, Patch: 2, PreRelease: "dev"}},
+		{in: "1.0.0+build.5-x", want: Version{Major: 1, Build: "build.5-x"}},
+		{in: "1.0", err: true},
+		{in: "1.0.0.0", err: true},
+		{in: "01.0.0", err: true},
+		{in: "1.0.x", err: true},
+		{in: "1.0.0-", err: true},
+		{in: "1.0.0-01", err: true},
+		{in: "1.0.0+a..b", err: true},
+		{in: "v1.0.0", err: true},
+	} {
+		got, err := Parse(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Parse(%q) = %#v, want %#v", tc.in, got, tc.want)
+		}
+		if got.String() != tc.in {
+			t.Errorf("Parse(%q).String() = %q", tc.in, got.String())
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// ordered lists versions in increasing order of precedence.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.0+dev",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := MustParse(a).Compare(MustParse(b)); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+	if c := MustParse("1.0.0+build.1").Compare(MustParse("1.0.0+build.2")); c != 0 {
+		t.Errorf("build metadata other than dev compared as %d, want 0", c)
+	}
+}
+
+func TestRange(t *testing.T) {
+	r, err := ParseRange("1.0.0", "1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v, want := range map[string]bool{
+		"0.9.0":      false,
+		"1.0.0-rc.1": false,
+		"1.0.0":      true,
+		"1.1.5":      true,
+		"1.2.0":      true,
+		"1.2.0+dev":  false,
+		"1.3.0":      false,
+	} {
+		if got := r.Contains(MustParse(v)); got != want {
+			t.Errorf("%s.Contains(%s) = %v, want %v", r, v, got, want)
+		}
+	}
+	if got := r.String(); got != "[1.0.0, 1.2.0]" {
+		t.Errorf("String() = %q", got)
+	}
+
+	open, err := ParseRange("", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !open.Contains(MustParse("0.1.0")) || open.String() != "[*, 1.0.0]" {
+		t.Errorf("range %s does not leave its minimum open", open)
+	}
+	if _, err := ParseRange("1.2.0", "1.0.0"); err == nil {
+		t.Error("ParseRange accepted an empty range")
+	}
+}
+
+func TestCanRead(t *testing.T) {
+	if got := Current().String(); got != specs.Version {
+		t.Fatalf("Current() = %s, want %s", got, specs.Version)
+	}
+	cur := Current()
+	for _, tc := range []struct {
+		v  Version
+		ok bool
+	}{
+		{Version{Major: cur.Major}, true},
+		{Version{Major: cur.Major, Minor: cur.Minor}, true},
+		{Version{Major: cur.Major, Minor: cur.Minor, Build: Dev}, cur.IsDev()},
+		{Version{Major: cur.Major, Minor: cur.Minor + 1}, false},
+		{Version{Major: cur.Major + 1}, false},
+	} {
+		err := CanRead(tc.v)
+		if (err == nil) != tc.ok {
+			t.Errorf("CanRead(%s) = %v, want ok %v", tc.v, err, tc.ok)
+		}
+	}
+	if err := CheckReadable(fmt.Sprintf("%d.0", cur.Major)); err == nil {
+		t.Error("CheckReadable accepted an invalid version")
+	}
+}